@@ -44,6 +44,9 @@ func main() {
 		analytics.ReportError(err)
 
 		analytics.Close()
+		if exerr, ok := errors.Cause(err).(utils.ErrorExitCode); ok {
+			os.Exit(exerr.ExitCode())
+		}
 		os.Exit(1)
 	}
 }
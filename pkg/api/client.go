@@ -289,6 +289,25 @@ func (c Client) CreateBuild(ctx context.Context, req CreateBuildRequest) (res Cr
 	return
 }
 
+// ListBuilds lists the most recent builds.
+func (c Client) ListBuilds(ctx context.Context, req ListBuildsRequest) (res ListBuildsResponse, err error) {
+	q := url.Values{}
+	if req.TaskID != "" {
+		q.Set("taskID", req.TaskID)
+	}
+	if req.Limit > 0 {
+		q.Set("limit", strconv.FormatInt(int64(req.Limit), 10))
+	}
+	err = c.do(ctx, "GET", "/builds/list?"+q.Encode(), nil, &res)
+	return
+}
+
+// CancelBuild cancels a hosted build.
+func (c Client) CancelBuild(ctx context.Context, buildID string) (err error) {
+	err = c.do(ctx, "POST", "/builds/cancel", CancelBuildRequest{BuildID: buildID}, nil)
+	return
+}
+
 // CreateBuildUpload creates an Airplane upload and returns metadata about it.
 func (c Client) CreateBuildUpload(ctx context.Context, req CreateBuildUploadRequest) (res CreateBuildUploadResponse, err error) {
 	err = c.do(ctx, "POST", "/builds/createUpload", req, &res)
@@ -313,6 +332,7 @@ func (c Client) DeleteAPIKey(ctx context.Context, req DeleteAPIKeyRequest) (err
 	return
 }
 
+// GetBuildLogs returns the logs of a hosted build by buildID and since timestamp.
 func (c Client) GetBuildLogs(ctx context.Context, buildID string, since time.Time) (res GetBuildLogsResponse, err error) {
 	q := url.Values{
 		"buildID": []string{buildID},
@@ -332,6 +352,18 @@ func (c Client) ListResources(ctx context.Context) (res ListResourcesResponse, e
 	return
 }
 
+// ListUsers lists all users in the team.
+func (c Client) ListUsers(ctx context.Context) (res ListUsersResponse, err error) {
+	err = c.do(ctx, "GET", "/users/list", nil, &res)
+	return
+}
+
+// ListGroups lists all groups in the team.
+func (c Client) ListGroups(ctx context.Context) (res ListGroupsResponse, err error) {
+	err = c.do(ctx, "GET", "/groups/list", nil, &res)
+	return
+}
+
 // Do sends a request with `method`, `path`, `payload` and `reply`.
 func (c Client) do(ctx context.Context, method, path string, payload, reply interface{}) error {
 	var url = "https://" + c.host() + "/v0" + path
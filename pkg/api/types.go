@@ -60,6 +60,15 @@ type Permission struct {
 
 type Action string
 
+const (
+	ActionTasksGet              Action = "tasks.get"
+	ActionTasksUpdate           Action = "tasks.update"
+	ActionTasksExecute          Action = "tasks.execute"
+	ActionRunsGet               Action = "runs.get"
+	ActionRunsCancel            Action = "runs.cancel"
+	ActionTriggerRequestsCreate Action = "trigger_requests.create"
+)
+
 type TaskKind string
 
 const (
@@ -67,8 +76,10 @@ const (
 	TaskKindDockerfile TaskKind = "dockerfile"
 	TaskKindGo         TaskKind = "go"
 	TaskKindImage      TaskKind = "image"
+	TaskKindJava       TaskKind = "java"
 	TaskKindNode       TaskKind = "node"
 	TaskKindPython     TaskKind = "python"
+	TaskKindRuby       TaskKind = "ruby"
 	TaskKindShell      TaskKind = "shell"
 
 	TaskKindSQL  TaskKind = "sql"
@@ -451,6 +462,22 @@ type CreateBuildResponse struct {
 	Build Build `json:"build"`
 }
 
+// ListBuildsRequest represents a list builds request.
+type ListBuildsRequest struct {
+	TaskID string `json:"taskID"`
+	Limit  int    `json:"limit"`
+}
+
+// ListBuildsResponse represents a list builds response.
+type ListBuildsResponse struct {
+	Builds []Build `json:"builds"`
+}
+
+// CancelBuildRequest represents a cancel build request.
+type CancelBuildRequest struct {
+	BuildID string `json:"buildID"`
+}
+
 type Build struct {
 	ID             string      `json:"id"`
 	TaskRevisionID string      `json:"taskRevisionID"`
@@ -478,6 +505,9 @@ func (s BuildStatus) Stopped() bool {
 
 type CreateBuildUploadRequest struct {
 	SizeBytes int `json:"sizeBytes"`
+	// Resumable requests a write-only URL that starts a resumable upload
+	// session, which the archive is then uploaded to in chunks.
+	Resumable bool `json:"resumable,omitempty"`
 }
 
 type CreateBuildUploadResponse struct {
@@ -518,6 +548,27 @@ type GetUniqueSlugResponse struct {
 	Slug string `json:"slug"`
 }
 
+// User represents a member of a team.
+type User struct {
+	ID    string `json:"id"`
+	Email string `json:"email"`
+	Name  string `json:"name"`
+}
+
+type ListUsersResponse struct {
+	Users []User `json:"users"`
+}
+
+// Group represents a group of users.
+type Group struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type ListGroupsResponse struct {
+	Groups []Group `json:"groups"`
+}
+
 type ListResourcesResponse struct {
 	Resources []Resource `json:"resources"`
 }
@@ -0,0 +1,289 @@
+package build
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/docker/docker/pkg/archive"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+// archiveEpoch is the modification time of every archive entry.
+var archiveEpoch = time.Unix(0, 0)
+
+// includeFunc reports whether a file or directory is included in an
+// archive. Excluded directories are skipped along with their children.
+type includeFunc func(path string, info os.FileInfo) (bool, error)
+
+// archiveFile is a generated file that is added to an archive, f.e. the
+// Dockerfile of a build.
+type archiveFile struct {
+	// Name is the slash-separated path of the file within the archive.
+	Name string
+	Data []byte
+}
+
+// contextArchive is the build context of a task: the files of root that
+// include includes, along with generated files.
+//
+// Local and remote builds archive their context with the same walk, and
+// stream it to Docker or the upload instead of copying it first.
+type contextArchive struct {
+	root    string
+	include includeFunc
+	files   []archiveFile
+
+	// progress is called with the number of bytes of file contents as
+	// they are archived, if set.
+	progress func(n int64)
+}
+
+// write writes a gzipped tarball of the context to w and returns the
+// digest of the written bytes.
+//
+// The archive is reproducible: entries are sorted by path, and times,
+// owners and the gzip header are fixed. Modes are reduced to 0755 or 0644,
+// so the same context always produces the same bytes and digest.
+func (a contextArchive) write(w io.Writer) (digest.Digest, error) {
+	digester := digest.Canonical.Digester()
+	zw := gzip.NewWriter(io.MultiWriter(w, digester.Hash()))
+	// The gzip header must not carry a name or a modification time.
+	zw.Header = gzip.Header{OS: 255}
+	tw := tar.NewWriter(zw)
+
+	written, err := a.writeFiles(tw)
+	if err != nil {
+		return "", err
+	}
+
+	// filepath.Walk visits the entries of a directory in lexical order.
+	err = filepath.Walk(a.root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == a.root {
+			return nil
+		}
+		if a.include != nil {
+			ok, err := a.include(p, info)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+
+		rel, err := filepath.Rel(a.root, p)
+		if err != nil {
+			return errors.Wrap(err, "getting archive relative path")
+		}
+		name := filepath.ToSlash(rel)
+		if written[name] {
+			// Generated files take precedence over the files of root.
+			return nil
+		}
+		return a.writeEntry(tw, name, p, info)
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "building archive")
+	}
+
+	if err := tw.Close(); err != nil {
+		return "", errors.Wrap(err, "closing archive")
+	}
+	if err := zw.Close(); err != nil {
+		return "", errors.Wrap(err, "compressing archive")
+	}
+	return digester.Digest(), nil
+}
+
+// writeFiles writes the generated files, and their parent directories, to
+// tw and returns the names of the written entries.
+func (a contextArchive) writeFiles(tw *tar.Writer) (map[string]bool, error) {
+	files := append([]archiveFile(nil), a.files...)
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+
+	written := map[string]bool{}
+	for _, f := range files {
+		var dirs []string
+		for dir := path.Dir(f.Name); dir != "." && dir != "/"; dir = path.Dir(dir) {
+			dirs = append([]string{dir}, dirs...)
+		}
+		for _, dir := range dirs {
+			if written[dir] {
+				continue
+			}
+			written[dir] = true
+			if err := tw.WriteHeader(archiveHeader(dir, tar.TypeDir, 0)); err != nil {
+				return nil, errors.Wrapf(err, "writing header of %s", dir)
+			}
+		}
+
+		written[f.Name] = true
+		if err := tw.WriteHeader(archiveHeader(f.Name, tar.TypeReg, int64(len(f.Data)))); err != nil {
+			return nil, errors.Wrapf(err, "writing header of %s", f.Name)
+		}
+		if _, err := tw.Write(f.Data); err != nil {
+			return nil, errors.Wrapf(err, "archiving %s", f.Name)
+		}
+	}
+	return written, nil
+}
+
+// writeEntry writes a single entry of root to tw. Entries that are
+// neither files, directories nor symbolic links are left out.
+func (a contextArchive) writeEntry(tw *tar.Writer, name, p string, info os.FileInfo) error {
+	var hdr *tar.Header
+	mode := info.Mode()
+	switch {
+	case mode.IsDir():
+		hdr = archiveHeader(name, tar.TypeDir, 0)
+	case mode&os.ModeSymlink != 0:
+		target, err := os.Readlink(p)
+		if err != nil {
+			return errors.Wrapf(err, "reading link %s", name)
+		}
+		hdr = archiveHeader(name, tar.TypeSymlink, 0)
+		hdr.Linkname = target
+	case mode.IsRegular():
+		hdr = archiveHeader(name, tar.TypeReg, info.Size())
+		if mode&0111 != 0 {
+			hdr.Mode = 0755
+		}
+	default:
+		return nil
+	}
+
+	if err := tw.WriteHeader(hdr); err != nil {
+		return errors.Wrapf(err, "writing header of %s", name)
+	}
+	if hdr.Typeflag != tar.TypeReg {
+		return nil
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return errors.Wrapf(err, "opening %s", name)
+	}
+	defer f.Close()
+	var w io.Writer = tw
+	if a.progress != nil {
+		w = reportWriter{w: tw, report: a.progress}
+	}
+	if _, err := io.CopyN(w, f, hdr.Size); err != nil {
+		return errors.Wrapf(err, "archiving %s", name)
+	}
+	return nil
+}
+
+// archiveHeader returns the normalized header of an entry.
+func archiveHeader(name string, typeflag byte, size int64) *tar.Header {
+	hdr := &tar.Header{
+		Typeflag: typeflag,
+		Name:     name,
+		Size:     size,
+		Mode:     0644,
+		ModTime:  archiveEpoch,
+		Format:   tar.FormatUSTAR,
+	}
+	switch typeflag {
+	case tar.TypeDir:
+		hdr.Name += "/"
+		hdr.Mode = 0755
+	case tar.TypeSymlink:
+		hdr.Mode = 0777
+	}
+	if len(hdr.Name) > 100 {
+		// USTAR can't always store long names, PAX can.
+		hdr.Format = tar.FormatPAX
+	}
+	return hdr
+}
+
+// reportWriter reports the number of bytes written to w.
+type reportWriter struct {
+	w      io.Writer
+	report func(n int64)
+}
+
+func (w reportWriter) Write(b []byte) (int, error) {
+	n, err := w.w.Write(b)
+	w.report(int64(n))
+	return n, err
+}
+
+// stream returns a reader that the archive is written to as it is read,
+// without buffering it in memory or on disk.
+//
+// The reader must be closed, after which result returns the archive's size
+// and digest.
+func (a contextArchive) stream() *archiveStream {
+	pr, pw := io.Pipe()
+	s := &archiveStream{PipeReader: pr, done: make(chan struct{})}
+	go func() {
+		defer close(s.done)
+		cw := &countingWriter{w: pw}
+		s.digest, s.err = a.write(cw)
+		s.size = cw.n
+		pw.CloseWithError(s.err)
+	}()
+	return s
+}
+
+// extract writes the context to the directory dst.
+func (a contextArchive) extract(dst string) error {
+	s := a.stream()
+	err := archive.Untar(s, dst, &archive.TarOptions{NoLchown: true})
+	s.Close()
+	if _, _, serr := s.result(); serr != nil && err != nil {
+		return serr
+	}
+	if err != nil {
+		return errors.Wrapf(err, "writing build context to %s", dst)
+	}
+	return nil
+}
+
+// archiveStream is an archive that is written as it's read.
+type archiveStream struct {
+	*io.PipeReader
+
+	done   chan struct{}
+	size   int64
+	digest digest.Digest
+	err    error
+}
+
+// result waits until the archive was written, or failed to be written,
+// and returns its size and digest.
+//
+// Writing the archive fails if the reader is closed before it was read
+// to the end.
+func (s *archiveStream) result() (int64, digest.Digest, error) {
+	<-s.done
+	return s.size, s.digest, s.err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	n, err := w.w.Write(b)
+	w.n += int64(n)
+	return n, err
+}
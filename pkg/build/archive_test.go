@@ -0,0 +1,188 @@
+package build
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/airplanedev/cli/pkg/build/ignore"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchiveReproducible(t *testing.T) {
+	require := require.New(t)
+	const src = "testdata/node/workspace"
+
+	archive := func(root string) ([]byte, digest.Digest) {
+		include, err := ignore.Func(root)
+		require.NoError(err)
+		var buf bytes.Buffer
+		d, err := contextArchive{root: root, include: include}.write(&buf)
+		require.NoError(err)
+		require.Equal(digest.FromBytes(buf.Bytes()), d)
+		return buf.Bytes(), d
+	}
+
+	// Archive two copies of the same tree, created at different times.
+	roots := make([]string, 2)
+	for i := range roots {
+		root, err := ioutil.TempDir("", "airplane_archive_test_*")
+		require.NoError(err)
+		defer os.RemoveAll(root)
+		require.NoError(contextArchive{root: src}.extract(root))
+		roots[i] = root
+	}
+	first, firstDigest := archive(roots[0])
+
+	mtime := time.Now().Add(time.Hour)
+	require.NoError(filepath.Walk(roots[1], func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(path, mtime, mtime)
+	}))
+	second, secondDigest := archive(roots[1])
+
+	require.Equal(first, second)
+	require.Equal(firstDigest, secondDigest)
+
+	zr, err := gzip.NewReader(bytes.NewReader(first))
+	require.NoError(err)
+	require.True(zr.ModTime.IsZero())
+	require.Empty(zr.Name)
+
+	var names []string
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(err)
+		require.Equal(archiveEpoch.Unix(), hdr.ModTime.Unix())
+		require.Zero(hdr.Uid)
+		require.Zero(hdr.Gid)
+		require.Empty(hdr.Uname)
+		require.Empty(hdr.Gname)
+		names = append(names, hdr.Name)
+	}
+	require.Equal([]string{
+		"package-lock.json",
+		"package.json",
+		"packages/",
+		"packages/lib/",
+		"packages/lib/package.json",
+		"packages/lib/src/",
+		"packages/lib/src/index.ts",
+		"packages/task/",
+		"packages/task/main.ts",
+		"packages/task/package.json",
+		"packages/task/tsconfig.json",
+	}, names)
+}
+
+func TestArchiveSymlinks(t *testing.T) {
+	require := require.New(t)
+
+	root, err := ioutil.TempDir("", "airplane_archive_test_*")
+	require.NoError(err)
+	defer os.RemoveAll(root)
+	require.NoError(os.MkdirAll(filepath.Join(root, "lib"), 0755))
+	require.NoError(os.MkdirAll(filepath.Join(root, "node_modules/.bin"), 0755))
+	require.NoError(ioutil.WriteFile(filepath.Join(root, "lib/index.js"), []byte("module.exports = {}\n"), 0644))
+	require.NoError(ioutil.WriteFile(filepath.Join(root, ignore.Filename), []byte("ignored.js\n"), 0644))
+	for link, target := range map[string]string{
+		"index.js":              "lib/index.js",
+		"shared":                "lib",
+		"missing.js":            "does-not-exist.js",
+		"ignored.js":            "lib/index.js",
+		"node_modules/.bin/tsc": "../typescript/bin/tsc",
+	} {
+		require.NoError(os.Symlink(target, filepath.Join(root, link)))
+	}
+
+	include, err := ignore.Func(root)
+	require.NoError(err)
+	var buf bytes.Buffer
+	_, err = contextArchive{root: root, include: include}.write(&buf)
+	require.NoError(err)
+
+	zr, err := gzip.NewReader(&buf)
+	require.NoError(err)
+	links := map[string]string{}
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(err)
+		if hdr.Typeflag == tar.TypeSymlink {
+			links[hdr.Name] = hdr.Linkname
+		}
+	}
+
+	// Links are archived as is, unless they're excluded:
+	require.Equal(map[string]string{
+		"index.js":   "lib/index.js",
+		"shared":     "lib",
+		"missing.js": "does-not-exist.js",
+	}, links)
+}
+
+func TestArchiveStream(t *testing.T) {
+	require := require.New(t)
+
+	var progress int64
+	archive := contextArchive{
+		root: "testdata/node/workspace",
+		files: []archiveFile{
+			{Name: ".airplane/Dockerfile", Data: []byte("FROM node:16\n")},
+			// Generated files replace the files of the root.
+			{Name: "package.json", Data: []byte("{}\n")},
+		},
+		progress: func(n int64) { progress += n },
+	}
+
+	var buf bytes.Buffer
+	d, err := archive.write(&buf)
+	require.NoError(err)
+
+	s := archive.stream()
+	streamed, err := ioutil.ReadAll(s)
+	require.NoError(err)
+	require.NoError(s.Close())
+	size, sd, err := s.result()
+	require.NoError(err)
+	require.Equal(buf.Bytes(), streamed)
+	require.Equal(int64(len(streamed)), size)
+	require.Equal(d, sd)
+	require.NotZero(progress)
+
+	dst, err := ioutil.TempDir("", "airplane_archive_test_*")
+	require.NoError(err)
+	defer os.RemoveAll(dst)
+	require.NoError(archive.extract(dst))
+
+	dockerfile, err := ioutil.ReadFile(filepath.Join(dst, ".airplane/Dockerfile"))
+	require.NoError(err)
+	require.Equal("FROM node:16\n", string(dockerfile))
+	pkg, err := ioutil.ReadFile(filepath.Join(dst, "package.json"))
+	require.NoError(err)
+	require.Equal("{}\n", string(pkg))
+	_, err = os.Stat(filepath.Join(dst, "packages/task/main.ts"))
+	require.NoError(err)
+
+	// Closing the stream early fails writing it.
+	s = archive.stream()
+	require.NoError(s.Close())
+	_, _, err = s.result()
+	require.Error(err)
+}
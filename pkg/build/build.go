@@ -2,14 +2,22 @@ package build
 
 import (
 	"context"
+	"encoding/json"
+	"regexp"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/airplanedev/cli/pkg/api"
 	"github.com/airplanedev/cli/pkg/taskdir/definitions"
 	"github.com/pkg/errors"
 )
 
+var (
+	// safeShellRegex matches shell arguments that don't need to be quoted.
+	safeShellRegex = regexp.MustCompile(`^[A-Za-z0-9_./,:=@%+-]+$`)
+)
+
 // Request represents a build request.
 type Request struct {
 	Local   bool
@@ -19,6 +27,50 @@ type Request struct {
 	TaskID  string
 	TaskEnv api.TaskEnv
 	Shim    bool
+
+	// CacheFrom and CacheTo are image references to import the build
+	// cache from and to export it to. Only supported for local builds.
+	CacheFrom []string
+	CacheTo   string
+
+	// Platforms are the platforms to build for, f.e. linux/arm64. If empty,
+	// it builds for the default platform. Only supported for local builds.
+	Platforms []string
+
+	// Tag is the tag of the built image, defaults to "latest".
+	//
+	// Remote builds are always tagged with their build ID.
+	Tag string
+
+	// SkipPush builds the image without pushing it to the registry.
+	// Only supported for local builds.
+	SkipPush bool
+
+	// ReadOnly prevents the build from updating the task.
+	//
+	// Remote builds read the task's kind and kind options from Airplane,
+	// so they fail if those differ from Def.
+	ReadOnly bool
+
+	// DockerfileOutput and ContextOutput are paths that the generated
+	// Dockerfile and the build context are written to, if set.
+	//
+	// Remote builders generate their own Dockerfile, so for remote builds
+	// DockerfileOutput is the Dockerfile that the CLI would build with.
+	DockerfileOutput string
+	ContextOutput    string
+
+	// Context configures the size limits of the build context. Only
+	// supported for remote builds, whose context is uploaded.
+	Context ContextOptions
+
+	// Timeout cancels the build if it takes longer, if set. Remote builds
+	// are cancelled on the builder too.
+	Timeout time.Duration
+
+	// SBOM generates a software bill of materials of the built image and
+	// checks it for vulnerabilities, if set.
+	SBOM *SBOMOptions
 }
 
 // Response represents a build response.
@@ -26,14 +78,59 @@ type Response struct {
 	ImageURL string
 	// Optional, only if applicable
 	BuildID string
+	// Digest is the digest of the pushed image, if known.
+	Digest string
+}
+
+// PinnedImageURL returns an image URL that always refers to the built image,
+// f.e. to share the image with other tasks.
+//
+// Tags such as "latest" are moved by later builds, so the URL is pinned by
+// digest instead. Remote builds are tagged with their build ID, which is
+// never reused. If neither applies, it returns an empty string.
+func (r Response) PinnedImageURL() string {
+	if r.Digest != "" {
+		repo := r.ImageURL
+		if i := strings.LastIndex(repo, ":"); i > strings.LastIndex(repo, "/") {
+			repo = repo[:i]
+		}
+		return repo + "@" + r.Digest
+	}
+	if r.BuildID != "" {
+		return r.ImageURL
+	}
+	return ""
 }
 
 // Run runs the build and returns an image URL.
 func Run(ctx context.Context, req Request) (*Response, error) {
+	buildCtx := ctx
+	if req.Timeout > 0 {
+		var cancel context.CancelFunc
+		buildCtx, cancel = context.WithTimeout(ctx, req.Timeout)
+		defer cancel()
+	}
+
+	var resp *Response
+	var err error
 	if req.Local {
-		return local(ctx, req)
+		resp, err = local(buildCtx, req)
+	} else {
+		resp, err = remote(buildCtx, req)
+	}
+	if err != nil {
+		if ctx.Err() == nil && buildCtx.Err() == context.DeadlineExceeded {
+			return nil, errors.Errorf("build timed out after %s", req.Timeout)
+		}
+		return nil, err
+	}
+
+	if req.SBOM != nil {
+		if err := report(ctx, req.Client, req.Root, resp, *req.SBOM); err != nil {
+			return nil, err
+		}
 	}
-	return remote(ctx, req)
+	return resp, nil
 }
 
 // applyTemplate executes template t with the provided data and
@@ -61,6 +158,42 @@ func inlineString(s string) string {
 	return "echo '" + s + "'"
 }
 
+// inlinePrintf is like inlineString, but writes s with printf. Unlike echo,
+// printf interprets the escaped newlines in every shell, f.e. in busybox
+// on Alpine-based images. Backslashes in s are escaped, so that s is
+// written verbatim.
+func inlinePrintf(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.Join(strings.Split(s, "\n"), "\\n\\\n")
+	s = strings.ReplaceAll(s, "'", `'"'"'`)
+	return "printf '%b' '" + s + "'"
+}
+
+// jsonArray formats elems as a JSON array, f.e. for the exec form of a
+// Dockerfile instruction.
+func jsonArray(elems ...string) string {
+	quoted := make([]string, len(elems))
+	for i, e := range elems {
+		b, _ := json.Marshal(e)
+		quoted[i] = string(b)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// shellJoin formats args as a shell command, single-quoting arguments that
+// contain characters with a special meaning to the shell.
+func shellJoin(args ...string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg != "" && safeShellRegex.MatchString(arg) {
+			quoted[i] = arg
+		} else {
+			quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'"'"'`) + "'"
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
 // backslashEscape escapes s by replacing `\` with `\\` and all runes in chars with `\{rune}`.
 // Typically should backslashEscape(s, `"`) to escape backslashes and double quotes.
 func backslashEscape(s string, chars string) string {
@@ -18,3 +18,24 @@ func TestInlineString(t *testing.T) {
 		inlineString(`'''`),
 	)
 }
+
+func TestPinnedImageURL(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal(
+		"us-docker.pkg.dev/airplane/tasks/task-abc@sha256:f00",
+		Response{ImageURL: "us-docker.pkg.dev/airplane/tasks/task-abc:latest", Digest: "sha256:f00"}.PinnedImageURL(),
+	)
+	require.Equal(
+		"localhost:5000/task-abc@sha256:f00",
+		Response{ImageURL: "localhost:5000/task-abc", Digest: "sha256:f00"}.PinnedImageURL(),
+	)
+	require.Equal(
+		"us-docker.pkg.dev/airplane/tasks/task-abc:build123",
+		Response{ImageURL: "us-docker.pkg.dev/airplane/tasks/task-abc:build123", BuildID: "build123"}.PinnedImageURL(),
+	)
+	require.Equal(
+		"",
+		Response{ImageURL: "us-docker.pkg.dev/airplane/tasks/task-abc:latest"}.PinnedImageURL(),
+	)
+}
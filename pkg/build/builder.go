@@ -5,6 +5,8 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
@@ -14,9 +16,11 @@ import (
 	"github.com/airplanedev/cli/pkg/build/ignore"
 	"github.com/airplanedev/cli/pkg/logger"
 	"github.com/airplanedev/cli/pkg/utils/bufiox"
+	"github.com/airplanedev/cli/pkg/utils/pointers"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/client"
 	dockerJSONMessage "github.com/docker/docker/pkg/jsonmessage"
+	"github.com/dustin/go-humanize"
 	"github.com/mattn/go-isatty"
 	"github.com/pkg/errors"
 )
@@ -32,6 +36,14 @@ func (r RegistryAuth) host() string {
 	return strings.SplitN(r.Repo, "/", 2)[0]
 }
 
+// AuthConfig returns the docker auth config of the registry.
+func (r RegistryAuth) authConfig() types.AuthConfig {
+	return types.AuthConfig{
+		Username: "oauth2accesstoken",
+		Password: r.Token,
+	}
+}
+
 // LocalConfig configures a (local) builder.
 type LocalConfig struct {
 	// Root is the root directory.
@@ -58,27 +70,75 @@ type LocalConfig struct {
 	Auth *RegistryAuth
 
 	// BuildEnv is a map of build-time environment variables to use.
+	//
+	// They are passed as build args and are visible in the image history,
+	// use BuildSecrets for sensitive values.
 	BuildEnv map[string]string
+
+	// BuildSecrets is a map of build secrets to use.
+	//
+	// They can be mounted in a RUN instruction with
+	// `--mount=type=secret,id=<key>` and are never persisted in the image.
+	BuildSecrets map[string]string
+
+	// CacheFrom is a list of images to import the build cache from.
+	CacheFrom []string
+
+	// CacheTo is an image to export the build cache to.
+	//
+	// The cache is embedded into the built image, which is additionally
+	// tagged as CacheTo. Callers are responsible for pushing it.
+	CacheTo string
+
+	// Platforms are the platforms to build the image for.
+	//
+	// If empty, it builds for the default platform. Multi-platform images
+	// are built with docker buildx and pushed by Build.
+	Platforms []string
+
+	// DockerfileOutput is a path that the generated Dockerfile is written
+	// to, if set.
+	DockerfileOutput string
+
+	// ContextOutput is a directory that the build context is written to,
+	// if set.
+	ContextOutput string
 }
 
 type DockerfileConfig struct {
 	Builder string
 	Root    string
 	Options api.KindOptions
+
+	// Platforms are the platforms that the Dockerfile is built for.
+	//
+	// If empty, it assumes the default platform.
+	Platforms []string
+}
+
+// LocalBuilder builds images with a local Docker daemon.
+type LocalBuilder struct {
+	root         string
+	name         string
+	options      api.KindOptions
+	auth         *RegistryAuth
+	buildEnv     map[string]string
+	buildSecrets map[string]string
+	cacheFrom    []string
+	cacheTo      string
+	platforms    []string
+	outputs      outputs
+	client       *client.Client
 }
 
-// Builder implements an image builder.
-type Builder struct {
-	root     string
-	name     string
-	options  api.KindOptions
-	auth     *RegistryAuth
-	buildEnv map[string]string
-	client   *client.Client
+// outputs are paths that build artifacts are written to.
+type outputs struct {
+	dockerfile string
+	context    string
 }
 
 // New returns a new local builder with c.
-func New(c LocalConfig) (*Builder, error) {
+func New(c LocalConfig) (*LocalBuilder, error) {
 	if !filepath.IsAbs(c.Root) {
 		return nil, fmt.Errorf("build: expected an absolute root path, got %q", c.Root)
 	}
@@ -95,6 +155,10 @@ func New(c LocalConfig) (*Builder, error) {
 		return nil, fmt.Errorf("build: builder requires registry auth")
 	}
 
+	if len(c.Platforms) == 0 {
+		c.Platforms = []string{DefaultPlatform}
+	}
+
 	client, err := client.NewClientWithOpts(
 		client.FromEnv,
 		client.WithAPIVersionNegotiation(),
@@ -103,13 +167,21 @@ func New(c LocalConfig) (*Builder, error) {
 		return nil, err
 	}
 
-	return &Builder{
-		root:     c.Root,
-		name:     c.Builder,
-		options:  c.Options,
-		auth:     c.Auth,
-		buildEnv: c.BuildEnv,
-		client:   client,
+	return &LocalBuilder{
+		root:         c.Root,
+		name:         c.Builder,
+		options:      c.Options,
+		auth:         c.Auth,
+		buildEnv:     c.BuildEnv,
+		buildSecrets: c.BuildSecrets,
+		cacheFrom:    c.CacheFrom,
+		cacheTo:      c.CacheTo,
+		platforms:    c.Platforms,
+		outputs: outputs{
+			dockerfile: c.DockerfileOutput,
+			context:    c.ContextOutput,
+		},
+		client: client,
 	}, nil
 }
 
@@ -121,27 +193,31 @@ func New(c LocalConfig) (*Builder, error) {
 // The method creates a Dockerfile depending on the configured builder
 // and adds it to the tree, it passes the tree as the build context
 // and initializes the build.
-func (b *Builder) Build(ctx context.Context, taskID, version string) (*Response, error) {
+func (b *LocalBuilder) Build(ctx context.Context, taskID, version string) (*Response, error) {
 	var repo = b.auth.Repo
 	var name = "task-" + sanitizeTaskID(taskID)
 	var uri = repo + "/" + name + ":" + version
 
-	patterns, err := ignore.DockerignorePatterns(b.root)
-	if err != nil {
-		return nil, err
+	// Dockerfile tasks can configure the build context and more, other
+	// tasks are built with their root as the context.
+	dfOpts := dockerfileOptions{Context: b.root}
+	if Name(b.name) == NameDockerfile {
+		var err error
+		if dfOpts, err = getDockerfileOptions(b.root, b.options); err != nil {
+			return nil, err
+		}
 	}
-	tree, err := NewTree(TreeOptions{
-		ExcludePatterns: patterns,
-	})
+
+	include, err := ignore.Func(dfOpts.Context)
 	if err != nil {
-		return nil, errors.Wrap(err, "new tree")
+		return nil, err
 	}
-	defer tree.Close()
 
 	dockerfile, err := BuildDockerfile(DockerfileConfig{
-		Builder: b.name,
-		Root:    b.root,
-		Options: b.options,
+		Builder:   b.name,
+		Root:      b.root,
+		Options:   b.options,
+		Platforms: b.platforms,
 	})
 	if err != nil {
 		return nil, errors.Wrap(err, "creating dockerfile")
@@ -149,44 +225,85 @@ func (b *Builder) Build(ctx context.Context, taskID, version string) (*Response,
 	logger.Debug(strings.TrimSpace(dockerfile))
 
 	dockerfilePath := ".airplane/Dockerfile"
-	logger.Debug("writing dockerfile to %s", dockerfilePath)
-	if err := tree.MkdirAll(filepath.Dir(dockerfilePath)); err != nil {
-		return nil, err
-	}
-	if err := tree.Write(dockerfilePath, strings.NewReader(dockerfile)); err != nil {
-		return nil, err
+	archive := contextArchive{
+		root:    dfOpts.Context,
+		include: include,
+		files:   []archiveFile{{Name: dockerfilePath, Data: []byte(dockerfile)}},
 	}
 
-	if err := tree.Copy(b.root); err != nil {
-		return nil, err
+	if b.outputs.dockerfile != "" {
+		if err := ioutil.WriteFile(b.outputs.dockerfile, []byte(dockerfile), 0644); err != nil {
+			return nil, errors.Wrap(err, "writing dockerfile")
+		}
+	}
+	if b.outputs.context != "" {
+		if err := archive.extract(b.outputs.context); err != nil {
+			return nil, err
+		}
 	}
 
-	bc, err := tree.Archive()
-	if err != nil {
-		return nil, err
+	if b.MultiPlatform() {
+		// buildx reads the build context from a directory.
+		dir, err := ioutil.TempDir("", "airplane_context_*")
+		if err != nil {
+			return nil, errors.Wrap(err, "tempdir")
+		}
+		defer os.RemoveAll(dir)
+		if err := archive.extract(dir); err != nil {
+			return nil, err
+		}
+		return b.buildx(ctx, dir, dockerfilePath, uri, dfOpts)
 	}
+
+	// The build context is streamed to the daemon as it's archived.
+	bc := archive.stream()
 	defer bc.Close()
 
 	buildArgs := make(map[string]*string)
-	for k, v := range b.buildEnv {
+	for k, v := range b.buildArgs(dfOpts) {
 		value := v
 		buildArgs[k] = &value
 	}
 
+	tags := []string{uri}
+	if b.cacheTo != "" {
+		// The dockerd BuildKit builder can't export a standalone cache, so
+		// the cache is exported inline with the image instead.
+		buildArgs["BUILDKIT_INLINE_CACHE"] = pointers.String("1")
+		tags = append(tags, b.cacheTo)
+	}
+
+	// Build secrets are served to BuildKit over a session that is
+	// attached to the daemon for the duration of the build.
+	sess, err := b.session(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer sess.Close()
+
 	opts := types.ImageBuildOptions{
 		Dockerfile:  dockerfilePath,
-		Tags:        []string{uri},
+		Tags:        tags,
+		CacheFrom:   b.cacheFrom,
 		BuildArgs:   buildArgs,
-		Platform:    "linux/amd64",
+		Target:      dfOpts.Target,
+		Platform:    b.platforms[0],
 		AuthConfigs: b.authconfigs(),
+		Version:     types.BuilderBuildKit,
+		SessionID:   sess.ID(),
 	}
 
 	resp, err := b.client.ImageBuild(ctx, bc, opts)
 	if err != nil {
+		bc.Close()
+		if _, _, serr := bc.result(); serr != nil && !errors.Is(serr, io.ErrClosedPipe) {
+			return nil, serr
+		}
 		return nil, errors.Wrap(err, "image build")
 	}
 	defer resp.Body.Close()
 
+	progress := newProgressWriter(os.Stderr)
 	scanner := bufiox.NewScanner(resp.Body)
 	for scanner.Scan() {
 		var event *dockerJSONMessage.JSONMessage
@@ -194,6 +311,13 @@ func (b *Builder) Build(ctx context.Context, taskID, version string) (*Response,
 			return nil, errors.Wrap(err, "unmarshalling docker build event")
 		}
 
+		if event.ID == buildkitTraceID && event.Aux != nil {
+			if err := progress.Write(*event.Aux); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
 		if err := event.Display(os.Stderr, isatty.IsTerminal(os.Stderr.Fd())); err != nil {
 			return nil, errors.Wrap(err, "docker build")
 		}
@@ -202,23 +326,54 @@ func (b *Builder) Build(ctx context.Context, taskID, version string) (*Response,
 		return nil, errors.Wrap(err, "scanning")
 	}
 
+	bc.Close()
+	if size, digest, err := bc.result(); err == nil {
+		logger.Log(logger.Gray("Build context: %s %s", humanize.Bytes(uint64(size)), digest))
+	}
+
 	return &Response{
 		ImageURL: uri,
 	}, nil
 }
 
-// Push pushes the given image.
-func (b *Builder) Push(ctx context.Context, uri string) error {
-	authjson, err := json.Marshal(b.registryAuth())
+// buildArgs returns the build args of a build with opts.
+//
+// The build env takes precedence over the build args of the options.
+func (b *LocalBuilder) buildArgs(opts dockerfileOptions) map[string]string {
+	args := make(map[string]string, len(opts.BuildArgs)+len(b.buildEnv))
+	for k, v := range opts.BuildArgs {
+		args[k] = v
+	}
+	for k, v := range b.buildEnv {
+		args[k] = v
+	}
+	return args
+}
+
+// MultiPlatform reports whether b builds a multi-platform image.
+//
+// Multi-platform images are pushed as part of the build.
+func (b *LocalBuilder) MultiPlatform() bool {
+	return len(b.platforms) > 1
+}
+
+// Push pushes the given image and returns the digest that the registry
+// reported for it, if any.
+func (b *LocalBuilder) Push(ctx context.Context, uri string) (digest string, err error) {
+	var auth types.AuthConfig
+	if strings.SplitN(uri, "/", 2)[0] == b.auth.host() {
+		auth = b.registryAuth()
+	}
+	authjson, err := json.Marshal(auth)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	resp, err := b.client.ImagePush(ctx, uri, types.ImagePushOptions{
 		RegistryAuth: base64.URLEncoding.EncodeToString(authjson),
 	})
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer resp.Close()
 
@@ -226,30 +381,34 @@ func (b *Builder) Push(ctx context.Context, uri string) error {
 	for scanner.Scan() {
 		var event *dockerJSONMessage.JSONMessage
 		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
-			return errors.Wrap(err, "unmarshalling docker build event")
+			return "", errors.Wrap(err, "unmarshalling docker build event")
+		}
+
+		if event.Aux != nil {
+			var result types.PushResult
+			if err := json.Unmarshal(*event.Aux, &result); err == nil && result.Digest != "" {
+				digest = result.Digest
+			}
 		}
 
 		if err := event.Display(os.Stderr, isatty.IsTerminal(os.Stderr.Fd())); err != nil {
-			return errors.Wrap(err, "docker push")
+			return "", errors.Wrap(err, "docker push")
 		}
 	}
 	if err := scanner.Err(); err != nil {
-		return errors.Wrap(err, "scanning")
+		return "", errors.Wrap(err, "scanning")
 	}
 
-	return nil
+	return digest, nil
 }
 
 // RegistryAuth returns the registry auth.
-func (b *Builder) registryAuth() types.AuthConfig {
-	return types.AuthConfig{
-		Username: "oauth2accesstoken",
-		Password: b.auth.Token,
-	}
+func (b *LocalBuilder) registryAuth() types.AuthConfig {
+	return b.auth.authConfig()
 }
 
 // Authconfigs returns the authconfigs to use.
-func (b *Builder) authconfigs() map[string]types.AuthConfig {
+func (b *LocalBuilder) authconfigs() map[string]types.AuthConfig {
 	return map[string]types.AuthConfig{
 		b.auth.host(): b.registryAuth(),
 	}
@@ -284,34 +443,6 @@ const (
 	NameNode       Name = "node"
 	NameDockerfile Name = "dockerfile"
 	NameShell      Name = "shell"
+	NameRuby       Name = "ruby"
+	NameJava       Name = "java"
 )
-
-func NeedsBuilding(kind api.TaskKind) (bool, error) {
-	switch Name(kind) {
-	case NameGo, NameDeno, NamePython, NameNode, NameDockerfile, NameShell:
-		return true, nil
-	case NameImage:
-		return false, nil
-	default:
-		return false, errors.Errorf("NeedsBuilding got unexpected kind %s", kind)
-	}
-}
-
-func BuildDockerfile(c DockerfileConfig) (string, error) {
-	switch Name(c.Builder) {
-	case NameGo:
-		return golang(c.Root, c.Options)
-	case NameDeno:
-		return deno(c.Root, c.Options)
-	case NamePython:
-		return python(c.Root, c.Options)
-	case NameNode:
-		return node(c.Root, c.Options)
-	case NameDockerfile:
-		return dockerfile(c.Root, c.Options)
-	case NameShell:
-		return shell(c.Root, c.Options)
-	default:
-		return "", errors.Errorf("build: unknown builder type %q", c.Builder)
-	}
-}
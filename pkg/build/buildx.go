@@ -0,0 +1,161 @@
+package build
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/airplanedev/cli/pkg/fsx"
+	"github.com/airplanedev/cli/pkg/logger"
+	"github.com/pkg/errors"
+)
+
+// buildx builds a multi-platform image with docker buildx and pushes it.
+//
+// The BuildKit builder that is embedded in dockerd can't produce manifest
+// lists, so multi-platform builds shell out to the docker CLI instead.
+func (b *LocalBuilder) buildx(ctx context.Context, contextDir, dockerfilePath, uri string, opts dockerfileOptions) (*Response, error) {
+	configDir, err := b.dockerConfig()
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(configDir)
+
+	args := []string{
+		"buildx", "build",
+		"--platform", strings.Join(b.platforms, ","),
+		"--file", filepath.Join(contextDir, dockerfilePath),
+		"--tag", uri,
+		"--progress", "plain",
+		"--push",
+		"--metadata-file", filepath.Join(configDir, "metadata.json"),
+	}
+	env := append(os.Environ(), "DOCKER_CONFIG="+configDir)
+
+	if opts.Target != "" {
+		args = append(args, "--target", opts.Target)
+	}
+
+	// Build args are read from the environment so that their values
+	// don't show up in the process list.
+	buildArgs := b.buildArgs(opts)
+	for _, k := range sortedKeys(buildArgs) {
+		args = append(args, "--build-arg", k)
+		env = append(env, k+"="+buildArgs[k])
+	}
+
+	if len(b.buildSecrets) > 0 {
+		secretsDir := filepath.Join(configDir, "secrets")
+		if err := os.Mkdir(secretsDir, 0700); err != nil {
+			return nil, errors.Wrap(err, "creating secrets directory")
+		}
+		for _, k := range sortedKeys(b.buildSecrets) {
+			src := filepath.Join(secretsDir, k)
+			if err := ioutil.WriteFile(src, []byte(b.buildSecrets[k]), 0600); err != nil {
+				return nil, errors.Wrapf(err, "writing build secret %s", k)
+			}
+			args = append(args, "--secret", "id="+k+",src="+src)
+		}
+	}
+
+	for _, ref := range b.cacheFrom {
+		args = append(args, "--cache-from", "type=registry,ref="+ref)
+	}
+	if b.cacheTo != "" {
+		args = append(args, "--cache-to", "type=registry,ref="+b.cacheTo+",mode=max")
+	}
+	args = append(args, contextDir)
+
+	logger.Debug("Running docker %s", strings.Join(args, " "))
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Env = env
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		logger.Suggest(
+			"Multi-platform builds require a docker buildx builder that supports them:",
+			"docker buildx create --use",
+		)
+		return nil, errors.Wrap(err, "docker buildx build")
+	}
+
+	resp := &Response{
+		ImageURL: uri,
+	}
+	if buf, err := ioutil.ReadFile(filepath.Join(configDir, "metadata.json")); err != nil {
+		logger.Debug("reading build metadata: %s", err)
+	} else {
+		var metadata struct {
+			Digest string `json:"containerimage.digest"`
+		}
+		if err := json.Unmarshal(buf, &metadata); err != nil {
+			logger.Debug("unmarshalling build metadata: %s", err)
+		}
+		resp.Digest = metadata.Digest
+	}
+
+	return resp, nil
+}
+
+// dockerConfig creates a temporary docker config directory that is
+// authenticated with the Airplane registry.
+//
+// The user's CLI plugins and buildx builders remain available.
+func (b *LocalBuilder) dockerConfig() (string, error) {
+	dir, err := ioutil.TempDir("", "airplane-docker-")
+	if err != nil {
+		return "", errors.Wrap(err, "creating docker config directory")
+	}
+
+	auth := b.registryAuth()
+	config, err := json.Marshal(map[string]interface{}{
+		"auths": map[string]interface{}{
+			b.auth.host(): map[string]string{
+				"auth": base64.StdEncoding.EncodeToString([]byte(auth.Username + ":" + auth.Password)),
+			},
+		},
+	})
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", errors.Wrap(err, "marshalling docker config")
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "config.json"), config, 0600); err != nil {
+		os.RemoveAll(dir)
+		return "", errors.Wrap(err, "writing docker config")
+	}
+
+	userDir := os.Getenv("DOCKER_CONFIG")
+	if userDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			os.RemoveAll(dir)
+			return "", errors.Wrap(err, "getting home directory")
+		}
+		userDir = filepath.Join(home, ".docker")
+	}
+	for _, name := range []string{"cli-plugins", "buildx"} {
+		if src := filepath.Join(userDir, name); fsx.Exists(src) {
+			if err := os.Symlink(src, filepath.Join(dir, name)); err != nil {
+				os.RemoveAll(dir)
+				return "", errors.Wrapf(err, "linking docker %s", name)
+			}
+		}
+	}
+
+	return dir, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
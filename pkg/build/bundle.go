@@ -0,0 +1,188 @@
+package build
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/airplanedev/cli/pkg/api"
+	"github.com/airplanedev/cli/pkg/fsx"
+)
+
+// ESBuildVersion is the version of esbuild that bundles Node tasks.
+const ESBuildVersion = "0.12.15"
+
+// IsBundle returns true if a Node task with the given options is bundled
+// into a single file with esbuild, instead of shipping its sources and
+// node_modules.
+func IsBundle(options api.KindOptions) bool {
+	return isTrue(options["bundle"])
+}
+
+// BundleExternals returns the modules that are excluded from the bundle of
+// a Node task, f.e. native modules. They are loaded from node_modules.
+func BundleExternals(options api.KindOptions) []string {
+	return stringSlice(options["externals"])
+}
+
+// ESBuildArgs returns the esbuild arguments that bundle entrypoint, and
+// everything it imports, into outfile. If tsconfig is empty, esbuild looks
+// up the closest tsconfig.json of the entrypoint.
+func ESBuildArgs(entrypoint, tsconfig, outfile string, options api.KindOptions) []string {
+	nodeVersion, _ := options["nodeVersion"].(string)
+	if nodeVersion == "" {
+		nodeVersion = "16"
+	}
+	major := strings.SplitN(nodeVersion, ".", 2)[0]
+
+	args := []string{
+		entrypoint,
+		"--bundle",
+		"--platform=node",
+		"--target=node" + major,
+		"--outfile=" + outfile,
+	}
+	if tsconfig != "" {
+		args = append(args, "--tsconfig="+tsconfig)
+	}
+	for _, ext := range BundleExternals(options) {
+		args = append(args, "--external:"+ext)
+	}
+	return args
+}
+
+// nodeBundle creates a dockerfile for a bundled Node task.
+//
+// Dependencies are installed and the task is bundled in a builder stage, the
+// final image only contains the bundle. If the task has externals, the
+// node_modules they are loaded from are copied over as well.
+func nodeBundle(root string, options api.KindOptions, shim bool) (string, error) {
+	entrypoint, _ := options["entrypoint"].(string)
+	if err := fsx.AssertExistsAll(filepath.Join(root, entrypoint)); err != nil {
+		return "", err
+	}
+
+	workdir, _ := options["workdir"].(string)
+	workdir = path.Join("/", workdir)
+	project, err := getNodeProject(root, workdir)
+	if err != nil {
+		return "", err
+	}
+
+	nodeVersion, _ := options["nodeVersion"].(string)
+	base, err := getBaseNodeImage(nodeVersion)
+	if err != nil {
+		return "", err
+	}
+
+	cfg := struct {
+		Base           string
+		Workdir        string
+		NPMCacheMount  string
+		ESBuildVersion string
+		InstallFiles   []string
+		InstallCommand string
+		BuildCommand   string
+		InlineShim     string
+		InlineTSConfig string
+		ESBuild        string
+		NodeModules    []string
+		NodePath       string
+		Bundle         string
+	}{
+		Base:           base,
+		Workdir:        workdir,
+		NPMCacheMount:  npmCacheMount,
+		ESBuildVersion: ESBuildVersion,
+	}
+
+	if project.HasPackageJSON {
+		for _, f := range project.Files {
+			cfg.InstallFiles = append(cfg.InstallFiles, jsonArray(f, path.Join("/airplane", f)))
+		}
+		cfg.InstallCommand = project.InstallCommand(path.Join("/airplane", project.InstallDir))
+	}
+
+	var args []string
+	if shim {
+		shim, err := NodeShim(entrypoint)
+		if err != nil {
+			return "", err
+		}
+		cfg.InlineShim = inlineString(shim)
+
+		tsconfig, err := GenTSConfig(root, filepath.Join(root, entrypoint), options)
+		if err != nil {
+			return "", err
+		}
+		cfg.InlineTSConfig = inlineString(string(tsconfig))
+
+		cfg.Bundle = "/airplane/.airplane/dist/.airplane/shim.js"
+		args = ESBuildArgs("/airplane/.airplane/shim.ts", "/airplane/.airplane/tsconfig.json", cfg.Bundle, options)
+	} else {
+		cfg.BuildCommand, _ = options["buildCommand"].(string)
+		cfg.Bundle = "/airplane/.airplane/dist/index.js"
+		args = ESBuildArgs(path.Join("/airplane", entrypoint), "", cfg.Bundle, options)
+	}
+	cfg.ESBuild = jsonArray(append([]string{"esbuild"}, args...)...)
+
+	if len(BundleExternals(options)) > 0 {
+		// Externals are resolved relative to the bundle, from the node_modules
+		// of the root, or through NODE_PATH from the node_modules of the task.
+		installModules := path.Join("/airplane", project.InstallDir, "node_modules")
+		cfg.NodeModules = append(cfg.NodeModules, installModules)
+		if workdirModules := path.Join("/airplane", workdir, "node_modules"); workdirModules != installModules {
+			cfg.NodeModules = append(cfg.NodeModules, workdirModules)
+			cfg.NodePath = workdirModules
+		}
+	}
+
+	return applyTemplate(heredoc.Doc(`
+		# syntax=docker/dockerfile:1.2
+		FROM {{.Base}} AS builder
+
+		WORKDIR /airplane{{.Workdir}}
+
+		RUN {{.NPMCacheMount}} npm install -g esbuild@{{.ESBuildVersion}}
+
+		{{if .InstallCommand}}
+		{{range .InstallFiles}}
+		COPY {{.}}
+		{{end}}
+		# Support setting BUILD_NPM_RC or BUILD_NPM_TOKEN to configure private registry auth
+		RUN {{.InstallCommand}}
+		{{end}}
+
+		COPY . /airplane
+
+		{{if .BuildCommand}}
+		RUN {{.BuildCommand}}
+		{{end}}
+
+		{{if .InlineShim}}
+		RUN mkdir -p /airplane/.airplane && \
+			{{.InlineShim}} > /airplane/.airplane/shim.ts && \
+			{{.InlineTSConfig}} > /airplane/.airplane/tsconfig.json
+		{{end}}
+
+		RUN {{.ESBuild}}
+
+		{{if .NodeModules}}
+		RUN mkdir -p{{range .NodeModules}} {{.}}{{end}}
+		{{end}}
+
+		FROM {{.Base}}
+
+		WORKDIR /airplane{{.Workdir}}
+
+		{{range .NodeModules}}
+		COPY --from=builder {{.}} {{.}}
+		{{end}}
+		{{if .NodePath}}
+		ENV NODE_PATH={{.NodePath}}
+		{{end}}
+		COPY --from=builder {{.Bundle}} {{.Bundle}}
+		ENTRYPOINT ["node", "{{.Bundle}}"]
+	`), cfg)
+}
@@ -0,0 +1,108 @@
+package build
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/airplanedev/cli/pkg/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestESBuildArgs(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal([]string{
+		"main.ts",
+		"--bundle",
+		"--platform=node",
+		"--target=node16",
+		"--outfile=dist/index.js",
+	}, ESBuildArgs("main.ts", "", "dist/index.js", api.KindOptions{}))
+
+	require.Equal([]string{
+		".airplane/shim.ts",
+		"--bundle",
+		"--platform=node",
+		"--target=node14",
+		"--outfile=dist/shim.js",
+		"--tsconfig=.airplane/tsconfig.json",
+		"--external:sharp",
+		"--external:pg-native",
+	}, ESBuildArgs(".airplane/shim.ts", ".airplane/tsconfig.json", "dist/shim.js", api.KindOptions{
+		"nodeVersion": "14",
+		// Kind options that come from the API are decoded from JSON:
+		"externals": []interface{}{"sharp", "pg-native"},
+	}))
+}
+
+func TestNodeBundle(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		root        string
+		options     api.KindOptions
+		bundle      string
+		nodeModules []string
+	}{
+		{
+			name:    "shim",
+			root:    "testdata/node/npm",
+			options: api.KindOptions{"shim": "true", "entrypoint": "main.ts", "bundle": true},
+			bundle:  "/airplane/.airplane/dist/.airplane/shim.js",
+		},
+		{
+			name:    "legacy",
+			root:    "testdata/node/npm",
+			options: api.KindOptions{"entrypoint": "main.ts", "language": "typescript", "bundle": true},
+			bundle:  "/airplane/.airplane/dist/index.js",
+		},
+		{
+			name: "workspace with externals",
+			root: "testdata/node/workspace",
+			options: api.KindOptions{
+				"shim":       "true",
+				"entrypoint": "packages/task/main.ts",
+				"workdir":    "/packages/task",
+				"bundle":     true,
+				"externals":  []string{"sharp"},
+			},
+			bundle: "/airplane/.airplane/dist/.airplane/shim.js",
+			nodeModules: []string{
+				"/airplane/node_modules",
+				"/airplane/packages/task/node_modules",
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+
+			dockerfile, err := BuildDockerfile(DockerfileConfig{
+				Builder: string(NameNode),
+				Root:    test.root,
+				Options: test.options,
+			})
+			require.NoError(err)
+
+			stages := strings.Split(dockerfile, "\nFROM ")
+			require.Len(stages, 3)
+			require.Contains(stages[1], "npm install -g esbuild@"+ESBuildVersion)
+			require.Contains(stages[1], `RUN ["esbuild", `)
+			require.NotContains(stages[1], "tsc --pretty")
+
+			// The final stage only contains the bundle, and the node_modules
+			// that externals are loaded from:
+			var copies []string
+			for _, instr := range instructions(stages[2]) {
+				if strings.HasPrefix(instr, "COPY ") {
+					copies = append(copies, instr)
+				}
+			}
+			var expected []string
+			for _, nm := range test.nodeModules {
+				expected = append(expected, "COPY --from=builder "+nm+" "+nm)
+			}
+			expected = append(expected, "COPY --from=builder "+test.bundle+" "+test.bundle)
+			require.Equal(expected, copies)
+			require.Contains(stages[2], `ENTRYPOINT ["node", "`+test.bundle+`"]`)
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package build
+
+// Cache mounts persist package manager caches across builds with BuildKit's
+// `--mount=type=cache`. The caches are kept by the builder and never land in
+// an image layer.
+const (
+	npmCacheMount    = "--mount=type=cache,target=/root/.npm"
+	yarnCacheMount   = "--mount=type=cache,target=/usr/local/share/.cache/yarn"
+	pnpmCacheMount   = "--mount=type=cache,target=/root/.pnpm-store"
+	pipCacheMount    = "--mount=type=cache,target=/root/.cache/pip"
+	poetryCacheMount = "--mount=type=cache,target=/root/.cache/pypoetry"
+	pipenvCacheMount = "--mount=type=cache,target=/root/.cache/pipenv"
+	goCacheMounts    = "--mount=type=cache,target=/go/pkg/mod --mount=type=cache,target=/root/.cache/go-build"
+	gradleCacheMount = "--mount=type=cache,target=/root/.gradle"
+	mavenCacheMount  = "--mount=type=cache,target=/root/.m2"
+)
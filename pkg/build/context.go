@@ -0,0 +1,243 @@
+package build
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/airplanedev/cli/pkg/api"
+	"github.com/airplanedev/cli/pkg/logger"
+	"github.com/dustin/go-humanize"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultContextWarnSize and DefaultContextMaxSize are the default
+	// size limits of a remote build's context.
+	DefaultContextWarnSize = 50 * humanize.MByte
+	DefaultContextMaxSize  = 500 * humanize.MByte
+
+	// largestContextEntries is the number of files and directories that
+	// are listed when a build context is larger than expected.
+	largestContextEntries = 10
+)
+
+// ContextOptions configures the checks of a remote build's context, which
+// run before it is uploaded.
+type ContextOptions struct {
+	// WarnSize is the size in bytes above which the largest files and
+	// directories of the context are listed, along with a warning.
+	// Zero disables the warning.
+	WarnSize uint64
+
+	// MaxSize is the size in bytes above which the build fails before
+	// anything is uploaded. Zero disables the limit.
+	MaxSize uint64
+
+	// Explain prints whether every path of the root is included in the
+	// context or excluded from it.
+	Explain bool
+}
+
+// contextEntry is a file or directory of a build context.
+type contextEntry struct {
+	// Path is the slash-separated path relative to the root.
+	Path string
+	Dir  bool
+	// Size is the size of a file, or the total size of the files within
+	// a directory.
+	Size uint64
+	// Included is false if the entry was excluded from the context.
+	Included bool
+}
+
+// buildContext summarizes the files of a build context.
+type buildContext struct {
+	// Size is the total size of the included files.
+	Size uint64
+	// Entries are the included and excluded entries, in walk order.
+	// Excluded directories are listed without their contents, and
+	// their size is only known if it was requested.
+	Entries []contextEntry
+}
+
+// analyzeContext walks root the same way that contextArchive does, and
+// returns the entries that include includes or excludes.
+//
+// Excluded directories, such as node_modules, are only walked to compute
+// their size if sizeExcluded is set, since they may be arbitrarily large.
+func analyzeContext(root string, include includeFunc, sizeExcluded bool) (buildContext, error) {
+	var bc buildContext
+	dirs := map[string]int{}
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return errors.Wrap(err, "getting context relative path")
+		}
+		entry := contextEntry{Path: filepath.ToSlash(rel), Dir: info.IsDir(), Included: true}
+		if include != nil {
+			if entry.Included, err = include(p, info); err != nil {
+				return err
+			}
+		}
+
+		switch {
+		case !entry.Included && entry.Dir:
+			if sizeExcluded {
+				if entry.Size, err = dirSize(p); err != nil {
+					return err
+				}
+			}
+			bc.Entries = append(bc.Entries, entry)
+			return filepath.SkipDir
+		case entry.Dir:
+			dirs[entry.Path] = len(bc.Entries)
+		case info.Mode().IsRegular():
+			entry.Size = uint64(info.Size())
+		}
+		bc.Entries = append(bc.Entries, entry)
+
+		if entry.Included && !entry.Dir {
+			bc.Size += entry.Size
+			for dir := path.Dir(entry.Path); dir != "."; dir = path.Dir(dir) {
+				bc.Entries[dirs[dir]].Size += entry.Size
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return buildContext{}, errors.Wrap(err, "analyzing build context")
+	}
+	return bc, nil
+}
+
+// dirSize returns the total size of the files within dir.
+func dirSize(dir string) (uint64, error) {
+	var size uint64
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += uint64(info.Size())
+		}
+		return nil
+	})
+	return size, err
+}
+
+// largest returns the n largest included files or directories of bc.
+func (bc buildContext) largest(dirs bool, n int) []contextEntry {
+	var entries []contextEntry
+	for _, e := range bc.Entries {
+		if e.Included && e.Dir == dirs {
+			entries = append(entries, e)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Size > entries[j].Size
+	})
+	if len(entries) > n {
+		entries = entries[:n]
+	}
+	return entries
+}
+
+// checkContext analyzes the build context of root and checks it against
+// the limits of opts, before it's archived and uploaded.
+func checkContext(root string, include includeFunc, opts ContextOptions) (buildContext, error) {
+	// The size of excluded entries is only printed when explaining the context:
+	bc, err := analyzeContext(root, include, opts.Explain)
+	if err != nil {
+		return buildContext{}, err
+	}
+
+	if opts.Explain {
+		explainContext(bc)
+	}
+
+	tooLarge := opts.MaxSize > 0 && bc.Size > opts.MaxSize
+	if tooLarge || (opts.WarnSize > 0 && bc.Size > opts.WarnSize) {
+		logLargestEntries(bc)
+	}
+	if tooLarge {
+		return buildContext{}, errors.Errorf(
+			"build context is %s, which exceeds the limit of %s: exclude large files with an .airplaneignore file or raise the limit with --context-max-size",
+			humanize.Bytes(bc.Size), humanize.Bytes(opts.MaxSize),
+		)
+	}
+	if opts.WarnSize > 0 && bc.Size > opts.WarnSize {
+		logger.Warning("The build context is %s, which is larger than %s. Exclude files that the task doesn't need with an .airplaneignore file.",
+			humanize.Bytes(bc.Size), humanize.Bytes(opts.WarnSize))
+	}
+	return bc, nil
+}
+
+// maxArchiveSize returns an upper bound of the size of the archive of bc,
+// which is only known once the archive was streamed.
+//
+// Every entry takes a header block, or three with a PAX header for long
+// names, and its contents are padded to the block size. Incompressible
+// contents grow slightly when they are gzipped.
+func (bc buildContext) maxArchiveSize() uint64 {
+	const block = 512
+	size := uint64(2 * block)
+	for _, e := range bc.Entries {
+		if !e.Included {
+			continue
+		}
+		size += block
+		if len(e.Path) >= 100 {
+			size += 2*block + uint64(len(e.Path))
+		}
+		if !e.Dir {
+			size += (e.Size + block - 1) / block * block
+		}
+	}
+	return size + size/1000 + 1024
+}
+
+// explainContext prints whether each entry of bc is included or excluded.
+func explainContext(bc buildContext) {
+	buildLog(api.LogLevelInfo, "Build context (%s):", humanize.Bytes(bc.Size))
+	for _, e := range bc.Entries {
+		name := e.Path
+		if e.Dir {
+			name += "/"
+		}
+		if e.Included {
+			buildLog(api.LogLevelInfo, "  %s %s %s", logger.Green("+"), name, logger.Gray(humanize.Bytes(e.Size)))
+		} else {
+			buildLog(api.LogLevelInfo, "  %s %s", logger.Red("-"), logger.Gray("%s %s", name, humanize.Bytes(e.Size)))
+		}
+	}
+}
+
+// logLargestEntries prints the largest files and directories of bc.
+func logLargestEntries(bc buildContext) {
+	for _, dirs := range []bool{false, true} {
+		entries := bc.largest(dirs, largestContextEntries)
+		if len(entries) == 0 {
+			continue
+		}
+		if dirs {
+			buildLog(api.LogLevelInfo, "Largest directories:")
+		} else {
+			buildLog(api.LogLevelInfo, "Largest files:")
+		}
+		for _, e := range entries {
+			name := e.Path
+			if e.Dir {
+				name += "/"
+			}
+			buildLog(api.LogLevelInfo, "  %8s  %s", humanize.Bytes(e.Size), name)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package build
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/airplanedev/cli/pkg/build/ignore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzeContext(t *testing.T) {
+	require := require.New(t)
+
+	root, err := ioutil.TempDir("", "airplane_context_test_*")
+	require.NoError(err)
+	defer os.RemoveAll(root)
+
+	for name, size := range map[string]int{
+		"main.ts":                    100,
+		"package.json":               20,
+		"data/a.csv":                 3000,
+		"data/raw/b.csv":             5000,
+		"node_modules/lodash/lodash": 70000,
+		"debug.log":                  400,
+	} {
+		p := filepath.Join(root, name)
+		require.NoError(os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(ioutil.WriteFile(p, []byte(strings.Repeat("x", size)), 0644))
+	}
+	require.NoError(ioutil.WriteFile(filepath.Join(root, ".airplaneignore"), []byte("*.log\n"), 0644))
+
+	include, err := ignore.Func(root)
+	require.NoError(err)
+	bc, err := analyzeContext(root, include, true)
+	require.NoError(err)
+
+	require.Equal(uint64(100+20+3000+5000+len("*.log\n")), bc.Size)
+	require.Equal([]contextEntry{
+		{Path: ".airplaneignore", Size: 6, Included: true},
+		{Path: "data", Dir: true, Size: 8000, Included: true},
+		{Path: "data/a.csv", Size: 3000, Included: true},
+		{Path: "data/raw", Dir: true, Size: 5000, Included: true},
+		{Path: "data/raw/b.csv", Size: 5000, Included: true},
+		{Path: "debug.log", Size: 400},
+		{Path: "main.ts", Size: 100, Included: true},
+		{Path: "node_modules", Dir: true, Size: 70000},
+		{Path: "package.json", Size: 20, Included: true},
+	}, bc.Entries)
+
+	require.Equal([]contextEntry{
+		{Path: "data/raw/b.csv", Size: 5000, Included: true},
+		{Path: "data/a.csv", Size: 3000, Included: true},
+	}, bc.largest(false, 2))
+	require.Equal([]contextEntry{
+		{Path: "data", Dir: true, Size: 8000, Included: true},
+		{Path: "data/raw", Dir: true, Size: 5000, Included: true},
+	}, bc.largest(true, 5))
+
+	// Excluded directories are only walked if their size is requested.
+	bc2, err := analyzeContext(root, include, false)
+	require.NoError(err)
+	require.Equal(bc.Size, bc2.Size)
+	require.Contains(bc2.Entries, contextEntry{Path: "node_modules", Dir: true})
+
+	// Excluded files don't count towards the limits.
+	_, err = checkContext(root, include, ContextOptions{WarnSize: 1000, MaxSize: 10000})
+	require.NoError(err)
+	_, err = checkContext(root, include, ContextOptions{MaxSize: 8000})
+	require.Error(err)
+	require.Contains(err.Error(), "exceeds the limit of 8.0 kB")
+
+	// The archive never exceeds its estimated maximum size.
+	var buf bytes.Buffer
+	_, err = contextArchive{root: root, include: include}.write(&buf)
+	require.NoError(err)
+	require.LessOrEqual(uint64(buf.Len()), bc.maxArchiveSize())
+}
@@ -1,16 +1,58 @@
 package build
 
 import (
+	"path"
 	"path/filepath"
 	"strings"
-	"text/template"
 
+	"github.com/MakeNowJust/heredoc"
 	"github.com/airplanedev/cli/pkg/api"
 	"github.com/airplanedev/cli/pkg/fsx"
 	"github.com/pkg/errors"
 )
 
+func init() {
+	Register(NameDeno, denoBuilder{})
+}
+
+// denoBuilder builds Deno tasks.
+type denoBuilder struct{}
+
+// Dockerfile implementation.
+func (denoBuilder) Dockerfile(root string, options api.KindOptions) (string, error) {
+	return deno(root, options)
+}
+
+// Root implementation.
+//
+// The root is the closest parent directory that contains a `deps.ts` or
+// `deno.lock`, or the directory of the entrypoint.
+func (denoBuilder) Root(path string) (string, error) {
+	return findRoot(path, "deps.ts", "deno.lock"), nil
+}
+
+// Workdir implementation.
+func (b denoBuilder) Workdir(path string) (string, error) {
+	return b.Root(path)
+}
+
+// Shim implementation.
+//
+// Deno runs the entrypoint, which reads the params from its arguments.
+func (denoBuilder) Shim(root, entrypoint string) (string, error) {
+	return "", ErrNoShim
+}
+
+// NeedsBuilding implementation.
+func (denoBuilder) NeedsBuilding() bool {
+	return true
+}
+
 // Deno creates a dockerfile for Deno.
+//
+// Remote modules are cached in a layer of their own: if the task has a
+// `deps.ts`, it is cached before the rest of the task is copied, so that
+// dependencies are only downloaded again when they change.
 func deno(root string, options api.KindOptions) (string, error) {
 	entrypoint, _ := options["entrypoint"].(string)
 	main := filepath.Join(root, entrypoint)
@@ -19,33 +61,100 @@ func deno(root string, options api.KindOptions) (string, error) {
 		return "", err
 	}
 
-	t, err := template.New("deno").Parse(`
-FROM {{ .Base }}
-WORKDIR /airplane
-ADD . .
-RUN deno cache {{ .Entrypoint }}
-USER deno
-ENTRYPOINT ["deno", "run", "-A", "{{ .Entrypoint }}"]
-	`)
+	denoVersion, _ := options["denoVersion"].(string)
+	base, err := getBaseDenoImage(denoVersion)
 	if err != nil {
-		return "", errors.Wrap(err, "new template")
+		return "", err
 	}
 
-	v, err := GetVersion(NameDeno, "1")
-	if err != nil {
-		return "", err
+	var files []string
+	var flags []string
+	if fsx.Exists(filepath.Join(root, "deno.lock")) {
+		files = append(files, "deno.lock")
+		flags = append(flags, "--lock=deno.lock")
+	}
+	if importMap, _ := options["importMap"].(string); importMap != "" {
+		if err := fsx.AssertExistsAll(filepath.Join(root, importMap)); err != nil {
+			return "", err
+		}
+		files = append(files, importMap)
+		flags = append(flags, "--import-map="+importMap)
 	}
+	var deps string
+	if fsx.Exists(filepath.Join(root, "deps.ts")) {
+		deps = "deps.ts"
+		files = append(files, deps)
+	}
+
+	cache := func(file string) string {
+		return jsonArray(append(append([]string{"deno", "cache"}, flags...), file)...)
+	}
+	run := append([]string{"deno", "run"}, denoPermissions(options)...)
+	run = append(run, flags...)
+	run = append(run, entrypoint)
 
-	var buf strings.Builder
-	if err := t.Execute(&buf, struct {
+	cfg := struct {
 		Base       string
+		Files      []string
+		CacheDeps  string
+		CacheMain  string
 		Entrypoint string
 	}{
-		Base:       v.String(),
-		Entrypoint: entrypoint,
-	}); err != nil {
+		Base:       base,
+		CacheMain:  cache(entrypoint),
+		Entrypoint: jsonArray(run...),
+	}
+	for _, f := range files {
+		cfg.Files = append(cfg.Files, jsonArray(f, path.Join("/airplane", f)))
+	}
+	if deps != "" {
+		cfg.CacheDeps = cache(deps)
+	}
+
+	return applyTemplate(heredoc.Doc(`
+		FROM {{.Base}}
+		WORKDIR /airplane
+		{{range .Files}}
+		COPY {{.}}
+		{{end}}
+		{{if .CacheDeps}}
+		RUN {{.CacheDeps}}
+		{{end}}
+		ADD . .
+		RUN {{.CacheMain}}
+		USER deno
+		ENTRYPOINT {{.Entrypoint}}
+	`), cfg)
+}
+
+// denoPermissions returns the permission flags that a Deno task runs with.
+//
+// Permissions are declared in the `permissions` option, f.e. `net=api.github.com`
+// or `env`, and passed as `--allow-net=api.github.com` and `--allow-env`. If
+// no permissions are declared, the task is granted all permissions.
+func denoPermissions(options api.KindOptions) []string {
+	permissions := stringSlice(options["permissions"])
+	if len(permissions) == 0 {
+		return []string{"-A"}
+	}
+	flags := make([]string, 0, len(permissions))
+	for _, p := range permissions {
+		flags = append(flags, "--allow-"+strings.TrimPrefix(p, "--allow-"))
+	}
+	return flags
+}
+
+func getBaseDenoImage(version string) (string, error) {
+	if version == "" {
+		version = "1"
+	}
+	base, err := getImage(NameDeno, version)
+	if err != nil {
 		return "", err
 	}
+	if base == "" {
+		return "", errors.Errorf("unsupported deno version %q", version)
+	}
 
-	return buf.String(), nil
+	return base, nil
 }
@@ -0,0 +1,50 @@
+package build
+
+import (
+	"testing"
+
+	"github.com/airplanedev/cli/pkg/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDenoDockerfile(t *testing.T) {
+	require := require.New(t)
+
+	dockerfile, err := BuildDockerfile(DockerfileConfig{
+		Builder: string(NameDeno),
+		Root:    "testdata/deno",
+		Options: api.KindOptions{
+			"entrypoint":  "main.ts",
+			"denoVersion": "1.9",
+			"importMap":   "import_map.json",
+			// Kind options that come from the API are decoded from JSON:
+			"permissions": []interface{}{"net=deno.land", "env"},
+		},
+	})
+	require.NoError(err)
+
+	v, err := GetVersion(NameDeno, "1.9")
+	require.NoError(err)
+	require.Equal([]string{
+		"FROM " + v.String(),
+		"WORKDIR /airplane",
+		`COPY ["deno.lock", "/airplane/deno.lock"]`,
+		`COPY ["import_map.json", "/airplane/import_map.json"]`,
+		`COPY ["deps.ts", "/airplane/deps.ts"]`,
+		`RUN ["deno", "cache", "--lock=deno.lock", "--import-map=import_map.json", "deps.ts"]`,
+		"ADD . .",
+		`RUN ["deno", "cache", "--lock=deno.lock", "--import-map=import_map.json", "main.ts"]`,
+		"USER deno",
+		`ENTRYPOINT ["deno", "run", "--allow-net=deno.land", "--allow-env", "--lock=deno.lock", "--import-map=import_map.json", "main.ts"]`,
+	}, instructions(dockerfile))
+
+	// Without declared permissions, tasks are granted all permissions:
+	require.Equal([]string{"-A"}, denoPermissions(api.KindOptions{}))
+
+	_, err = BuildDockerfile(DockerfileConfig{
+		Builder: string(NameDeno),
+		Root:    "testdata/deno",
+		Options: api.KindOptions{"entrypoint": "main.ts", "denoVersion": "0.1"},
+	})
+	require.Error(err)
+}
@@ -2,14 +2,59 @@ package build
 
 import (
 	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/airplanedev/cli/pkg/api"
 	"github.com/airplanedev/cli/pkg/fsx"
 	"github.com/pkg/errors"
 )
 
+func init() {
+	Register(NameDockerfile, dockerfileBuilder{})
+}
+
+// dockerfileBuilder builds tasks from a user-provided Dockerfile.
+type dockerfileBuilder struct{}
+
+// Dockerfile implementation.
+func (dockerfileBuilder) Dockerfile(root string, options api.KindOptions) (string, error) {
+	return dockerfile(root, options)
+}
+
+// Root implementation.
+//
+// The root is the closest parent directory that contains a Dockerfile.
+func (dockerfileBuilder) Root(path string) (string, error) {
+	return findRoot(path, DockerfilePaths()...), nil
+}
+
+// Workdir implementation.
+func (b dockerfileBuilder) Workdir(path string) (string, error) {
+	return b.Root(path)
+}
+
+// Shim implementation.
+func (dockerfileBuilder) Shim(root, entrypoint string) (string, error) {
+	return "", ErrNoShim
+}
+
+// NeedsBuilding implementation.
+func (dockerfileBuilder) NeedsBuilding() bool {
+	return true
+}
+
+// Dockerfile reads the user-provided Dockerfile, relative to root.
+//
+// The Dockerfile is read from root even if it's built with a `context`
+// within it, so Dockerfiles that are shared between services can be
+// built with the context of a single one.
 func dockerfile(root string, options api.KindOptions) (string, error) {
+	if _, err := getDockerfileOptions(root, options); err != nil {
+		return "", err
+	}
+
 	dockerfile, _ := options["dockerfile"].(string)
 	dockerfilePath := filepath.Join(root, dockerfile)
 	if err := fsx.AssertExistsAll(dockerfilePath); err != nil {
@@ -23,3 +68,65 @@ func dockerfile(root string, options api.KindOptions) (string, error) {
 
 	return string(contents), nil
 }
+
+// dockerfileOptions are the options of the dockerfile builder that
+// configure the build rather than the Dockerfile.
+type dockerfileOptions struct {
+	// Context is the absolute path of the build context.
+	Context string
+
+	// Target is the stage of a multi-stage Dockerfile to build. If empty,
+	// the last stage is built.
+	Target string
+
+	// BuildArgs are passed to the build as build args.
+	BuildArgs map[string]string
+}
+
+// getDockerfileOptions returns the build options of a dockerfile task at
+// root with the given kind options.
+//
+// The `context` option is a directory within root, which defaults to
+// root itself.
+func getDockerfileOptions(root string, options api.KindOptions) (dockerfileOptions, error) {
+	opts := dockerfileOptions{
+		Context:   root,
+		BuildArgs: stringMap(options["buildArgs"]),
+	}
+	opts.Target, _ = options["target"].(string)
+
+	if context, _ := options["context"].(string); context != "" {
+		if filepath.IsAbs(context) {
+			return dockerfileOptions{}, errors.Errorf("context must be a path relative to %s, got %s", root, context)
+		}
+		dir := filepath.Join(root, context)
+		if rel, err := filepath.Rel(root, dir); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return dockerfileOptions{}, errors.Errorf("context %s must be within %s", context, root)
+		}
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return dockerfileOptions{}, errors.Errorf("context %s is not a directory in %s", context, root)
+		}
+		opts.Context = dir
+	}
+
+	return opts, nil
+}
+
+// stringMap returns v as a map of strings, f.e. a map kind option that was
+// decoded from JSON.
+func stringMap(v interface{}) map[string]string {
+	switch v := v.(type) {
+	case map[string]string:
+		return v
+	case map[string]interface{}:
+		m := make(map[string]string, len(v))
+		for k, e := range v {
+			if str, ok := e.(string); ok {
+				m[k] = str
+			}
+		}
+		return m
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,78 @@
+package build
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/airplanedev/cli/pkg/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDockerfile(t *testing.T) {
+	require := require.New(t)
+	root, err := filepath.Abs("testdata/dockerfile")
+	require.NoError(err)
+
+	df, err := BuildDockerfile(DockerfileConfig{
+		Builder: string(NameDockerfile),
+		Root:    root,
+		Options: api.KindOptions{
+			"dockerfile": "Dockerfile",
+			"context":    "service",
+		},
+	})
+	require.NoError(err)
+	require.Contains(df, "FROM base as task")
+
+	_, err = BuildDockerfile(DockerfileConfig{
+		Builder: string(NameDockerfile),
+		Root:    root,
+		Options: api.KindOptions{"dockerfile": "Dockerfile.missing"},
+	})
+	require.Error(err)
+}
+
+func TestDockerfileOptions(t *testing.T) {
+	require := require.New(t)
+	root, err := filepath.Abs("testdata/dockerfile")
+	require.NoError(err)
+
+	opts, err := getDockerfileOptions(root, api.KindOptions{"dockerfile": "Dockerfile"})
+	require.NoError(err)
+	require.Equal(dockerfileOptions{Context: root}, opts)
+
+	// Options read from the API are decoded from JSON:
+	opts, err = getDockerfileOptions(root, api.KindOptions{
+		"dockerfile": "Dockerfile",
+		"buildArgs":  map[string]interface{}{"VARIANT": "task"},
+		"target":     "task",
+		"context":    "service/",
+	})
+	require.NoError(err)
+	require.Equal(dockerfileOptions{
+		Context:   filepath.Join(root, "service"),
+		Target:    "task",
+		BuildArgs: map[string]string{"VARIANT": "task"},
+	}, opts)
+
+	for _, context := range []string{"..", "../secrets", "service/../..", "/tmp", "missing", "Dockerfile"} {
+		_, err := getDockerfileOptions(root, api.KindOptions{
+			"dockerfile": "Dockerfile",
+			"context":    context,
+		})
+		require.Error(err, context)
+	}
+}
+
+func TestBuildArgs(t *testing.T) {
+	require := require.New(t)
+
+	b := &LocalBuilder{buildEnv: map[string]string{"VARIANT": "env", "TOKEN": "secret"}}
+	require.Equal(map[string]string{
+		"VARIANT": "env",
+		"TOKEN":   "secret",
+		"DEBUG":   "1",
+	}, b.buildArgs(dockerfileOptions{
+		BuildArgs: map[string]string{"VARIANT": "task", "DEBUG": "1"},
+	}))
+}
@@ -3,14 +3,60 @@ package build
 import (
 	"path/filepath"
 	"strings"
-	"text/template"
 
+	"github.com/MakeNowJust/heredoc"
 	"github.com/airplanedev/cli/pkg/api"
 	"github.com/airplanedev/cli/pkg/fsx"
 	"github.com/pkg/errors"
 )
 
+func init() {
+	Register(NameGo, goBuilder{})
+}
+
+// goBuilder builds Go tasks.
+type goBuilder struct{}
+
+// Dockerfile implementation.
+func (goBuilder) Dockerfile(root string, options api.KindOptions) (string, error) {
+	return golang(root, options)
+}
+
+// Root implementation.
+//
+// The root is the closest parent directory that contains a go.mod.
+func (goBuilder) Root(path string) (string, error) {
+	return findRoot(path, "go.mod"), nil
+}
+
+// Workdir implementation.
+func (b goBuilder) Workdir(path string) (string, error) {
+	return b.Root(path)
+}
+
+// Shim implementation.
+//
+// Go tasks are compiled into a binary that receives the params as its
+// arguments.
+func (goBuilder) Shim(root, entrypoint string) (string, error) {
+	return "", ErrNoShim
+}
+
+// NeedsBuilding implementation.
+func (goBuilder) NeedsBuilding() bool {
+	return true
+}
+
 // Golang creates a dockerfile for Go.
+//
+// The task is compiled in a builder stage and the binary is copied into a
+// minimal final stage, distroless by default.
+//
+// The binary doesn't need a shim: the shims of other builders are an
+// exec-form ENTRYPOINT that reads the JSON params from its first argument,
+// and the binary is an exec-form ENTRYPOINT without a CMD too. The task's
+// arguments are therefore passed to it unchanged, and it reads the same JSON
+// params from os.Args[1].
 func golang(root string, options api.KindOptions) (string, error) {
 	gomod := filepath.Join(root, "go.mod")
 	gosum := filepath.Join(root, "go.sum")
@@ -21,45 +67,125 @@ func golang(root string, options api.KindOptions) (string, error) {
 		return "", err
 	}
 
-	t, err := template.New("golang").Parse(`
-FROM {{ .Base }} as builder
+	v, err := GetVersion(NameGo, "1")
+	if err != nil {
+		return "", err
+	}
 
-WORKDIR /airplane
+	cgo := isTrue(options["cgo"])
+	base, err := getGoRuntimeImage(options, cgo)
+	if err != nil {
+		return "", err
+	}
 
-COPY go.mod {{ if .HasGoSum -}} go.sum {{ end -}} ./
-RUN go mod download
+	build := []string{"go", "build", "-o", "/bin/main"}
+	if tags := stringSlice(options["tags"]); len(tags) > 0 {
+		build = append(build, "-tags", strings.Join(tags, ","))
+	}
+	if ldflags, _ := options["ldflags"].(string); ldflags != "" {
+		build = append(build, "-ldflags", ldflags)
+	}
+	build = append(build, filepath.Join("/airplane", entrypoint))
 
-COPY . .
+	cgoEnabled := "0"
+	if cgo {
+		cgoEnabled = "1"
+	}
 
-RUN ["go", "build", "-o", "/bin/main", "{{ .Entrypoint }}"]
+	return applyTemplate(heredoc.Doc(`
+		# syntax=docker/dockerfile:1.2
+		FROM {{.Builder}} as builder
 
-FROM {{ .Base }}
+		WORKDIR /airplane
+		ENV CGO_ENABLED={{.CGOEnabled}}
 
-COPY --from=builder /bin/main /bin/main
+		COPY go.mod {{if .HasGoSum}}go.sum {{end}}./
+		# Support setting BUILD_GOPRIVATE or BUILD_NETRC to configure private modules
+		RUN {{.Download}}
 
-ENTRYPOINT ["/bin/main"]
-`)
-	if err != nil {
-		return "", errors.Wrap(err, "parse template")
+		COPY . .
+
+		# The secrets are needed here too, in case the module cache is empty
+		# while the download above is cached, f.e. with --cache-from.
+		RUN {{.CacheMounts}} {{.Build}}
+
+		FROM {{.Base}}
+
+		{{if .Scratch}}
+		COPY --from=builder /etc/ssl/certs/ca-certificates.crt /etc/ssl/certs/
+		{{end}}
+		COPY --from=builder /bin/main /bin/main
+
+		ENTRYPOINT ["/bin/main"]
+	`), struct {
+		Builder     string
+		Base        string
+		Scratch     bool
+		CGOEnabled  string
+		HasGoSum    bool
+		CacheMounts string
+		Download    string
+		Build       string
+	}{
+		Builder:     v.String(),
+		Base:        base,
+		Scratch:     base == "scratch",
+		CGOEnabled:  cgoEnabled,
+		HasGoSum:    fsx.AssertExistsAll(gosum) == nil,
+		CacheMounts: goCacheMounts,
+		Download:    goCacheMounts + " " + withGoSecrets("go mod download"),
+		Build:       withGoSecrets(shellJoin(build...)),
+	})
+}
+
+// getGoRuntimeImage returns the image that the binary of a Go task runs in.
+//
+// Static binaries run in distroless/static, binaries that link against libc
+// with CGO run in distroless/base. The image can be overridden with the
+// `baseImage` option, f.e. `scratch`.
+func getGoRuntimeImage(options api.KindOptions, cgo bool) (string, error) {
+	if base, _ := options["baseImage"].(string); base != "" {
+		if base == "scratch" && cgo {
+			return "", errors.New("cgo binaries cannot run in a scratch image: set a baseImage that provides libc")
+		}
+		return base, nil
 	}
 
-	v, err := GetVersion(NameGo, "1")
-	if err != nil {
-		return "", err
+	version := "distroless/static"
+	if cgo {
+		version = "distroless/base"
 	}
+	return getImage(NameGo, version)
+}
 
-	var buf strings.Builder
-	if err := t.Execute(&buf, struct {
-		Base       string
-		Entrypoint string
-		HasGoSum   bool
-	}{
-		Base:       v.String(),
-		Entrypoint: filepath.Join("/airplane", entrypoint),
-		HasGoSum:   fsx.AssertExistsAll(gosum) == nil,
-	}); err != nil {
-		return "", err
+// isTrue returns true if v is a boolean kind option that is set, f.e. from a
+// task definition or decoded from JSON.
+func isTrue(v interface{}) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	default:
+		return false
 	}
+}
 
-	return buf.String(), nil
+// stringSlice returns v as a list of strings, f.e. a list kind option that
+// was decoded from JSON.
+func stringSlice(v interface{}) []string {
+	switch v := v.(type) {
+	case []string:
+		return v
+	case []interface{}:
+		var s []string
+		for _, e := range v {
+			if str, ok := e.(string); ok {
+				s = append(s, str)
+			}
+		}
+		return s
+	default:
+		return nil
+	}
 }
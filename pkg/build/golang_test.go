@@ -0,0 +1,123 @@
+package build
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/airplanedev/cli/pkg/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoDockerfile(t *testing.T) {
+	useTestVersions(t)
+	for _, test := range []struct {
+		name    string
+		options api.KindOptions
+		build   string
+		cgo     string
+		base    string
+		err     bool
+	}{
+		{
+			name:    "defaults",
+			options: api.KindOptions{"entrypoint": "main.go"},
+			build:   "go build -o /bin/main /airplane/main.go",
+			cgo:     "0",
+			base:    "distroless/static",
+		},
+		{
+			name: "tags and ldflags",
+			options: api.KindOptions{
+				"entrypoint": "main.go",
+				// Kind options that come from the API are decoded from JSON:
+				"tags":    []interface{}{"prod", "netgo"},
+				"ldflags": "-s -w -X main.version=1",
+			},
+			build: "go build -o /bin/main -tags prod,netgo -ldflags '-s -w -X main.version=1' /airplane/main.go",
+			cgo:   "0",
+			base:  "distroless/static",
+		},
+		{
+			name:    "cgo",
+			options: api.KindOptions{"entrypoint": "main.go", "cgo": true},
+			build:   "go build -o /bin/main /airplane/main.go",
+			cgo:     "1",
+			base:    "distroless/base",
+		},
+		{
+			name:    "scratch",
+			options: api.KindOptions{"entrypoint": "main.go", "baseImage": "scratch"},
+			build:   "go build -o /bin/main /airplane/main.go",
+			cgo:     "0",
+			base:    "scratch",
+		},
+		{
+			name:    "cgo in scratch",
+			options: api.KindOptions{"entrypoint": "main.go", "cgo": true, "baseImage": "scratch"},
+			err:     true,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+
+			dockerfile, err := BuildDockerfile(DockerfileConfig{
+				Builder: string(NameGo),
+				Root:    "testdata/secrets/go",
+				Options: test.options,
+			})
+			if test.err {
+				require.Error(err)
+				return
+			}
+			require.NoError(err)
+
+			stages := strings.Split(dockerfile, "\nFROM ")
+			require.Len(stages, 3)
+			require.Contains(stages[1], "ENV CGO_ENABLED="+test.cgo)
+			require.Contains(stages[1], test.build)
+
+			// The binary is the entrypoint, so that it receives the task's
+			// arguments without a shell in between:
+			// Bases that are listed in versions.json are pinned:
+			base := test.base
+			if v, err := GetVersion(NameGo, base); err == nil && v.Image != "" {
+				base = v.String()
+			}
+			final := instructions("FROM " + stages[2])
+			require.Equal("FROM "+base, final[0])
+			require.Equal(`ENTRYPOINT ["/bin/main"]`, final[len(final)-1])
+			require.Contains(final, "COPY --from=builder /bin/main /bin/main")
+			for _, instr := range final {
+				require.False(strings.HasPrefix(instr, "RUN "), instr)
+				// A CMD would replace the arguments, and thereby the params,
+				// that the task is run with:
+				require.False(strings.HasPrefix(instr, "CMD "), instr)
+			}
+		})
+	}
+}
+
+func TestGoRuntimeImages(t *testing.T) {
+	require := require.New(t)
+
+	// Go tasks run on the distroless images of versions.json, pinned to
+	// their digests rather than the moving `latest` tag:
+	for _, cgo := range []bool{false, true} {
+		dockerfile, err := BuildDockerfile(DockerfileConfig{
+			Builder: string(NameGo),
+			Root:    "testdata/secrets/go",
+			Options: api.KindOptions{"entrypoint": "main.go", "cgo": cgo},
+		})
+		require.NoError(err, "cgo=%t", cgo)
+
+		name := "distroless/static"
+		if cgo {
+			name = "distroless/base"
+		}
+		v, err := GetVersion(NameGo, name)
+		require.NoError(err)
+		require.NotEmpty(v.Digest, name)
+		final := instructions("FROM " + strings.Split(dockerfile, "\nFROM ")[2])
+		require.Equal("FROM "+v.String(), final[0])
+	}
+}
@@ -3,149 +3,214 @@ package ignore
 import (
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/airplanedev/cli/pkg/logger"
 	"github.com/pkg/errors"
-	gitignore "github.com/sabhiram/go-gitignore"
 )
 
-// Returns an IgnoreFunc that can be used with airplanedev/archiver to filter
-// out files that match a default list or user-provided .airplaneignore.
-func Func(taskRootPath string) (func(filePath string, info os.FileInfo) (bool, error), error) {
-	excludes, err := Patterns(taskRootPath)
-	if err != nil {
+// Filename is the name of the files that declare which files are left out
+// of build contexts, in addition to the default excludes. Like .gitignore
+// files, they can be placed in any directory of the root.
+const Filename = ".airplaneignore"
+
+// Default set of excludes.
+// We exclude the same files regardless of kind because you might have both JS and PY tasks and
+// want pyc files excluded just the same.
+// For inspiration, see:
+// https://github.com/github/gitignore
+// https://github.com/github/gitignore/blob/master/Go.gitignore
+// https://github.com/github/gitignore/blob/master/Node.gitignore
+// https://vercel.com/docs/build-step#ignored-files-and-folders
+var defaultExcludes = []string{
+	".env.local",
+	".env.*.local",
+	"*.pyc",
+	".git",
+	".gitmodules",
+	".hg",
+	".idea",
+	".next",
+	".now",
+	".npm",
+	".svn",
+	".*.swp",
+	".terraform",
+	".venv",
+	".vercel",
+	// Yarn 2+ keeps its release and plugins in .yarn, which are needed
+	// to install dependencies.
+	".yarn/cache",
+	".yarn/unplugged",
+	".yarn/build-state.yml",
+	".yarn/install-state.gz",
+	".pnp.*",
+	"__pycache__",
+	"node_modules",
+	"npm-debug.log",
+	// Local build artifacts created by `airplane dev`.
+	".airplane",
+}
+
+// Matcher decides which files of a root are excluded from its build
+// context. It follows the semantics of .gitignore files:
+//
+//   - The default excludes apply first, followed by the .airplaneignore
+//     files from the root down to the file's directory. The last pattern
+//     that matches a path decides, so users can re-include files with `!`.
+//   - Patterns of nested .airplaneignore files are relative to their
+//     directory.
+//   - Files within an excluded directory can't be re-included, so
+//     excluded directories are never walked.
+//
+// Local and remote builds use the same matcher, so they include the
+// same files.
+type Matcher struct {
+	root string
+
+	mu sync.Mutex
+	// patterns are the patterns of the ignore file of each directory,
+	// keyed by its slash-separated path relative to the root.
+	patterns map[string][]pattern
+}
+
+// NewMatcher returns a matcher of the files in root.
+func NewMatcher(root string) (*Matcher, error) {
+	m := &Matcher{root: root, patterns: map[string][]pattern{}}
+	if _, err := m.dirPatterns("."); err != nil {
 		return nil, err
 	}
-	logger.Debug("Excludes:\n  %s", strings.Join(excludes, "\n  "))
-
-	ig := gitignore.CompileIgnoreLines(excludes...)
-	hasInclusion := false
-	for _, pat := range excludes {
-		if strings.HasPrefix(pat, "!") {
-			hasInclusion = true
-			break
+	return m, nil
+}
+
+// Excluded reports whether the slash-separated path rel, relative to the
+// root, is excluded. Paths are excluded if one of their parent directories
+// is excluded.
+func (m *Matcher) Excluded(rel string, isDir bool) (bool, error) {
+	rel = path.Clean(rel)
+	if rel == "." {
+		return false, nil
+	}
+	// Check parents first: their contents are never re-included.
+	var parents []string
+	for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+		parents = append([]string{dir}, parents...)
+	}
+	for _, dir := range parents {
+		if excluded, err := m.match(dir, true); err != nil || excluded {
+			return excluded, err
 		}
 	}
+	return m.match(rel, isDir)
+}
 
-	return func(filePath string, info os.FileInfo) (bool, error) {
-		// Ignore symbolic links. For example, in Node projects you occasionally see
-		// symbolic links to binaries like `.bin/foobar`  which don't exist.
-		if info.Mode()&os.ModeSymlink == os.ModeSymlink {
-			return false, nil
+// match reports whether rel is excluded by the patterns that apply to it,
+// assuming its parents are included.
+func (m *Matcher) match(rel string, isDir bool) (bool, error) {
+	// The patterns of the root, followed by those of nested directories.
+	dirs := []string{"."}
+	if d := path.Dir(rel); d != "." {
+		parts := strings.Split(d, "/")
+		for i := range parts {
+			dirs = append(dirs, strings.Join(parts[:i+1], "/"))
 		}
+	}
 
-		relFilePath, err := filepath.Rel(taskRootPath, filePath)
+	var excluded bool
+	for _, dir := range dirs {
+		patterns, err := m.dirPatterns(dir)
 		if err != nil {
-			return false, errors.Wrap(err, "getting archive relative path")
+			return false, err
 		}
+		for _, p := range patterns {
+			if p.match(rel, isDir) {
+				excluded = !p.negate
+			}
+		}
+	}
+	return excluded, nil
+}
 
-		skip := ig.MatchesPath(relFilePath)
+// dirPatterns returns the patterns that the ignore file of dir declares.
+// The patterns of the root start with the default excludes.
+func (m *Matcher) dirPatterns(dir string) ([]pattern, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if patterns, ok := m.patterns[dir]; ok {
+		return patterns, nil
+	}
 
-		// If we want to skip this file, and it's a directory, then we can skip it only if there
-		// are no inclusions.
-		// Ideally, we are smarter about this and skip the dir if there's no way for the inclusion
-		// pattern to match the dir. However, this is tricky with things like "!foo/" matching
-		// any directory named foo/ inside this directory.
-		if info.IsDir() && skip && !hasInclusion {
-			return false, nil
-		}
+	var lines []string
+	if dir == "." {
+		lines = append(lines, defaultExcludes...)
+	}
+	fileLines, err := readIgnoreFile(filepath.Join(m.root, filepath.FromSlash(dir)))
+	if err != nil {
+		return nil, err
+	}
+	lines = append(lines, fileLines...)
 
-		if !skip {
-			logger.Debug("Including in build archive: %s", relFilePath)
+	var patterns []pattern
+	for _, line := range lines {
+		p, ok, err := parsePattern(dir, line)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing %s", path.Join(dir, Filename))
+		}
+		if ok {
+			patterns = append(patterns, p)
 		}
-		return !skip, nil
-	}, nil
-}
-
-func Patterns(path string) ([]string, error) {
-	// Start with default set of excludes.
-	// We exclude the same files regardless of kind because you might have both JS and PY tasks and
-	// want pyc files excluded just the same.
-	// For inspiration, see:
-	// https://github.com/github/gitignore
-	// https://github.com/github/gitignore/blob/master/Go.gitignore
-	// https://github.com/github/gitignore/blob/master/Node.gitignore
-	// https://vercel.com/docs/build-step#ignored-files-and-folders
-	excludes := []string{
-		".env.local",
-		".env.*.local",
-		"*.pyc",
-		".git",
-		".gitmodules",
-		".hg",
-		".idea",
-		".next",
-		".now",
-		".npm",
-		".svn",
-		".*.swp",
-		".terraform",
-		".venv",
-		".vercel",
-		".yarn",
-		"__pycache__",
-		"node_modules",
-		"npm-debug.log",
-		// Local build artifacts created by `airplane dev`.
-		".airplane",
 	}
+	m.patterns[dir] = patterns
+	return patterns, nil
+}
 
-	// Allow user-specified ignore file. Note that users can re-INCLUDE files using !, so if our
-	// default excludes skip something necessary they can always add it back.
-	const ignorefile = ".airplaneignore"
-	bs, err := ioutil.ReadFile(filepath.Join(path, ignorefile))
+// readIgnoreFile returns the lines of the ignore file in dir, if any.
+func readIgnoreFile(dir string) ([]string, error) {
+	bs, err := ioutil.ReadFile(filepath.Join(dir, Filename))
 	switch {
 	case os.IsNotExist(err):
-		// Nothing additional to append
-		return excludes, nil
+		return nil, nil
 	case err != nil:
-		return nil, errors.Wrap(err, "opening "+ignorefile)
+		return nil, errors.Wrap(err, "opening "+Filename)
 	}
-	fileExcludes := []string{}
-	for _, ex := range strings.Split(string(bs), "\n") {
-		if ex != "" {
-			fileExcludes = append(fileExcludes, ex)
+	var lines []string
+	for _, line := range strings.Split(string(bs), "\n") {
+		if line != "" {
+			lines = append(lines, line)
 		}
 	}
-	logger.Debug("Found %s - using %d exclude rule(s):\n  %s", ignorefile, len(fileExcludes), strings.Join(fileExcludes, "\n  "))
-	excludes = append(excludes, fileExcludes...)
-	return excludes, nil
+	logger.Debug("Found %s - using %d exclude rule(s):\n  %s", filepath.Join(dir, Filename), len(lines), strings.Join(lines, "\n  "))
+	return lines, nil
 }
 
-// DockerignorePatterns returns the ignore patterns formatted according to
-// the .dockerignore format.
-func DockerignorePatterns(path string) ([]string, error) {
-	patterns, err := Patterns(path)
+// Returns an IgnoreFunc that can be used when archiving a build context to filter
+// out files that match a default list or user-provided .airplaneignore files.
+//
+// Excluded directories are skipped along with their contents, so the function
+// is only called for paths whose parent directories are included. Symbolic
+// links are matched like files, and archived as links rather than followed.
+func Func(taskRootPath string) (func(filePath string, info os.FileInfo) (bool, error), error) {
+	m, err := NewMatcher(taskRootPath)
 	if err != nil {
 		return nil, err
 	}
 
-	diPatterns := []string{}
-	for _, p := range patterns {
-		diPatterns = append(diPatterns, toDockerignore(p))
-	}
-
-	return diPatterns, nil
-}
-
-// toDockerIgnore converts from .airplaneignore format to .dockerignore
-// format. It is based on:
-// https://github.com/LinusU/gitignore-to-dockerignore/blob/master/index.js
-func toDockerignore(g string) string {
-	g = strings.TrimSpace(g)
+	return func(filePath string, info os.FileInfo) (bool, error) {
+		relFilePath, err := filepath.Rel(taskRootPath, filePath)
+		if err != nil {
+			return false, errors.Wrap(err, "getting archive relative path")
+		}
 
-	switch {
-	case g == "":
-		return ""
-	case strings.HasPrefix(g, "!/"):
-		return "!" + g[2:]
-	case strings.HasPrefix(g, "!"):
-		return "!**/" + g[1:]
-	case strings.HasPrefix(g, "/"):
-		return g[1:]
-	default:
-		return "**/" + g
-	}
+		skip, err := m.match(filepath.ToSlash(relFilePath), info.IsDir())
+		if err != nil {
+			return false, err
+		}
+		if !skip {
+			logger.Debug("Including in build archive: %s", relFilePath)
+		}
+		return !skip, nil
+	}, nil
 }
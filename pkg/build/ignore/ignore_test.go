@@ -1,24 +1,301 @@
 package ignore
 
 import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 )
 
-func TestDockerignorePatterns(tt *testing.T) {
-	for _, test := range []struct {
-		In  string
-		Out string
-	}{
-		{"", ""},
-		{"node_modules", "**/node_modules"},
-		{"!node_modules", "!**/node_modules"},
-		{"/node_modules", "node_modules"},
-		{"!/node_modules", "!node_modules"},
-	} {
-		tt.Run(test.In, func(t *testing.T) {
-			require.Equal(t, test.Out, toDockerignore(test.In))
+// ignoreTests describe trees and the files of them that are included in
+// build contexts. The same cases check the matcher, the walk of archives
+// and, if it's installed, git.
+var ignoreTests = []struct {
+	name string
+	// files maps the slash-separated paths of the tree to their contents.
+	files    map[string]string
+	included []string
+}{
+	{
+		name: "default excludes",
+		files: map[string]string{
+			"main.py":                 "",
+			"lib/util.py":             "",
+			"lib/util.pyc":            "",
+			"__pycache__/main.pyc":    "",
+			"node_modules/a/index.js": "",
+			"web/node_modules/b.js":   "",
+			".env.local":              "",
+			".env.production.local":   "",
+			".env":                    "",
+			".yarn/cache/a.zip":       "",
+			".yarn/releases/yarn.cjs": "",
+			"web/.yarn/cache/b.zip":   "",
+		},
+		included: []string{
+			".env",
+			".yarn/releases/yarn.cjs",
+			"lib/util.py",
+			"main.py",
+			"web/.yarn/cache/b.zip",
+		},
+	},
+	{
+		name: "negations",
+		files: map[string]string{
+			Filename:        "*.log\n!keep.log\n",
+			"a.log":         "",
+			"keep.log":      "",
+			"logs/b.log":    "",
+			"logs/keep.log": "",
+		},
+		included: []string{Filename, "keep.log", "logs/keep.log"},
+	},
+	{
+		name: "re-including defaults",
+		files: map[string]string{
+			Filename:                "!node_modules\n",
+			"node_modules/a.js":     "",
+			"web/node_modules/b.js": "",
+		},
+		included: []string{Filename, "node_modules/a.js", "web/node_modules/b.js"},
+	},
+	{
+		name: "files of excluded directories",
+		files: map[string]string{
+			Filename:         "build\n!build/keep.txt\n",
+			"build/keep.txt": "",
+			"build/out.bin":  "",
+			"main.go":        "",
+		},
+		included: []string{Filename, "main.go"},
+	},
+	{
+		name: "re-including contents",
+		files: map[string]string{
+			Filename:            "build/*\n!build/keep.txt\n",
+			"build/keep.txt":    "",
+			"build/out.bin":     "",
+			"build/sub/out.bin": "",
+		},
+		included: []string{Filename, "build/keep.txt"},
+	},
+	{
+		name: "directory patterns",
+		files: map[string]string{
+			Filename:        "out/\n",
+			"out/a.txt":     "",
+			"src/out/b.txt": "",
+			"src/lib/out":   "",
+		},
+		included: []string{Filename, "src/lib/out"},
+	},
+	{
+		name: "anchored patterns",
+		files: map[string]string{
+			Filename:                 "/config.json\ndocs/internal\n",
+			"config.json":            "",
+			"sub/config.json":        "",
+			"docs/internal/a.md":     "",
+			"docs/public/b.md":       "",
+			"sub/docs/internal/c.md": "",
+		},
+		included: []string{Filename, "docs/public/b.md", "sub/config.json", "sub/docs/internal/c.md"},
+	},
+	{
+		name: "double asterisks",
+		files: map[string]string{
+			Filename:                  "**/fixtures\ndocs/**/*.png\ntmp/**\n",
+			"fixtures/a.json":         "",
+			"test/fixtures/b.json":    "",
+			"docs/logo.png":           "",
+			"docs/img/deep/chart.png": "",
+			"docs/readme.md":          "",
+			"logo.png":                "",
+			"tmp/a/b.txt":             "",
+		},
+		included: []string{Filename, "docs/readme.md", "logo.png"},
+	},
+	{
+		name: "wildcards",
+		files: map[string]string{
+			Filename:     "# comment\n\\#notes.txt\nfile[0-9].txt\nv?.txt\nimg[!a].png   \n",
+			"#notes.txt": "",
+			"file1.txt":  "",
+			"fileA.txt":  "",
+			"v1.txt":     "",
+			"v10.txt":    "",
+			"imga.png":   "",
+			"imgb.png":   "",
+			"# comment":  "",
+		},
+		included: []string{"# comment", Filename, "fileA.txt", "imga.png", "v10.txt"},
+	},
+	{
+		name: "allowlist",
+		files: map[string]string{
+			Filename:    "*\n!*/\n!*.go\n",
+			"main.go":   "",
+			"README.md": "",
+			"pkg/a.go":  "",
+			"pkg/b.txt": "",
+		},
+		included: []string{"main.go", "pkg/a.go"},
+	},
+	{
+		name: "nested ignore files",
+		files: map[string]string{
+			Filename:               "*.txt\n",
+			"notes.txt":            "",
+			"sub/" + Filename:      "!notes.txt\n/data\n*.csv\n",
+			"sub/notes.txt":        "",
+			"sub/other.txt":        "",
+			"sub/data/a.json":      "",
+			"sub/deep/data/b.json": "",
+			"sub/deep/c.csv":       "",
+			"d.csv":                "",
+			"other/" + Filename:    "!*.csv\n",
+			"other/e.csv":          "",
+		},
+		included: []string{
+			Filename,
+			"d.csv",
+			"other/" + Filename,
+			"other/e.csv",
+			"sub/" + Filename,
+			"sub/deep/data/b.json",
+			"sub/notes.txt",
+		},
+	},
+	{
+		name: "nested ignore files in excluded directories",
+		files: map[string]string{
+			Filename:             "vendor\n",
+			"vendor/" + Filename: "!*\n",
+			"vendor/lib.go":      "",
+		},
+		included: []string{Filename},
+	},
+}
+
+func TestMatcher(t *testing.T) {
+	for _, test := range ignoreTests {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+			root := writeTree(t, test.files)
+
+			m, err := NewMatcher(root)
+			require.NoError(err)
+			var included []string
+			for name := range test.files {
+				excluded, err := m.Excluded(name, false)
+				require.NoError(err)
+				if !excluded {
+					included = append(included, name)
+				}
+			}
+			sort.Strings(included)
+			require.Equal(test.included, included)
+		})
+	}
+}
+
+func TestFunc(t *testing.T) {
+	for _, test := range ignoreTests {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+			root := writeTree(t, test.files)
+
+			// Walk the tree like build archives do.
+			include, err := Func(root)
+			require.NoError(err)
+			var included []string
+			err = filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+				if err != nil || p == root {
+					return err
+				}
+				ok, err := include(p, info)
+				switch {
+				case err != nil:
+					return err
+				case !ok && info.IsDir():
+					return filepath.SkipDir
+				case ok && !info.IsDir():
+					rel, err := filepath.Rel(root, p)
+					if err != nil {
+						return err
+					}
+					included = append(included, filepath.ToSlash(rel))
+				}
+				return nil
+			})
+			require.NoError(err)
+			require.Equal(test.included, included)
+		})
+	}
+}
+
+// TestGitParity checks that git includes the same files when the ignore
+// files are used as .gitignore files.
+func TestGitParity(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	for _, test := range ignoreTests {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+			files := map[string]string{}
+			for name, contents := range test.files {
+				files[name] = contents
+				if path.Base(name) == Filename {
+					files[path.Join(path.Dir(name), ".gitignore")] = contents
+				}
+			}
+			root := writeTree(t, files)
+
+			git := func(args ...string) string {
+				cmd := exec.Command("git", args...)
+				cmd.Dir = root
+				out, err := cmd.CombinedOutput()
+				require.NoError(err, string(out))
+				return string(out)
+			}
+			git("init", "--quiet")
+			require.NoError(ioutil.WriteFile(
+				filepath.Join(root, ".git/info/exclude"),
+				[]byte(strings.Join(defaultExcludes, "\n")+"\n"),
+				0644,
+			))
+
+			var included []string
+			for _, name := range strings.Split(git("ls-files", "--others", "--exclude-standard", "-z"), "\x00") {
+				if name != "" && path.Base(name) != ".gitignore" {
+					included = append(included, name)
+				}
+			}
+			sort.Strings(included)
+			require.Equal(test.included, included)
 		})
 	}
 }
+
+// writeTree writes files to a temporary directory and returns its path.
+func writeTree(t *testing.T, files map[string]string) string {
+	root, err := ioutil.TempDir("", "airplane_ignore_test_*")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(root) })
+
+	for name, contents := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, ioutil.WriteFile(p, []byte(contents), 0644))
+	}
+	return root
+}
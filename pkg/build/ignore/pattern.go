@@ -0,0 +1,140 @@
+package ignore
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// pattern is a single gitignore pattern.
+//
+// See https://git-scm.com/docs/gitignore#_pattern_format.
+type pattern struct {
+	// base is the slash-separated directory, relative to the root, of the
+	// ignore file that declared the pattern. Patterns only match paths
+	// within their base, relative to it.
+	base string
+	// negate re-includes paths that previous patterns excluded.
+	negate bool
+	// dirOnly only matches directories, f.e. `build/`.
+	dirOnly bool
+	re      *regexp.Regexp
+}
+
+// parsePattern parses a line of an ignore file in base. It returns false
+// if the line is blank or a comment.
+func parsePattern(base, line string) (pattern, bool, error) {
+	line = strings.TrimSuffix(line, "\r")
+	line = trimTrailingSpaces(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return pattern{}, false, nil
+	}
+
+	p := pattern{base: base}
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") && !strings.HasSuffix(line, `\/`) {
+		p.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	if line == "" {
+		return pattern{}, false, nil
+	}
+
+	// Patterns with a slash at the start or in the middle are relative to
+	// the ignore file, others match names at any depth.
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	expr, err := translate(line)
+	if err != nil {
+		return pattern{}, false, errors.Wrapf(err, "invalid pattern %q", line)
+	}
+	if anchored {
+		expr = "^" + expr + "$"
+	} else {
+		expr = "(^|/)" + expr + "$"
+	}
+	if p.re, err = regexp.Compile(expr); err != nil {
+		return pattern{}, false, errors.Wrapf(err, "invalid pattern %q", line)
+	}
+	return p, true, nil
+}
+
+// trimTrailingSpaces removes trailing spaces, unless they're escaped.
+func trimTrailingSpaces(s string) string {
+	for strings.HasSuffix(s, " ") && !strings.HasSuffix(s, `\ `) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+// translate translates a glob into a regular expression.
+func translate(glob string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/") && (i == 0 || glob[i-1] == '/'):
+			// `**/` matches zero or more directories.
+			b.WriteString("(.*/)?")
+			i += 2
+		case glob[i:] == "**" && i > 0 && glob[i-1] == '/':
+			// A trailing `/**` matches everything inside.
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+			for i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+			}
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(regexp.QuoteMeta("["))
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if end == 0 {
+				// `[]...]` includes a closing bracket.
+				next := strings.IndexByte(glob[i+2:], ']')
+				if next < 0 {
+					return "", errors.New("unterminated character class")
+				}
+				class = glob[i+1 : i+2+next]
+				end = next + 1
+			}
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String(), nil
+}
+
+// match reports whether p matches the slash-separated path rel, relative
+// to the root.
+func (p pattern) match(rel string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	if p.base != "." {
+		if !strings.HasPrefix(rel, p.base+"/") {
+			return false
+		}
+		rel = rel[len(p.base)+1:]
+	}
+	return p.re.MatchString(rel)
+}
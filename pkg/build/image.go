@@ -0,0 +1,41 @@
+package build
+
+import (
+	"path/filepath"
+
+	"github.com/airplanedev/cli/pkg/api"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	Register(NameImage, imageBuilder{})
+}
+
+// imageBuilder is the builder of tasks that run a pre-built image, which
+// don't need to be built.
+type imageBuilder struct{}
+
+// Dockerfile implementation.
+func (imageBuilder) Dockerfile(root string, options api.KindOptions) (string, error) {
+	return "", errors.New("build: tasks that run an image are not built")
+}
+
+// Root implementation.
+func (imageBuilder) Root(path string) (string, error) {
+	return filepath.Dir(path), nil
+}
+
+// Workdir implementation.
+func (b imageBuilder) Workdir(path string) (string, error) {
+	return b.Root(path)
+}
+
+// Shim implementation.
+func (imageBuilder) Shim(root, entrypoint string) (string, error) {
+	return "", ErrNoShim
+}
+
+// NeedsBuilding implementation.
+func (imageBuilder) NeedsBuilding() bool {
+	return false
+}
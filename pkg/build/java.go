@@ -0,0 +1,151 @@
+package build
+
+import (
+	"path/filepath"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/airplanedev/cli/pkg/api"
+	"github.com/airplanedev/cli/pkg/fsx"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	Register(NameJava, javaBuilder{})
+}
+
+// javaBuilder builds Java tasks with Gradle or Maven.
+type javaBuilder struct{}
+
+// Dockerfile implementation.
+func (javaBuilder) Dockerfile(root string, options api.KindOptions) (string, error) {
+	return java(root, options)
+}
+
+// Root implementation.
+//
+// The root is the closest parent directory that contains the build file
+// of a Gradle or Maven project.
+func (javaBuilder) Root(path string) (string, error) {
+	return findRoot(path, javaProjectFiles...), nil
+}
+
+// Workdir implementation.
+func (b javaBuilder) Workdir(path string) (string, error) {
+	return b.Root(path)
+}
+
+// Shim implementation.
+//
+// Java tasks run their main class, which receives the params as its
+// arguments.
+func (javaBuilder) Shim(root, entrypoint string) (string, error) {
+	return "", ErrNoShim
+}
+
+// NeedsBuilding implementation.
+func (javaBuilder) NeedsBuilding() bool {
+	return true
+}
+
+// javaProjectFiles are the files that mark the root of a Java project.
+var javaProjectFiles = []string{
+	"build.gradle",
+	"build.gradle.kts",
+	"settings.gradle",
+	"settings.gradle.kts",
+	"pom.xml",
+}
+
+// Java creates a dockerfile for Java.
+//
+// The project is built with Gradle or Maven in a builder stage, which
+// collects the jars of the project and its runtime dependencies into a
+// directory. The final stage runs the `mainClass` with a JRE, so it
+// receives the task's arguments, f.e. the JSON params.
+//
+// Gradle projects must apply the `application` plugin, whose `installDist`
+// task collects the jars.
+func java(root string, options api.KindOptions) (string, error) {
+	mainClass, _ := options["mainClass"].(string)
+	if mainClass == "" {
+		return "", errors.New("mainClass is unexpectedly missing")
+	}
+
+	javaVersion, _ := options["javaVersion"].(string)
+	if javaVersion == "" {
+		javaVersion = "17"
+	}
+	base, err := getImage(NameJava, javaVersion)
+	if err != nil {
+		return "", err
+	}
+	if base == "" {
+		return "", errors.Errorf("unsupported java version %q", javaVersion)
+	}
+
+	var tool, cacheMount, build string
+	switch {
+	case fsx.Exists(filepath.Join(root, "pom.xml")):
+		tool, cacheMount = "maven", mavenCacheMount
+		build = "mvn -B package -DskipTests" +
+			" && mvn -B dependency:copy-dependencies -DincludeScope=runtime -DoutputDirectory=/airplane/.airplane/lib" +
+			" && cp target/*.jar /airplane/.airplane/lib/"
+	case hasGradleBuild(root):
+		tool, cacheMount = "gradle", gradleCacheMount
+		gradle := "gradle"
+		if fsx.Exists(filepath.Join(root, "gradlew")) {
+			gradle = "./gradlew"
+		}
+		build = gradle + " installDist --no-daemon" +
+			" && mkdir -p /airplane/.airplane/lib" +
+			" && cp build/install/*/lib/*.jar /airplane/.airplane/lib/"
+	default:
+		return "", errors.Errorf("expected a pom.xml or build.gradle in %s", root)
+	}
+
+	builder, err := getImage(NameJava, tool+"/"+javaVersion)
+	if err != nil {
+		return "", err
+	}
+	if builder == "" {
+		return "", errors.Errorf("unsupported java version %q for %s", javaVersion, tool)
+	}
+
+	return applyTemplate(heredoc.Doc(`
+		# syntax=docker/dockerfile:1.2
+		FROM {{.Builder}} as builder
+
+		WORKDIR /airplane
+		COPY . .
+		RUN {{.CacheMount}} {{.Build}}
+
+		FROM {{.Base}}
+
+		WORKDIR /airplane
+		COPY --from=builder /airplane/.airplane/lib /airplane/lib
+
+		ENTRYPOINT {{.Entrypoint}}
+	`), struct {
+		Builder    string
+		Base       string
+		CacheMount string
+		Build      string
+		Entrypoint string
+	}{
+		Builder:    builder,
+		Base:       base,
+		CacheMount: cacheMount,
+		Build:      build,
+		Entrypoint: jsonArray("java", "-cp", "/airplane/lib/*", mainClass),
+	})
+}
+
+// hasGradleBuild reports whether root contains a Gradle build.
+func hasGradleBuild(root string) bool {
+	for _, name := range javaProjectFiles {
+		if name != "pom.xml" && fsx.Exists(filepath.Join(root, name)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package build
+
+import (
+	"testing"
+
+	"github.com/airplanedev/cli/pkg/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJava(t *testing.T) {
+	useTestVersions(t)
+	for _, test := range []struct {
+		root    string
+		builder string
+		build   string
+	}{
+		{
+			root:    "testdata/java/gradle",
+			builder: "gradle/17",
+			build:   "gradle installDist --no-daemon",
+		},
+		{
+			root:    "testdata/java/maven",
+			builder: "maven/17",
+			build:   "mvn -B package -DskipTests",
+		},
+	} {
+		t.Run(test.root, func(t *testing.T) {
+			require := require.New(t)
+
+			dockerfile, err := BuildDockerfile(DockerfileConfig{
+				Builder: string(NameJava),
+				Root:    test.root,
+				Options: api.KindOptions{"mainClass": "tasks.Main"},
+			})
+			require.NoError(err)
+
+			builder, err := GetVersion(NameJava, test.builder)
+			require.NoError(err)
+			base, err := GetVersion(NameJava, "17")
+			require.NoError(err)
+
+			instrs := instructions(dockerfile)
+			require.Equal("FROM "+builder.String()+" as builder", instrs[0])
+			require.Contains(instrs, "FROM "+base.String())
+			require.Contains(dockerfile, test.build)
+			require.Equal(`ENTRYPOINT ["java", "-cp", "/airplane/lib/*", "tasks.Main"]`, instrs[len(instrs)-1])
+		})
+	}
+}
+
+func TestJavaErrors(t *testing.T) {
+	useTestVersions(t)
+	require := require.New(t)
+
+	_, err := BuildDockerfile(DockerfileConfig{
+		Builder: string(NameJava),
+		Root:    "testdata/java/gradle",
+	})
+	require.Error(err)
+	require.Contains(err.Error(), "mainClass")
+
+	_, err = BuildDockerfile(DockerfileConfig{
+		Builder: string(NameJava),
+		Root:    "testdata/ruby/none",
+		Options: api.KindOptions{"mainClass": "tasks.Main"},
+	})
+	require.Error(err)
+	require.Contains(err.Error(), "expected a pom.xml or build.gradle")
+}
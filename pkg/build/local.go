@@ -2,6 +2,7 @@ package build
 
 import (
 	"context"
+	"strings"
 
 	"github.com/airplanedev/cli/pkg/api"
 	"github.com/airplanedev/cli/pkg/configs"
@@ -16,7 +17,7 @@ func local(ctx context.Context, req Request) (*Response, error) {
 		return nil, errors.Wrap(err, "getting registry token")
 	}
 
-	buildEnv, err := getBuildEnv(ctx, req.Client, req.Def)
+	buildEnv, buildSecrets, err := getBuildEnv(ctx, req.Client, req.Def)
 	if err != nil {
 		return nil, err
 	}
@@ -38,37 +39,75 @@ func local(ctx context.Context, req Request) (*Response, error) {
 			Token: registry.Token,
 			Repo:  registry.Repo,
 		},
-		BuildEnv: buildEnv,
+		BuildEnv:     buildEnv,
+		BuildSecrets: buildSecrets,
+		CacheFrom:    req.CacheFrom,
+		CacheTo:      req.CacheTo,
+		Platforms:    req.Platforms,
+
+		DockerfileOutput: req.DockerfileOutput,
+		ContextOutput:    req.ContextOutput,
 	})
 	if err != nil {
 		return nil, errors.Wrap(err, "new build")
 	}
 
+	tag := req.Tag
+	if tag == "" {
+		tag = "latest"
+	}
+
+	if b.MultiPlatform() && req.SkipPush {
+		return nil, errors.New("multi-platform images can't be built without pushing them")
+	}
+
 	logger.Log("Building...")
-	resp, err := b.Build(ctx, req.TaskID, "latest")
+	resp, err := b.Build(ctx, req.TaskID, tag)
 	if err != nil {
 		return nil, errors.Wrap(err, "build")
 	}
 
+	if b.MultiPlatform() || req.SkipPush {
+		// Multi-platform images and their cache are pushed by the build,
+		// other images remain in the local daemon.
+		return resp, nil
+	}
+
 	logger.Log("Pushing...")
-	if err := b.Push(ctx, resp.ImageURL); err != nil {
+	if resp.Digest, err = b.Push(ctx, resp.ImageURL); err != nil {
 		return nil, errors.Wrap(err, "push")
 	}
 
+	if req.CacheTo != "" {
+		logger.Log("Exporting build cache...")
+		if _, err := b.Push(ctx, req.CacheTo); err != nil {
+			return nil, errors.Wrap(err, "push cache")
+		}
+	}
+
 	return resp, nil
 }
 
-// Retreives a build env from def - looks for env vars starting with BUILD_ and either uses the
-// string literal or looks up the config value.
-func getBuildEnv(ctx context.Context, client *api.Client, def definitions.Definition) (map[string]string, error) {
-	buildEnv := make(map[string]string)
+// getBuildEnv retrieves the build env from def. Only env vars starting with BUILD_ are
+// passed to the build, their values are either string literals or config values.
+//
+// Every BUILD_ var is available as a build secret. Values that are not backed by a
+// secret config are additionally passed as build args, which are visible in the image
+// history and therefore never contain secrets.
+func getBuildEnv(ctx context.Context, client *api.Client, def definitions.Definition) (args map[string]string, secrets map[string]string, err error) {
+	args = make(map[string]string)
+	secrets = make(map[string]string)
 	for k, v := range def.Env {
+		if !strings.HasPrefix(k, "BUILD_") {
+			continue
+		}
 		if v.Value != nil {
-			buildEnv[k] = *v.Value
+			args[k] = *v.Value
+			secrets[k] = *v.Value
 		} else if v.Config != nil {
 			nt, err := configs.ParseName(*v.Config)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			res, err := client.GetConfig(ctx, api.GetConfigRequest{
 				Name:       nt.Name,
@@ -76,10 +115,13 @@ func getBuildEnv(ctx context.Context, client *api.Client, def definitions.Defini
 				ShowSecret: true,
 			})
 			if err != nil {
-				return nil, err
+				return nil, nil, err
+			}
+			if !res.Config.IsSecret {
+				args[k] = res.Config.Value
 			}
-			buildEnv[k] = res.Config.Value
+			secrets[k] = res.Config.Value
 		}
 	}
-	return buildEnv, nil
+	return args, secrets, nil
 }
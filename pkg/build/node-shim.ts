@@ -1,4 +1,8 @@
 // This file includes a shim that will execute your task code.
+
+// Path aliases must resolve before the task is imported. tsc keeps the
+// order of statements and imports when compiling to CommonJS.
+registerPaths();
 import task from "{{.Entrypoint}}";
 
 async function main() {
@@ -39,3 +43,80 @@ async function main() {
 }
 
 main();
+
+// registerPaths resolves the path aliases (`paths` and `baseUrl`) of the
+// tsconfig.json to the compiled task, since tsc does not rewrite them.
+function registerPaths() {
+  const fs = require("fs");
+  const path = require("path");
+  const Module = require("module");
+
+  // This file is compiled to <root>/.airplane/dist/.airplane/shim.js.
+  const airplaneDir = path.join(__dirname, "..", "..");
+  const rootDir = path.join(airplaneDir, "..");
+  let options: any;
+  try {
+    const tsconfig = fs.readFileSync(path.join(airplaneDir, "tsconfig.json"), "utf8");
+    options = JSON.parse(tsconfig).compilerOptions || {};
+  } catch {
+    return;
+  }
+  if (!options.baseUrl) {
+    return;
+  }
+  const baseUrl: string = path.join(airplaneDir, options.baseUrl);
+  const paths: { [pattern: string]: string[] } = options.paths || {};
+
+  // Maps a source path to its compiled path within the dist directory.
+  const compiled = (source: string) =>
+    path
+      .join(airplaneDir, "dist", path.relative(rootDir, source))
+      .replace(/[.]tsx?$/, ".js");
+
+  const candidates = (request: string): string[] => {
+    const result: string[] = [];
+    for (const pattern of Object.keys(paths)) {
+      const [prefix, suffix] = pattern.split("*");
+      let match: string | undefined;
+      if (suffix === undefined) {
+        match = request === pattern ? "" : undefined;
+      } else if (
+        request.startsWith(prefix) && request.endsWith(suffix) &&
+        request.length >= prefix.length + suffix.length
+      ) {
+        match = request.slice(prefix.length, request.length - suffix.length);
+      }
+      if (match === undefined) {
+        continue;
+      }
+      for (const target of paths[pattern]) {
+        result.push(compiled(path.join(baseUrl, target.replace("*", match))));
+      }
+    }
+    return result;
+  };
+
+  const resolve = Module._resolveFilename;
+  Module._resolveFilename = function (this: any, request: string, ...args: any[]) {
+    if (request.startsWith(".") || path.isAbsolute(request)) {
+      return resolve.call(this, request, ...args);
+    }
+    for (const candidate of candidates(request)) {
+      try {
+        return resolve.call(this, candidate, ...args);
+      } catch {
+        // Try the next candidate.
+      }
+    }
+    try {
+      return resolve.call(this, request, ...args);
+    } catch (err) {
+      // Non-relative imports are also resolved relative to the baseUrl.
+      try {
+        return resolve.call(this, compiled(path.join(baseUrl, request)), ...args);
+      } catch {
+        throw err;
+      }
+    }
+  };
+}
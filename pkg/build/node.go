@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path"
 	"path/filepath"
 	"strings"
@@ -17,6 +18,92 @@ import (
 	"github.com/pkg/errors"
 )
 
+func init() {
+	Register(NameNode, nodeBuilder{})
+}
+
+// nodeBuilder builds Node tasks, written in JavaScript or TypeScript.
+type nodeBuilder struct{}
+
+// Dockerfile implementation.
+func (nodeBuilder) Dockerfile(root string, options api.KindOptions) (string, error) {
+	return node(root, options)
+}
+
+// Workdir implementation.
+//
+// The workdir is the nearest parent directory containing a `package.json`.
+func (nodeBuilder) Workdir(path string) (string, error) {
+	if p, ok := fsx.Find(path, "package.json"); ok {
+		return p, nil
+	}
+
+	// Otherwise default to immediate directory of path
+	return filepath.Dir(path), nil
+}
+
+// Root implementation.
+//
+// The root is usually just the workdir. However, this can be overridden
+// with the `airplane.root` property in the `package.json`, and tasks in
+// a package of a workspace are built from the root of the workspace.
+func (b nodeBuilder) Root(path string) (string, error) {
+	// By default, the root is the workdir.
+	root, err := b.Workdir(path)
+	if err != nil {
+		return "", err
+	}
+
+	// Unless the root is overridden with an `airplane.root` field
+	// in a `package.json`.
+	pkgjson := filepath.Join(root, "package.json")
+	buf, err := ioutil.ReadFile(pkgjson)
+	if err != nil {
+		// No package.json, use workdir as root.
+		if os.IsNotExist(err) {
+			logger.Debug("no package.json found")
+			return root, nil
+		}
+		return "", errors.Wrapf(err, "javascript: reading %s", pkgjson)
+	}
+	logger.Debug("found package.json at %s", pkgjson)
+
+	var pkg struct {
+		Settings struct {
+			Root string `json:"root"`
+		} `json:"airplane"`
+	}
+
+	if err := json.Unmarshal(buf, &pkg); err != nil {
+		return "", fmt.Errorf("javascript: reading %s - %w", root, err)
+	}
+
+	if pkgjsonRoot := pkg.Settings.Root; pkgjsonRoot != "" {
+		return filepath.Join(root, pkgjsonRoot), nil
+	}
+
+	// Tasks in a package of a workspace are built from the root of the
+	// workspace, so that the dependencies of the workspace can be installed.
+	if ws, ok, err := NodeWorkspaceRoot(string(filepath.Separator), root); err != nil {
+		return "", err
+	} else if ok {
+		logger.Debug("found workspace at %s", ws)
+		return ws, nil
+	}
+
+	return root, nil
+}
+
+// Shim implementation.
+func (nodeBuilder) Shim(root, entrypoint string) (string, error) {
+	return NodeShim(entrypoint)
+}
+
+// NeedsBuilding implementation.
+func (nodeBuilder) NeedsBuilding() bool {
+	return true
+}
+
 // node creates a dockerfile for Node (typescript/javascript).
 func node(root string, options api.KindOptions) (string, error) {
 	var err error
@@ -24,7 +111,11 @@ func node(root string, options api.KindOptions) (string, error) {
 	// For backwards compatibility, continue to build old Node tasks
 	// in the same way. Tasks built with the latest CLI will set
 	// shim=true which enables the new code path.
-	if shim, ok := options["shim"].(string); !ok || shim != "true" {
+	isShim := options["shim"] == "true"
+	if IsBundle(options) {
+		return nodeBundle(root, options, isShim)
+	}
+	if !isShim {
 		return nodeLegacyBuilder(root, options)
 	}
 
@@ -35,24 +126,56 @@ func node(root string, options api.KindOptions) (string, error) {
 	}
 
 	workdir, _ := options["workdir"].(string)
+	project, err := getNodeProject(root, workdir)
+	if err != nil {
+		return "", err
+	}
+
 	cfg := struct {
 		Workdir               string
 		Base                  string
 		HasPackageJSON        bool
-		IsYarn                bool
+		HasTypeScript         bool
 		InlineShim            string
 		InlineTSConfig        string
 		InlineShimPackageJSON string
+		InstallFiles          []string
+		InstallCommand        string
+		PruneCommand          string
+		TSCCommand            string
+		NPMCacheMount         string
 	}{
+		NPMCacheMount:  npmCacheMount,
 		Workdir:        workdir,
 		HasPackageJSON: fsx.AssertExistsAll(filepath.Join(root, "package.json")) == nil,
-		IsYarn:         fsx.AssertExistsAll(filepath.Join(root, "yarn.lock")) == nil,
+		HasTypeScript:  project.HasTypeScript,
+		TSCCommand:     "tsc",
 	}
 
 	if !strings.HasPrefix(cfg.Workdir, "/") {
 		cfg.Workdir = "/" + cfg.Workdir
 	}
 
+	if project.HasPackageJSON {
+		// Copy the files required to install the dependencies first, so that
+		// the install is cached until one of them changes.
+		for _, f := range project.Files {
+			cfg.InstallFiles = append(cfg.InstallFiles, jsonArray(f, path.Join("/airplane", f)))
+		}
+		cfg.InstallCommand = project.InstallCommand(path.Join("/airplane", project.InstallDir))
+		cfg.PruneCommand = project.PruneCommand(path.Join("/airplane", project.InstallDir))
+	}
+
+	if project.HasTypeScript {
+		// Use the project's own TypeScript, which is installed into either
+		// the workdir or, if hoisted, the root of the workspace.
+		cfg.TSCCommand = fmt.Sprintf(
+			"export PATH=%s:%s:$PATH && tsc",
+			path.Join("/airplane", cfg.Workdir, "node_modules/.bin"),
+			path.Join("/airplane", project.InstallDir, "node_modules/.bin"),
+		)
+	}
+
 	nodeVersion, _ := options["nodeVersion"].(string)
 	cfg.Base, err = getBaseNodeImage(nodeVersion)
 	if err != nil {
@@ -90,38 +213,52 @@ func node(root string, options api.KindOptions) (string, error) {
 	//
 	// Down the road, we may want to give customers more control over this build process
 	// in which case we could introduce an extra step for performing build commands.
+	//
+	// The build runs in a separate stage so that the final image only contains the
+	// compiled task and its production dependencies, not the TypeScript toolchain
+	// or any other devDependencies.
 	return applyTemplate(heredoc.Doc(`
-		FROM {{.Base}}
+		# syntax=docker/dockerfile:1.2
+		FROM {{.Base}} AS builder
 
 		WORKDIR /airplane{{.Workdir}}
 
-		# Support setting BUILD_NPM_RC or BUILD_NPM_TOKEN to configure private registry auth
-		ARG BUILD_NPM_RC
-		ARG BUILD_NPM_TOKEN
-		RUN [ -z "${BUILD_NPM_RC}" ] || echo "${BUILD_NPM_RC}" > .npmrc
-		RUN [ -z "${BUILD_NPM_TOKEN}" ] || echo "//registry.npmjs.org/:_authToken=${BUILD_NPM_TOKEN}" > .npmrc
-
-		RUN npm install -g typescript@4.2
-		COPY . /airplane
+		{{if not .HasTypeScript}}
+		RUN {{.NPMCacheMount}} npm install -g typescript@4.2
+		{{end}}
 
-		RUN mkdir -p /airplane/.airplane && \
+		RUN {{.NPMCacheMount}} mkdir -p /airplane/.airplane && \
 			cd /airplane/.airplane && \
 			{{.InlineShimPackageJSON}} > package.json && \
 			npm install
 
-		{{if not .HasPackageJSON}}
-		RUN echo '{}' > /airplane/package.json
+		{{if .InstallCommand}}
+		{{range .InstallFiles}}
+		COPY {{.}}
+		{{end}}
+		# Support setting BUILD_NPM_RC or BUILD_NPM_TOKEN to configure private registry auth
+		RUN {{.InstallCommand}}
 		{{end}}
 
-		{{if .IsYarn}}
-		RUN yarn --non-interactive
-		{{else}}
-		RUN npm install
+		COPY . /airplane
+
+		{{if not .HasPackageJSON}}
+		RUN echo '{}' > /airplane/package.json
 		{{end}}
 
 		RUN {{.InlineShim}} > /airplane/.airplane/shim.ts && \
 			{{.InlineTSConfig}} > /airplane/.airplane/tsconfig.json && \
-			tsc --pretty -p /airplane/.airplane
+			{{.TSCCommand}} --pretty -p /airplane/.airplane
+
+		{{if .PruneCommand}}
+		RUN {{.PruneCommand}}
+		{{end}}
+
+		FROM {{.Base}}
+
+		WORKDIR /airplane{{.Workdir}}
+
+		COPY --from=builder /airplane /airplane
 		ENTRYPOINT ["node", "/airplane/.airplane/dist/.airplane/shim.js"]
 	`), cfg)
 }
@@ -144,15 +281,16 @@ func GenShimPackageJSON() ([]byte, error) {
 func GenTSConfig(root string, entrypoint string, opts api.KindOptions) ([]byte, error) {
 	// https://www.typescriptlang.org/tsconfig
 	type CompilerOptions struct {
-		Target          string                     `json:"target,omitempty"`
-		Lib             []string                   `json:"lib,omitempty"`
-		AllowJS         *bool                      `json:"allowJs,omitempty"`
-		Module          string                     `json:"module,omitempty"`
-		ESModuleInterop *bool                      `json:"esModuleInterop,omitempty"`
-		OutDir          string                     `json:"outDir"`
-		RootDir         string                     `json:"rootDir"`
-		SkipLibCheck    *bool                      `json:"skipLibCheck,omitempty"`
-		Paths           map[string]json.RawMessage `json:"paths,omitempty"`
+		Target          string              `json:"target,omitempty"`
+		Lib             []string            `json:"lib,omitempty"`
+		AllowJS         *bool               `json:"allowJs,omitempty"`
+		Module          string              `json:"module,omitempty"`
+		ESModuleInterop *bool               `json:"esModuleInterop,omitempty"`
+		OutDir          string              `json:"outDir"`
+		RootDir         string              `json:"rootDir"`
+		SkipLibCheck    *bool               `json:"skipLibCheck,omitempty"`
+		BaseURL         string              `json:"baseUrl,omitempty"`
+		Paths           map[string][]string `json:"paths,omitempty"`
 	}
 	type TSConfig struct {
 		CompilerOptions CompilerOptions `json:"compilerOptions"`
@@ -188,15 +326,28 @@ func GenTSConfig(root string, entrypoint string, opts api.KindOptions) ([]byte,
 		if err := json.Unmarshal(content, &utsc); err != nil {
 			return nil, errors.Wrap(err, "invalid tsconfig.json")
 		}
-		if len(utsc.CompilerOptions.Paths) > 0 {
-			logger.Warning("Detected a tsconfig.json with path aliases which are not supported on Airplane yet.")
-		}
 
 		rp, err := filepath.Rel(filepath.Join(root, ".airplane"), p)
 		if err != nil {
 			return nil, errors.Wrap(err, "creating relative tsconfig path")
 		}
 		tsconfig.Extends = rp
+
+		// Path aliases are resolved relative to the tsconfig that declares
+		// them. They are copied over relative to the generated tsconfig since
+		// the shim reads them to resolve aliases at runtime, which tsc leaves
+		// untouched in the compiled code.
+		if utsc.CompilerOptions.BaseURL != "" || len(utsc.CompilerOptions.Paths) > 0 {
+			baseURL, err := filepath.Rel(
+				filepath.Join(root, ".airplane"),
+				filepath.Join(filepath.Dir(p), utsc.CompilerOptions.BaseURL),
+			)
+			if err != nil {
+				return nil, errors.Wrap(err, "creating relative baseUrl")
+			}
+			tsconfig.CompilerOptions.BaseURL = filepath.ToSlash(baseURL)
+			tsconfig.CompilerOptions.Paths = utsc.CompilerOptions.Paths
+		}
 	}
 
 	// Apply defaults to a few of the tsconfig fields, but let the user override
@@ -215,7 +366,7 @@ func GenTSConfig(root string, entrypoint string, opts api.KindOptions) ([]byte,
 	}
 
 	target := "es2020"
-	if opts != nil && strings.HasPrefix(opts["nodeVersion"].(string), "12") {
+	if nodeVersion, _ := opts["nodeVersion"].(string); strings.HasPrefix(nodeVersion, "12") {
 		// For Node 12 (the earliest version of Node we support), we need to compile to an
 		// older version of ECMAScript.
 		target = "es2019"
@@ -288,9 +439,9 @@ func nodeLegacyBuilder(root string, options api.KindOptions) (string, error) {
 
 	// Determine the install command to use.
 	if err := fsx.AssertExistsAll(pkglock); err == nil {
-		cmds = append(cmds, `npm install package-lock.json`)
+		cmds = append(cmds, npmCacheMount+" "+withNPMSecrets(`npm install package-lock.json`))
 	} else if err := fsx.AssertExistsAll(yarnlock); err == nil {
-		cmds = append(cmds, `yarn install`)
+		cmds = append(cmds, yarnCacheMount+" "+withNPMSecrets(`yarn install`))
 	}
 
 	// Language specific.
@@ -299,7 +450,7 @@ func nodeLegacyBuilder(root string, options api.KindOptions) (string, error) {
 		if buildDir == "" {
 			buildDir = ".airplane"
 		}
-		cmds = append(cmds, `npm install -g typescript@4.1`)
+		cmds = append(cmds, npmCacheMount+` npm install -g typescript@4.1`)
 		cmds = append(cmds, `[ -f tsconfig.json ] || echo '{"include": ["*", "**/*"], "exclude": ["node_modules"]}' >tsconfig.json`)
 		cmds = append(cmds, fmt.Sprintf(`rm -rf %s && tsc --outDir %s --rootDir .`, buildDir, buildDir))
 		if buildCommand != "" {
@@ -327,16 +478,12 @@ func nodeLegacyBuilder(root string, options api.KindOptions) (string, error) {
 	}
 
 	return applyTemplate(heredoc.Doc(`
+		# syntax=docker/dockerfile:1.2
 		FROM {{ .Base }}
 
 		WORKDIR {{ .Workdir }}
 
 		# Support setting BUILD_NPM_RC or BUILD_NPM_TOKEN to configure private registry auth
-		ARG BUILD_NPM_RC
-		ARG BUILD_NPM_TOKEN
-		RUN [ -z "${BUILD_NPM_RC}" ] || echo "${BUILD_NPM_RC}" > .npmrc
-		RUN [ -z "${BUILD_NPM_TOKEN}" ] || echo "//registry.npmjs.org/:_authToken=${BUILD_NPM_TOKEN}" > .npmrc
-
 		COPY . {{ .Workdir }}
 		{{ range .Commands }}
 		RUN {{ . }}
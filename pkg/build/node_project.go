@@ -0,0 +1,427 @@
+package build
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/airplanedev/cli/pkg/fsx"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// NodePackageManager is a tool that installs the dependencies of a Node
+// project.
+type NodePackageManager string
+
+const (
+	NodeNPM       NodePackageManager = "npm"
+	NodeYarn      NodePackageManager = "yarn"
+	NodeYarnBerry NodePackageManager = "yarn-berry"
+	NodePNPM      NodePackageManager = "pnpm"
+)
+
+// packageJSON is the subset of package.json that the builder reads.
+type packageJSON struct {
+	PackageManager  string            `json:"packageManager"`
+	Dependencies    map[string]string `json:"dependencies"`
+	DevDependencies map[string]string `json:"devDependencies"`
+	// Workspaces is either a list of globs, or an object with a `packages`
+	// list of globs.
+	Workspaces json.RawMessage `json:"workspaces"`
+}
+
+// readPackageJSON reads the package.json in dir. A missing package.json is
+// not an error.
+func readPackageJSON(dir string) (packageJSON, bool, error) {
+	buf, err := ioutil.ReadFile(filepath.Join(dir, "package.json"))
+	if os.IsNotExist(err) {
+		return packageJSON{}, false, nil
+	} else if err != nil {
+		return packageJSON{}, false, errors.Wrap(err, "reading package.json")
+	}
+
+	var pkg packageJSON
+	if err := json.Unmarshal(buf, &pkg); err != nil {
+		return packageJSON{}, false, errors.Wrapf(err, "parsing %s", filepath.Join(dir, "package.json"))
+	}
+	return pkg, true, nil
+}
+
+// dependsOn returns true if the package depends on name.
+func (p packageJSON) dependsOn(name string) bool {
+	_, ok := p.Dependencies[name]
+	_, okDev := p.DevDependencies[name]
+	return ok || okDev
+}
+
+// packageManagerVersion returns the version of pm that is pinned with the
+// `packageManager` field, f.e. "pnpm@6.11.0".
+func (p packageJSON) packageManagerVersion(pm string) string {
+	if name, version, ok := cut(p.PackageManager, "@"); ok && name == pm {
+		// Drop the optional hash, f.e. "3.2.1+sha224.abc".
+		version, _, _ = cut(version, "+")
+		return version
+	}
+	return ""
+}
+
+// workspaces returns the workspace globs of the package.
+func (p packageJSON) workspaces() ([]string, error) {
+	if len(p.Workspaces) == 0 || string(p.Workspaces) == "null" {
+		return nil, nil
+	}
+
+	var globs []string
+	if err := json.Unmarshal(p.Workspaces, &globs); err == nil {
+		return globs, nil
+	}
+
+	var obj struct {
+		Packages []string `json:"packages"`
+	}
+	if err := json.Unmarshal(p.Workspaces, &obj); err != nil {
+		return nil, errors.Wrap(err, "parsing package.json workspaces")
+	}
+	return obj.Packages, nil
+}
+
+// GetNodePackageManager returns the package manager of the Node project
+// at dir, based on its lock files and configuration.
+func GetNodePackageManager(dir string) (NodePackageManager, error) {
+	pkg, _, err := readPackageJSON(dir)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case fsx.Exists(filepath.Join(dir, "pnpm-lock.yaml")),
+		fsx.Exists(filepath.Join(dir, "pnpm-workspace.yaml")),
+		strings.HasPrefix(pkg.PackageManager, "pnpm@"):
+		return NodePNPM, nil
+	case fsx.Exists(filepath.Join(dir, ".yarnrc.yml")),
+		strings.HasPrefix(pkg.PackageManager, "yarn@") && !strings.HasPrefix(pkg.PackageManager, "yarn@1."):
+		return NodeYarnBerry, nil
+	case fsx.Exists(filepath.Join(dir, "yarn.lock")):
+		return NodeYarn, nil
+	default:
+		return NodeNPM, nil
+	}
+}
+
+// NodeWorkspaceRoot returns the root of the npm, yarn or pnpm workspace
+// that contains dir, either as the root itself or within one of its
+// packages. Parent directories are searched up until root. It returns false
+// if dir is not part of a workspace.
+func NodeWorkspaceRoot(root, dir string) (string, bool, error) {
+	root, dir = filepath.Clean(root), filepath.Clean(dir)
+	if rel, err := filepath.Rel(root, dir); err != nil || strings.HasPrefix(rel, "..") {
+		return "", false, nil
+	}
+
+	for d := dir; ; d = filepath.Dir(d) {
+		if d == dir {
+			globs, err := workspaceGlobs(d)
+			if err != nil {
+				return "", false, err
+			}
+			if len(globs) > 0 {
+				return d, true, nil
+			}
+		} else {
+			pkgs, err := workspacePackages(d)
+			if err != nil {
+				return "", false, err
+			}
+			rel, err := filepath.Rel(d, dir)
+			if err != nil {
+				return "", false, err
+			}
+			rel = filepath.ToSlash(rel)
+			for _, pkg := range pkgs {
+				if rel == pkg || strings.HasPrefix(rel, pkg+"/") {
+					return d, true, nil
+				}
+			}
+		}
+
+		if d == root || d == filepath.Dir(d) {
+			return "", false, nil
+		}
+	}
+}
+
+// workspaceGlobs returns the globs of the workspace packages if dir is the
+// root of a workspace.
+func workspaceGlobs(dir string) ([]string, error) {
+	buf, err := ioutil.ReadFile(filepath.Join(dir, "pnpm-workspace.yaml"))
+	if err == nil {
+		var ws struct {
+			Packages []string `yaml:"packages"`
+		}
+		if err := yaml.Unmarshal(buf, &ws); err != nil {
+			return nil, errors.Wrap(err, "parsing pnpm-workspace.yaml")
+		}
+		return ws.Packages, nil
+	} else if !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "reading pnpm-workspace.yaml")
+	}
+
+	pkg, _, err := readPackageJSON(dir)
+	if err != nil {
+		return nil, err
+	}
+	return pkg.workspaces()
+}
+
+// workspacePackages returns the directories of the packages in the
+// workspace at dir, relative to dir.
+func workspacePackages(dir string) ([]string, error) {
+	globs, err := workspaceGlobs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	for _, glob := range globs {
+		// Exclusions only narrow down the packages, copying the manifests
+		// of excluded packages is harmless.
+		if strings.HasPrefix(glob, "!") {
+			continue
+		}
+
+		var matches []string
+		if prefix := strings.TrimSuffix(glob, "/**"); prefix != glob {
+			err := filepath.Walk(filepath.Join(dir, prefix), func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return nil
+				}
+				if info.IsDir() && info.Name() == "node_modules" {
+					return filepath.SkipDir
+				}
+				if info.IsDir() {
+					matches = append(matches, path)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, errors.Wrapf(err, "expanding workspace %s", glob)
+			}
+		} else if matches, err = filepath.Glob(filepath.Join(dir, glob)); err != nil {
+			return nil, errors.Wrapf(err, "expanding workspace %s", glob)
+		}
+
+		for _, m := range matches {
+			if !fsx.Exists(filepath.Join(m, "package.json")) {
+				continue
+			}
+			rel, err := filepath.Rel(dir, m)
+			if err != nil {
+				return nil, err
+			}
+			seen[filepath.ToSlash(rel)] = true
+		}
+	}
+
+	pkgs := make([]string, 0, len(seen))
+	for p := range seen {
+		pkgs = append(pkgs, p)
+	}
+	sort.Strings(pkgs)
+	return pkgs, nil
+}
+
+// nodeProject describes how to install the dependencies of a Node task.
+type nodeProject struct {
+	// InstallDir is the directory, relative to the root, in which the
+	// dependencies are installed. This is the root of the workspace if
+	// the task is part of one, otherwise the workdir.
+	InstallDir string
+	// PackageManager installs the dependencies.
+	PackageManager NodePackageManager
+	// Files are the files and directories, relative to the root, that are
+	// required to install the dependencies.
+	Files []string
+	// HasPackageJSON is true if there are dependencies to install.
+	HasPackageJSON bool
+	// HasTypeScript is true if the project depends on typescript.
+	HasTypeScript bool
+
+	pkg      packageJSON
+	yarnPath string
+}
+
+// getNodeProject returns the Node project of a task with the given workdir,
+// relative to root.
+func getNodeProject(root, workdir string) (nodeProject, error) {
+	workdir = strings.TrimPrefix(filepath.ToSlash(workdir), "/")
+	abs := filepath.Join(root, workdir)
+
+	installAbs := abs
+	var packages []string
+	if ws, ok, err := NodeWorkspaceRoot(root, abs); err != nil {
+		return nodeProject{}, err
+	} else if ok {
+		installAbs = ws
+		if packages, err = workspacePackages(ws); err != nil {
+			return nodeProject{}, err
+		}
+	}
+
+	installDir, err := filepath.Rel(root, installAbs)
+	if err != nil {
+		return nodeProject{}, err
+	}
+	installDir = filepath.ToSlash(installDir)
+
+	pm, err := GetNodePackageManager(installAbs)
+	if err != nil {
+		return nodeProject{}, err
+	}
+
+	pkg, hasPackageJSON, err := readPackageJSON(installAbs)
+	if err != nil {
+		return nodeProject{}, err
+	}
+	hasTypeScript := pkg.dependsOn("typescript")
+	if installAbs != abs {
+		wpkg, _, err := readPackageJSON(abs)
+		if err != nil {
+			return nodeProject{}, err
+		}
+		hasTypeScript = hasTypeScript || wpkg.dependsOn("typescript")
+	}
+
+	yarnPath, err := readYarnPath(installAbs)
+	if err != nil {
+		return nodeProject{}, err
+	}
+
+	var files []string
+	for _, f := range []string{
+		"package.json",
+		"package-lock.json",
+		"npm-shrinkwrap.json",
+		"yarn.lock",
+		".yarnrc",
+		".yarnrc.yml",
+		".yarn/releases",
+		".yarn/plugins",
+		".yarn/patches",
+		"pnpm-lock.yaml",
+		"pnpm-workspace.yaml",
+		".npmrc",
+	} {
+		if fsx.Exists(filepath.Join(installAbs, f)) {
+			files = append(files, rootPath(installDir, f))
+		}
+	}
+	for _, p := range packages {
+		files = append(files, rootPath(installDir, p, "package.json"))
+	}
+
+	return nodeProject{
+		InstallDir:     installDir,
+		PackageManager: pm,
+		Files:          files,
+		HasPackageJSON: hasPackageJSON,
+		HasTypeScript:  hasTypeScript,
+		pkg:            pkg,
+		yarnPath:       yarnPath,
+	}, nil
+}
+
+// InstallCommand returns the command that installs the dependencies of
+// the project into dir, the absolute path of the install directory.
+func (p nodeProject) InstallCommand(dir string) string {
+	switch p.PackageManager {
+	case NodePNPM:
+		version := p.pkg.packageManagerVersion("pnpm")
+		if version == "" {
+			version = "6"
+		}
+		return npmCacheMount + " " + pnpmCacheMount + " " + withNPMSecrets(
+			"npm install -g pnpm@"+version+" && cd "+dir+" && pnpm install --frozen-lockfile",
+		)
+
+	case NodeYarnBerry:
+		// Yarn 2+ is usually checked in with the `yarnPath` setting which the
+		// yarn 1 binary of the base image defers to.
+		install := "yarn install --immutable"
+		if p.yarnPath == "" {
+			version := p.pkg.packageManagerVersion("yarn")
+			if version == "" {
+				version = "berry"
+			}
+			install = "yarn set version " + version + " && " + install
+		}
+		// Plug'n'Play can't resolve the dependencies of the compiled task
+		// since it lives outside of the project's workspaces, so dependencies
+		// are always installed into node_modules.
+		return withNPMSecrets("cd " + dir + " && export YARN_NODE_LINKER=node-modules && " + install)
+
+	case NodeYarn:
+		return yarnCacheMount + " " + withNPMSecrets("cd "+dir+" && yarn --non-interactive")
+
+	default:
+		return npmCacheMount + " " + withNPMSecrets("cd "+dir+" && npm install")
+	}
+}
+
+// PruneCommand returns the command that removes the devDependencies of the
+// project, f.e. its TypeScript toolchain, from dir once the task is compiled.
+func (p nodeProject) PruneCommand(dir string) string {
+	switch p.PackageManager {
+	case NodePNPM:
+		return pnpmCacheMount + " cd " + dir + " && pnpm prune --prod"
+
+	case NodeYarnBerry:
+		// `workspaces focus` is part of the workspace-tools plugin, which is
+		// only built into yarn 4+.
+		return withNPMSecrets("cd " + dir + " && export YARN_NODE_LINKER=node-modules && " +
+			"(yarn workspaces focus --all --production || " +
+			"(yarn plugin import workspace-tools && yarn workspaces focus --all --production))")
+
+	case NodeYarn:
+		// Yarn 1 has no prune command, a production install removes
+		// devDependencies instead.
+		return yarnCacheMount + " " + withNPMSecrets("cd "+dir+" && yarn --non-interactive --production")
+
+	default:
+		return "cd " + dir + " && npm prune --production"
+	}
+}
+
+// readYarnPath returns the `yarnPath` setting of the .yarnrc.yml in dir.
+func readYarnPath(dir string) (string, error) {
+	buf, err := ioutil.ReadFile(filepath.Join(dir, ".yarnrc.yml"))
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", errors.Wrap(err, "reading .yarnrc.yml")
+	}
+
+	var rc struct {
+		YarnPath string `yaml:"yarnPath"`
+	}
+	if err := yaml.Unmarshal(buf, &rc); err != nil {
+		return "", errors.Wrap(err, "parsing .yarnrc.yml")
+	}
+	return rc.YarnPath, nil
+}
+
+// rootPath joins elements into a slash-separated path relative to the root.
+func rootPath(elem ...string) string {
+	return strings.TrimPrefix(filepath.ToSlash(filepath.Join(elem...)), "./")
+}
+
+// cut slices s around the first instance of sep.
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
@@ -2,6 +2,7 @@ package build
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
 
 	"github.com/airplanedev/cli/pkg/api"
@@ -112,3 +113,145 @@ func TestGenTSConfig(t *testing.T) {
 		"extends": "../tsconfig.json",
 	}, m)
 }
+
+func TestGenTSConfigPaths(t *testing.T) {
+	require := require.New(t)
+
+	// Path aliases are rebased onto the generated tsconfig.json, which lives
+	// in <root>/.airplane:
+	c, err := GenTSConfig("testdata/node/workspace", "testdata/node/workspace/packages/task/main.ts", nil)
+	require.NoError(err)
+	m := map[string]interface{}{}
+	require.NoError(json.Unmarshal(c, &m))
+	require.Equal("../packages/task/tsconfig.json", m["extends"])
+	opts := m["compilerOptions"].(map[string]interface{})
+	require.Equal("../packages/task", opts["baseUrl"])
+	require.Equal(map[string]interface{}{
+		"@monorepo/lib": []interface{}{"../lib/src"},
+	}, opts["paths"])
+}
+
+func TestNodeDockerfileStages(t *testing.T) {
+	require := require.New(t)
+
+	dockerfile, err := BuildDockerfile(DockerfileConfig{
+		Builder: string(NameNode),
+		Root:    "testdata/secrets/node",
+		Options: api.KindOptions{"shim": "true", "entrypoint": "main.ts", "nodeVersion": "16"},
+	})
+	require.NoError(err)
+
+	// The first element is the syntax directive.
+	stages := strings.Split(dockerfile, "\nFROM ")
+	require.Len(stages, 3)
+	require.Contains(stages[1], "npm install -g typescript")
+	require.Contains(stages[1], "--mount=type=cache,target=/root/.npm")
+
+	// devDependencies are pruned once the task is compiled:
+	require.Greater(strings.Index(stages[1], "npm prune --production"), strings.Index(stages[1], "tsc --pretty"))
+
+	// The final stage only copies the compiled task from the builder:
+	require.NotContains(stages[2], "typescript")
+	require.NotContains(stages[2], "RUN ")
+	require.Contains(stages[2], "COPY --from=builder /airplane /airplane")
+}
+
+func TestNodePackageManagers(t *testing.T) {
+	for _, test := range []struct {
+		root    string
+		pm      NodePackageManager
+		install string
+		prune   string
+		files   []string
+	}{
+		{
+			root:    "testdata/node/npm",
+			pm:      NodeNPM,
+			install: "cd /airplane && npm install",
+			prune:   "cd /airplane && npm prune --production",
+			files:   []string{"package.json", "package-lock.json"},
+		},
+		{
+			root:    "testdata/node/yarn",
+			pm:      NodeYarn,
+			install: "cd /airplane && yarn --non-interactive",
+			prune:   "cd /airplane && yarn --non-interactive --production",
+			files:   []string{"package.json", "yarn.lock"},
+		},
+		{
+			root:    "testdata/node/berry",
+			pm:      NodeYarnBerry,
+			install: "cd /airplane && export YARN_NODE_LINKER=node-modules && yarn install --immutable",
+			prune:   "cd /airplane && export YARN_NODE_LINKER=node-modules && (yarn workspaces focus --all --production",
+			files:   []string{"package.json", "yarn.lock", ".yarnrc.yml", ".yarn/releases"},
+		},
+		{
+			root:    "testdata/node/pnpm",
+			pm:      NodePNPM,
+			install: "npm install -g pnpm@6 && cd /airplane && pnpm install --frozen-lockfile",
+			prune:   "cd /airplane && pnpm prune --prod",
+			files:   []string{"package.json", "pnpm-lock.yaml"},
+		},
+	} {
+		t.Run(string(test.pm), func(t *testing.T) {
+			require := require.New(t)
+
+			pm, err := GetNodePackageManager(test.root)
+			require.NoError(err)
+			require.Equal(test.pm, pm)
+
+			dockerfile, err := BuildDockerfile(DockerfileConfig{
+				Builder: string(NameNode),
+				Root:    test.root,
+				Options: api.KindOptions{"shim": "true", "entrypoint": "main.ts", "nodeVersion": "16"},
+			})
+			require.NoError(err)
+			require.Contains(dockerfile, test.install)
+			require.Contains(dockerfile, test.prune)
+			for _, f := range test.files {
+				require.Contains(dockerfile, `COPY ["`+f+`", "/airplane/`+f+`"]`)
+			}
+		})
+	}
+}
+
+func TestNodeWorkspace(t *testing.T) {
+	require := require.New(t)
+
+	ws, ok, err := NodeWorkspaceRoot("testdata", "testdata/node/workspace/packages/task")
+	require.NoError(err)
+	require.True(ok)
+	require.Equal("testdata/node/workspace", ws)
+
+	_, ok, err = NodeWorkspaceRoot("testdata", "testdata/node/npm")
+	require.NoError(err)
+	require.False(ok)
+
+	dockerfile, err := BuildDockerfile(DockerfileConfig{
+		Builder: string(NameNode),
+		Root:    "testdata/node/workspace",
+		Options: api.KindOptions{
+			"shim":        "true",
+			"entrypoint":  "packages/task/main.ts",
+			"workdir":     "/packages/task",
+			"nodeVersion": "16",
+		},
+	})
+	require.NoError(err)
+
+	// Dependencies are installed from the root of the workspace, with the
+	// manifests of all of its packages:
+	for _, f := range []string{
+		"package.json",
+		"package-lock.json",
+		"packages/lib/package.json",
+		"packages/task/package.json",
+	} {
+		require.Contains(dockerfile, `COPY ["`+f+`", "/airplane/`+f+`"]`)
+	}
+	require.Contains(dockerfile, "cd /airplane && npm install")
+
+	// The task depends on typescript, so its version is used:
+	require.NotContains(dockerfile, "npm install -g typescript")
+	require.Contains(dockerfile, "export PATH=/airplane/packages/task/node_modules/.bin:/airplane/node_modules/.bin:$PATH && tsc --pretty")
+}
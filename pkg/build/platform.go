@@ -0,0 +1,175 @@
+package build
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultPlatform is the platform that images are built for by default.
+const DefaultPlatform = "linux/amd64"
+
+var (
+	// platformRegex matches `os/arch` platforms, f.e. linux/arm64.
+	platformRegex = regexp.MustCompile(`^[a-z0-9]+/[a-z0-9_]+$`)
+	// fromRegex matches the image of a FROM instruction.
+	fromRegex = regexp.MustCompile(`(?i)^(\s*FROM\s+)(\S+)(.*)$`)
+)
+
+// ParsePlatforms parses a comma-separated list of platforms, f.e.
+// `linux/amd64,linux/arm64`. An empty string is the default platform.
+//
+// Platforms that no base image of versions.json is pinned for are rejected,
+// since tasks can't be built for them.
+func ParsePlatforms(s string) ([]string, error) {
+	if s == "" {
+		return []string{DefaultPlatform}, nil
+	}
+
+	supported, err := supportedPlatforms()
+	if err != nil {
+		return nil, err
+	}
+	isSupported := map[string]bool{}
+	for _, p := range supported {
+		isSupported[p] = true
+	}
+
+	var platforms []string
+	seen := map[string]bool{}
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if !platformRegex.MatchString(p) {
+			return nil, errors.Errorf("invalid platform %q: expected os/arch, f.e. linux/arm64", p)
+		}
+		if !isSupported[p] {
+			return nil, errors.Errorf("platform %s is not supported yet: base images are only available for %s", p, strings.Join(supported, ", "))
+		}
+		if !seen[p] {
+			seen[p] = true
+			platforms = append(platforms, p)
+		}
+	}
+	return platforms, nil
+}
+
+// supportedPlatforms returns the default platform, followed by the other
+// platforms that base images of versions.json are pinned for, sorted.
+func supportedPlatforms() ([]string, error) {
+	versions, err := GetVersions()
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for _, vs := range versions {
+		for _, v := range vs {
+			for p, digest := range v.Digests {
+				if digest != "" && p != DefaultPlatform {
+					seen[p] = true
+				}
+			}
+		}
+	}
+
+	platforms := make([]string, 0, len(seen))
+	for p := range seen {
+		platforms = append(platforms, p)
+	}
+	sort.Strings(platforms)
+	return append([]string{DefaultPlatform}, platforms...), nil
+}
+
+// IsDefaultPlatform reports whether platforms only contains the default platform.
+func IsDefaultPlatform(platforms []string) bool {
+	return len(platforms) == 0 || len(platforms) == 1 && platforms[0] == DefaultPlatform
+}
+
+// withPlatforms rewrites the base images of dockerfile that are listed in
+// versions.json so that every platform is built from its own base image.
+//
+// The base image digests in versions.json are specific to linux/amd64. For a
+// single platform, they are swapped for the digests of that platform. For
+// multiple platforms, a stage is declared for each platform and the FROM
+// instruction selects the stage of the target platform.
+func withPlatforms(dockerfile string, platforms []string) (string, error) {
+	if IsDefaultPlatform(platforms) {
+		return dockerfile, nil
+	}
+
+	versions, err := GetVersions()
+	if err != nil {
+		return "", err
+	}
+	byImage := map[string]Version{}
+	for _, vs := range versions {
+		for _, v := range vs {
+			if ref := v.String(); ref != "" {
+				byImage[ref] = v
+			}
+		}
+	}
+
+	lines := strings.Split(dockerfile, "\n")
+	var stages []string
+	stageNames := map[string]string{}
+	for i, line := range lines {
+		m := fromRegex.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		v, ok := byImage[m[2]]
+		if !ok {
+			continue
+		}
+
+		if len(platforms) == 1 {
+			ref, err := platformImage(v, platforms[0])
+			if err != nil {
+				return "", err
+			}
+			lines[i] = m[1] + ref + m[3]
+			continue
+		}
+
+		name, ok := stageNames[m[2]]
+		if !ok {
+			name = fmt.Sprintf("airplane-base-%d", len(stageNames))
+			stageNames[m[2]] = name
+			for _, p := range platforms {
+				ref, err := platformImage(v, p)
+				if err != nil {
+					return "", err
+				}
+				stages = append(stages, fmt.Sprintf("FROM %s AS %s-%s", ref, name, strings.ReplaceAll(p, "/", "-")))
+			}
+		}
+		lines[i] = m[1] + name + "-${TARGETOS}-${TARGETARCH}" + m[3]
+	}
+	if len(stages) == 0 {
+		return strings.Join(lines, "\n"), nil
+	}
+
+	// Parser directives, such as `# syntax=`, must stay at the top.
+	var n int
+	for n < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[n]), "# syntax=") {
+		n++
+	}
+	out := append([]string{}, lines[:n]...)
+	out = append(out, stages...)
+	out = append(out, "")
+	out = append(out, lines[n:]...)
+	return strings.Join(out, "\n"), nil
+}
+
+// platformImage returns the image of v for platform, which must be pinned
+// to a digest.
+func platformImage(v Version, platform string) (string, error) {
+	ref := v.ForPlatform(platform)
+	if ref == "" {
+		return "", errors.Errorf("base image %s:%s is not available for %s", v.Image, v.Tag, platform)
+	}
+	return ref, nil
+}
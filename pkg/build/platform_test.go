@@ -0,0 +1,89 @@
+package build
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/airplanedev/cli/pkg/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePlatforms(t *testing.T) {
+	useTestVersions(t)
+	require := require.New(t)
+
+	platforms, err := ParsePlatforms("")
+	require.NoError(err)
+	require.Equal([]string{"linux/amd64"}, platforms)
+
+	platforms, err = ParsePlatforms("linux/arm64, linux/amd64,linux/arm64")
+	require.NoError(err)
+	require.Equal([]string{"linux/arm64", "linux/amd64"}, platforms)
+
+	_, err = ParsePlatforms("arm64")
+	require.EqualError(err, `invalid platform "arm64": expected os/arch, f.e. linux/arm64`)
+
+	// Platforms that no base image is pinned for can't be built:
+	_, err = ParsePlatforms("linux/amd64,linux/s390x")
+	require.EqualError(err, "platform linux/s390x is not supported yet: base images are only available for linux/amd64, linux/arm64")
+}
+
+func TestVersionForPlatform(t *testing.T) {
+	require := require.New(t)
+
+	v := Version{
+		Image:   "registry.hub.docker.com/library/node",
+		Tag:     "16.2.0-buster",
+		Digest:  "sha256:amd64",
+		Digests: map[string]string{"linux/arm64": "sha256:arm64"},
+	}
+	require.Equal("registry.hub.docker.com/library/node@sha256:amd64", v.ForPlatform(""))
+	require.Equal("registry.hub.docker.com/library/node@sha256:amd64", v.ForPlatform("linux/amd64"))
+	require.Equal("registry.hub.docker.com/library/node@sha256:arm64", v.ForPlatform("linux/arm64"))
+	// Tags are never used instead of digests:
+	require.Empty(v.ForPlatform("linux/s390x"))
+	require.Empty(Version{Image: v.Image, Tag: v.Tag}.ForPlatform("linux/amd64"))
+}
+
+func TestDockerfilePlatforms(t *testing.T) {
+	useTestVersions(t)
+	require := require.New(t)
+
+	v, err := GetVersion(NameNode, "16")
+	require.NoError(err)
+	c := DockerfileConfig{
+		Builder: string(NameNode),
+		Root:    "testdata/secrets/node",
+		Options: api.KindOptions{"shim": "true", "entrypoint": "main.ts", "nodeVersion": "16"},
+	}
+
+	// The default platform uses the amd64 digests:
+	dockerfile, err := BuildDockerfile(c)
+	require.NoError(err)
+	require.Contains(dockerfile, "FROM "+v.String()+" AS builder\n")
+
+	// A single platform swaps the base image:
+	c.Platforms = []string{"linux/arm64"}
+	dockerfile, err = BuildDockerfile(c)
+	require.NoError(err)
+	require.NotContains(dockerfile, v.String())
+	require.Contains(dockerfile, "FROM "+v.ForPlatform("linux/arm64")+" AS builder\n")
+
+	// Multiple platforms select a base stage per target platform:
+	c.Platforms = []string{"linux/amd64", "linux/arm64"}
+	dockerfile, err = BuildDockerfile(c)
+	require.NoError(err)
+	require.True(strings.HasPrefix(dockerfile, strings.Join([]string{
+		"# syntax=docker/dockerfile:1.2",
+		"FROM " + v.String() + " AS airplane-base-0-linux-amd64",
+		"FROM " + v.ForPlatform("linux/arm64") + " AS airplane-base-0-linux-arm64",
+		"",
+		"FROM airplane-base-0-${TARGETOS}-${TARGETARCH} AS builder",
+	}, "\n")), dockerfile)
+	require.Contains(dockerfile, "\nFROM airplane-base-0-${TARGETOS}-${TARGETARCH}\n")
+
+	// Platforms without a pinned base image fail:
+	c.Platforms = []string{"linux/amd64", "linux/s390x"}
+	_, err = BuildDockerfile(c)
+	require.EqualError(err, "base image registry.hub.docker.com/library/node:16.2.0-buster is not available for linux/s390x")
+}
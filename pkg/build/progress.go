@@ -0,0 +1,88 @@
+package build
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	controlapi "github.com/moby/buildkit/api/services/control"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+// buildkitTraceID is the ID of build events that carry BuildKit progress.
+const buildkitTraceID = "moby.buildkit.trace"
+
+// progressWriter prints BuildKit progress in a plain, line-based format
+// similar to `docker build --progress=plain`.
+type progressWriter struct {
+	w       io.Writer
+	steps   map[digest.Digest]int
+	started map[digest.Digest]bool
+	done    map[digest.Digest]bool
+	nextID  int
+}
+
+func newProgressWriter(w io.Writer) *progressWriter {
+	return &progressWriter{
+		w:       w,
+		steps:   map[digest.Digest]int{},
+		started: map[digest.Digest]bool{},
+		done:    map[digest.Digest]bool{},
+	}
+}
+
+// Write prints a single BuildKit status update, aux is the raw
+// aux payload of a `moby.buildkit.trace` build event.
+func (p *progressWriter) Write(aux json.RawMessage) error {
+	var dt []byte
+	if err := json.Unmarshal(aux, &dt); err != nil {
+		return errors.Wrap(err, "unmarshalling buildkit trace")
+	}
+	var status controlapi.StatusResponse
+	if err := status.Unmarshal(dt); err != nil {
+		return errors.Wrap(err, "unmarshalling buildkit status")
+	}
+
+	for _, v := range status.Vertexes {
+		if v.Started == nil || p.done[v.Digest] {
+			continue
+		}
+		id := p.step(v.Digest)
+		if !p.started[v.Digest] {
+			p.started[v.Digest] = true
+			fmt.Fprintf(p.w, "#%d %s\n", id, v.Name)
+		}
+		switch {
+		case v.Cached:
+			fmt.Fprintf(p.w, "#%d CACHED\n", id)
+		case v.Error != "":
+			fmt.Fprintf(p.w, "#%d ERROR: %s\n", id, v.Error)
+		case v.Completed != nil:
+			fmt.Fprintf(p.w, "#%d DONE %.1fs\n", id, v.Completed.Sub(*v.Started).Seconds())
+		default:
+			continue
+		}
+		p.done[v.Digest] = true
+	}
+
+	for _, l := range status.Logs {
+		id := p.step(l.Vertex)
+		for _, line := range strings.Split(strings.TrimRight(string(l.Msg), "\n"), "\n") {
+			fmt.Fprintf(p.w, "#%d %s\n", id, line)
+		}
+	}
+
+	return nil
+}
+
+func (p *progressWriter) step(d digest.Digest) int {
+	id, ok := p.steps[d]
+	if !ok {
+		p.nextID++
+		id = p.nextID
+		p.steps[d] = id
+	}
+	return id
+}
@@ -1,7 +1,9 @@
 package build
 
 import (
+	"bufio"
 	_ "embed"
+	"os"
 	"path/filepath"
 	"strings"
 	"text/template"
@@ -12,6 +14,41 @@ import (
 	"github.com/pkg/errors"
 )
 
+func init() {
+	Register(NamePython, pythonBuilder{})
+}
+
+// pythonBuilder builds Python tasks.
+type pythonBuilder struct{}
+
+// Dockerfile implementation.
+func (pythonBuilder) Dockerfile(root string, options api.KindOptions) (string, error) {
+	return python(root, options)
+}
+
+// Root implementation.
+//
+// The root is the closest parent directory that contains one of the files
+// of a Python project, f.e. requirements.txt or pyproject.toml.
+func (pythonBuilder) Root(path string) (string, error) {
+	return findRoot(path, PythonProjectFiles...), nil
+}
+
+// Workdir implementation.
+func (b pythonBuilder) Workdir(path string) (string, error) {
+	return b.Root(path)
+}
+
+// Shim implementation.
+func (pythonBuilder) Shim(root, entrypoint string) (string, error) {
+	return PythonShim(root, entrypoint)
+}
+
+// NeedsBuilding implementation.
+func (pythonBuilder) NeedsBuilding() bool {
+	return true
+}
+
 // Python creates a dockerfile for Python.
 func python(root string, args api.KindOptions) (string, error) {
 	if args["shim"] != "true" {
@@ -24,7 +61,13 @@ func python(root string, args api.KindOptions) (string, error) {
 		return "", err
 	}
 
-	v, err := GetVersion(NamePython, "3")
+	pythonVersion, _ := args["pythonVersion"].(string)
+	base, err := getBasePythonImage(pythonVersion)
+	if err != nil {
+		return "", err
+	}
+
+	deps, err := getPythonDependencies(root)
 	if err != nil {
 		return "", err
 	}
@@ -35,6 +78,7 @@ func python(root string, args api.KindOptions) (string, error) {
 	}
 
 	dockerfile := heredoc.Doc(`
+		# syntax=docker/dockerfile:1.2
 		FROM {{ .Base }}
 
 		# Install common OS dependencies
@@ -45,11 +89,15 @@ func python(root string, args api.KindOptions) (string, error) {
 
 		WORKDIR /airplane
 		RUN mkdir -p .airplane && {{.InlineShim}} > .airplane/shim.py
-		{{if .HasRequirements}}
-		COPY requirements.txt .
-		RUN pip install -r requirements.txt
+		{{if and .InstallCommand (not .NeedsSource)}}
+		COPY {{.DependencyFiles}} ./
+		# Support setting BUILD_PIP_CONF or BUILD_PIP_INDEX_URL to configure private package indexes
+		RUN {{.InstallCommand}}
 		{{end}}
 		COPY . .
+		{{if and .InstallCommand .NeedsSource}}
+		RUN {{.InstallCommand}}
+		{{end}}
 		ENV PYTHONUNBUFFERED=1
 		ENTRYPOINT ["python", ".airplane/shim.py"]
 	`)
@@ -57,11 +105,15 @@ func python(root string, args api.KindOptions) (string, error) {
 	df, err := applyTemplate(dockerfile, struct {
 		Base            string
 		InlineShim      string
-		HasRequirements bool
+		DependencyFiles string
+		InstallCommand  string
+		NeedsSource     bool
 	}{
-		Base:            v.String(),
+		Base:            base,
 		InlineShim:      inlineString(shim),
-		HasRequirements: fsx.Exists(filepath.Join(root, "requirements.txt")),
+		DependencyFiles: strings.Join(deps.Files, " "),
+		InstallCommand:  deps.InstallCommand,
+		NeedsSource:     deps.NeedsSource,
 	})
 	if err != nil {
 		return "", errors.Wrapf(err, "rendering dockerfile")
@@ -93,43 +145,214 @@ func PythonShim(taskRoot, entrypoint string) (string, error) {
 func pythonLegacy(root string, args api.KindOptions) (string, error) {
 	var entrypoint, _ = args["entrypoint"].(string)
 	var main = filepath.Join(root, entrypoint)
-	var reqs = filepath.Join(root, "requirements.txt")
 
 	if err := fsx.AssertExistsAll(main); err != nil {
 		return "", err
 	}
 
 	t, err := template.New("python").Parse(heredoc.Doc(`
+		# syntax=docker/dockerfile:1.2
 		FROM {{ .Base }}
 		WORKDIR /airplane
-		{{if not .HasRequirements}}
-		RUN echo > requirements.txt
-		{{end}}
 		COPY . .
-		RUN pip install -r requirements.txt
+		{{if .InstallCommand}}
+		RUN {{ .InstallCommand }}
+		{{end}}
 		ENTRYPOINT ["python", "/airplane/{{ .Entrypoint }}"]
 	`))
 	if err != nil {
 		return "", err
 	}
 
-	v, err := GetVersion(NamePython, "3")
+	pythonVersion, _ := args["pythonVersion"].(string)
+	base, err := getBasePythonImage(pythonVersion)
+	if err != nil {
+		return "", err
+	}
+
+	deps, err := getPythonDependencies(root)
 	if err != nil {
 		return "", err
 	}
 
 	var buf strings.Builder
 	if err := t.Execute(&buf, struct {
-		Base            string
-		Entrypoint      string
-		HasRequirements bool
+		Base           string
+		Entrypoint     string
+		InstallCommand string
 	}{
-		Base:            v.String(),
-		Entrypoint:      entrypoint,
-		HasRequirements: fsx.AssertExistsAll(reqs) == nil,
+		Base:           base,
+		Entrypoint:     entrypoint,
+		InstallCommand: deps.InstallCommand,
 	}); err != nil {
 		return "", err
 	}
 
 	return buf.String(), nil
 }
+
+// PythonPackageManager is a tool that installs the dependencies of a Python
+// project.
+type PythonPackageManager string
+
+const (
+	PythonPip    PythonPackageManager = "pip"
+	PythonPoetry PythonPackageManager = "poetry"
+	PythonPipenv PythonPackageManager = "pipenv"
+)
+
+// PythonProjectFiles are the files that mark the root of a Python project.
+var PythonProjectFiles = []string{
+	"requirements.txt",
+	"pyproject.toml",
+	"poetry.lock",
+	"Pipfile",
+	"Pipfile.lock",
+}
+
+// Versions of the package managers that are installed into images.
+const (
+	poetryVersion = "1.1.6"
+	pipenvVersion = "2021.5.29"
+)
+
+// GetPythonPackageManager returns the package manager of the Python project
+// at root, or an empty string if the project has no dependencies.
+func GetPythonPackageManager(root string) (PythonPackageManager, error) {
+	switch {
+	case fsx.Exists(filepath.Join(root, "poetry.lock")):
+		return PythonPoetry, nil
+	case fsx.Exists(filepath.Join(root, "Pipfile.lock")), fsx.Exists(filepath.Join(root, "Pipfile")):
+		return PythonPipenv, nil
+	}
+
+	var sections map[string]bool
+	if fsx.Exists(filepath.Join(root, "pyproject.toml")) {
+		var err error
+		if sections, err = readPyprojectSections(filepath.Join(root, "pyproject.toml")); err != nil {
+			return "", err
+		}
+	}
+
+	switch {
+	case sections["tool.poetry"]:
+		return PythonPoetry, nil
+	case fsx.Exists(filepath.Join(root, "requirements.txt")):
+		return PythonPip, nil
+	case sections["project"], sections["build-system"]:
+		// The project is installable with pip, f.e. with setuptools or flit.
+		return PythonPip, nil
+	default:
+		return "", nil
+	}
+}
+
+// readPyprojectSections returns the table headers of the pyproject.toml at
+// path, f.e. "tool.poetry".
+func readPyprojectSections(path string) (map[string]bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening pyproject.toml")
+	}
+	defer f.Close()
+
+	sections := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			sections[strings.Trim(line, "[] ")] = true
+		}
+	}
+	return sections, errors.Wrap(scanner.Err(), "reading pyproject.toml")
+}
+
+// pythonDependencies describes how to install the dependencies of a Python
+// project.
+type pythonDependencies struct {
+	// Files are copied into the image before installing the dependencies,
+	// so that the install is cached until one of them changes.
+	Files []string
+	// InstallCommand installs the dependencies, it is empty if the project
+	// has none.
+	InstallCommand string
+	// NeedsSource is true if the install requires the whole project, f.e.
+	// when pip builds a pyproject.toml project.
+	NeedsSource bool
+}
+
+// getPythonDependencies returns how to install the dependencies of the
+// Python project at root. Lock files are always respected so that images
+// are built with the locked versions.
+func getPythonDependencies(root string) (pythonDependencies, error) {
+	pm, err := GetPythonPackageManager(root)
+	if err != nil {
+		return pythonDependencies{}, err
+	}
+
+	existing := func(files ...string) []string {
+		var found []string
+		for _, f := range files {
+			if fsx.Exists(filepath.Join(root, f)) {
+				found = append(found, f)
+			}
+		}
+		return found
+	}
+
+	switch pm {
+	case PythonPoetry:
+		return pythonDependencies{
+			Files: existing("pyproject.toml", "poetry.lock"),
+			InstallCommand: pipCacheMount + " " + poetryCacheMount + " " + withPipSecrets(
+				"pip install poetry=="+poetryVersion+
+					" && poetry config virtualenvs.create false"+
+					" && poetry install --no-root --no-dev --no-interaction --no-ansi",
+			),
+		}, nil
+
+	case PythonPipenv:
+		install := "pipenv install --system --deploy"
+		if !fsx.Exists(filepath.Join(root, "Pipfile.lock")) {
+			install = "pipenv install --system --skip-lock"
+		}
+		return pythonDependencies{
+			Files: existing("Pipfile", "Pipfile.lock"),
+			InstallCommand: pipCacheMount + " " + pipenvCacheMount + " " + withPipSecrets(
+				"pip install pipenv=="+pipenvVersion+" && "+install,
+			),
+		}, nil
+
+	case PythonPip:
+		if fsx.Exists(filepath.Join(root, "requirements.txt")) {
+			return pythonDependencies{
+				Files:          []string{"requirements.txt"},
+				InstallCommand: pipCacheMount + " " + withPipSecrets("pip install -r requirements.txt"),
+			}, nil
+		}
+		return pythonDependencies{
+			InstallCommand: pipCacheMount + " " + withPipSecrets("pip install ."),
+			NeedsSource:    true,
+		}, nil
+
+	default:
+		return pythonDependencies{}, nil
+	}
+}
+
+// getBasePythonImage returns the base image for the given Python version,
+// defaulting to the latest supported Python 3.
+func getBasePythonImage(version string) (string, error) {
+	if version == "" {
+		version = "3"
+	}
+	base, err := getImage(NamePython, version)
+	if err != nil {
+		return "", err
+	}
+	if base == "" {
+		return "", errors.Errorf("unsupported python version %q", version)
+	}
+
+	return base, nil
+}
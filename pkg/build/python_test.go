@@ -0,0 +1,123 @@
+package build
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/airplanedev/cli/pkg/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPythonDependencies(t *testing.T) {
+	for _, test := range []struct {
+		root    string
+		pm      PythonPackageManager
+		copy    string
+		install string
+	}{
+		{
+			root:    "testdata/python/poetry",
+			pm:      PythonPoetry,
+			copy:    "COPY pyproject.toml poetry.lock ./",
+			install: "poetry install --no-root --no-dev",
+		},
+		{
+			root:    "testdata/python/pipenv",
+			pm:      PythonPipenv,
+			copy:    "COPY Pipfile Pipfile.lock ./",
+			install: "pipenv install --system --deploy",
+		},
+		{
+			root:    "testdata/python/requirements",
+			pm:      PythonPip,
+			copy:    "COPY requirements.txt ./",
+			install: "pip install -r requirements.txt",
+		},
+		{
+			root:    "testdata/python/pyproject",
+			pm:      PythonPip,
+			install: "pip install .",
+		},
+		{
+			root: "testdata/python/none",
+		},
+	} {
+		t.Run(test.root, func(t *testing.T) {
+			require := require.New(t)
+
+			pm, err := GetPythonPackageManager(test.root)
+			require.NoError(err)
+			require.Equal(test.pm, pm)
+
+			dockerfile, err := BuildDockerfile(DockerfileConfig{
+				Builder: string(NamePython),
+				Root:    test.root,
+				Options: api.KindOptions{"shim": "true", "entrypoint": "main.py"},
+			})
+			require.NoError(err)
+
+			instrs := instructions(dockerfile)
+			var copyIdx, sourceIdx, installIdx = -1, -1, -1
+			for i, instr := range instrs {
+				switch {
+				case test.copy != "" && instr == test.copy:
+					copyIdx = i
+				case instr == "COPY . .":
+					sourceIdx = i
+				case strings.HasPrefix(instr, "RUN --mount=type=cache"):
+					installIdx = i
+				}
+			}
+			require.NotEqual(-1, sourceIdx, dockerfile)
+
+			if test.install == "" {
+				require.Equal(-1, installIdx, dockerfile)
+				return
+			}
+			require.NotEqual(-1, installIdx, dockerfile)
+			require.Contains(instrs[installIdx], test.install)
+
+			if test.copy != "" {
+				// Dependencies are installed before the source is copied so
+				// the install is cached until the dependency files change.
+				require.True(copyIdx < installIdx && installIdx < sourceIdx, dockerfile)
+			} else {
+				require.True(sourceIdx < installIdx, dockerfile)
+			}
+		})
+	}
+}
+
+func TestPythonVersion(t *testing.T) {
+	useTestVersions(t)
+	require := require.New(t)
+
+	for version, tag := range map[string]string{
+		"":    "3.9.4-buster",
+		"3":   "3.9.4-buster",
+		"3.8": "3.8.10-buster",
+		"3.7": "3.7.10-buster",
+	} {
+		dockerfile, err := BuildDockerfile(DockerfileConfig{
+			Builder: string(NamePython),
+			Root:    "testdata/python/requirements",
+			Options: api.KindOptions{"shim": "true", "entrypoint": "main.py", "pythonVersion": version},
+		})
+		require.NoError(err)
+
+		v, err := GetVersion(NamePython, version)
+		if version == "" {
+			v, err = GetVersion(NamePython, "3")
+		}
+		require.NoError(err)
+		require.Equal(tag, v.Tag)
+		require.Contains(dockerfile, "FROM "+v.String()+"\n")
+	}
+
+	_, err := BuildDockerfile(DockerfileConfig{
+		Builder: string(NamePython),
+		Root:    "testdata/python/requirements",
+		Options: api.KindOptions{"shim": "true", "entrypoint": "main.py", "pythonVersion": "2.7"},
+	})
+	require.Error(err)
+}
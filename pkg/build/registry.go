@@ -0,0 +1,118 @@
+package build
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/airplanedev/cli/pkg/api"
+	"github.com/airplanedev/cli/pkg/fsx"
+	"github.com/pkg/errors"
+)
+
+// ErrNoShim is returned by builders whose tasks run without a shim, f.e.
+// because they are compiled into a binary that receives the params.
+//
+// It can be checked via `errors.Is(err, ErrNoShim)`.
+var ErrNoShim = errors.New("build: builder does not use a shim")
+
+// Builder builds the tasks of a kind.
+//
+// Builders are registered with Register, usually from the init function
+// of the file or package that implements them.
+type Builder interface {
+	// Dockerfile generates a Dockerfile that builds the task at root with
+	// the given kind options. Root is the build context of the Dockerfile.
+	Dockerfile(root string, options api.KindOptions) (string, error)
+
+	// Root detects the root of the task at path, the directory that is
+	// archived as its build context.
+	//
+	// Typically builders look for a specific file such as `package.json`
+	// or `Gemfile` with `fsx.Find()`.
+	Root(path string) (dir string, err error)
+
+	// Workdir detects the directory that the build commands of the task
+	// at path run in. It's either its root or a directory within it.
+	Workdir(path string) (dir string, err error)
+
+	// Shim returns the shim that runs the entrypoint of a task, relative
+	// to root, with the params of a run. Runtimes use it to run tasks
+	// locally the same way that their images do.
+	//
+	// If the builder doesn't use a shim, it returns ErrNoShim.
+	Shim(root, entrypoint string) (string, error)
+
+	// NeedsBuilding reports whether tasks of the kind need to be built.
+	// Tasks that run a pre-built image don't.
+	NeedsBuilding() bool
+}
+
+// builders is a collection of registered builders.
+var builders = make(map[Name]Builder)
+
+// Register registers the builder of tasks of kind name.
+func Register(name Name, b Builder) {
+	if _, ok := builders[name]; ok {
+		panic(fmt.Sprintf("build: %s already registered", name))
+	}
+	builders[name] = b
+}
+
+// Lookup returns the builder of tasks of kind name.
+func Lookup(name Name) (Builder, error) {
+	b, ok := builders[name]
+	if !ok {
+		return nil, errors.Errorf("build: unknown builder type %q", name)
+	}
+	return b, nil
+}
+
+// Names returns the names of the registered builders, sorted.
+func Names() []Name {
+	var names []Name
+	for name := range builders {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+func NeedsBuilding(kind api.TaskKind) (bool, error) {
+	b, err := Lookup(Name(kind))
+	if err != nil {
+		return false, errors.Errorf("NeedsBuilding got unexpected kind %s", kind)
+	}
+	return b.NeedsBuilding(), nil
+}
+
+func BuildDockerfile(c DockerfileConfig) (string, error) {
+	b, err := Lookup(Name(c.Builder))
+	if err != nil {
+		return "", err
+	}
+	df, err := b.Dockerfile(c.Root, c.Options)
+	if err != nil {
+		return "", err
+	}
+	if Name(c.Builder) == NameDockerfile {
+		// User-provided Dockerfiles pick their own base images.
+		return df, nil
+	}
+	return withPlatforms(df, c.Platforms)
+}
+
+// findRoot returns the closest parent directory of path that contains
+// one of names, or the directory of path if there is none.
+func findRoot(path string, names ...string) string {
+	var root string
+	for _, name := range names {
+		if dir, ok := fsx.Find(path, name); ok && len(dir) > len(root) {
+			root = dir
+		}
+	}
+	if root == "" {
+		return filepath.Dir(path)
+	}
+	return root
+}
@@ -0,0 +1,43 @@
+package build
+
+import (
+	"testing"
+
+	"github.com/airplanedev/cli/pkg/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal([]Name{
+		NameDeno,
+		NameDockerfile,
+		NameGo,
+		NameImage,
+		NameJava,
+		NameNode,
+		NamePython,
+		NameRuby,
+		NameShell,
+	}, Names())
+
+	for _, name := range Names() {
+		ok, err := NeedsBuilding(api.TaskKind(name))
+		require.NoError(err)
+		require.Equal(name != NameImage, ok, name)
+	}
+	ok, err := NeedsBuilding(api.TaskKindJava)
+	require.NoError(err)
+	require.True(ok)
+	_, err = NeedsBuilding(api.TaskKindSQL)
+	require.Error(err)
+
+	_, err = BuildDockerfile(DockerfileConfig{Builder: "cobol"})
+	require.Error(err)
+	require.Contains(err.Error(), `unknown builder type "cobol"`)
+
+	require.Panics(func() {
+		Register(NameGo, goBuilder{})
+	})
+}
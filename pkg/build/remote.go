@@ -2,26 +2,40 @@ package build
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
-	"net/http"
 	"os"
-	"path"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"time"
 
-	"github.com/airplanedev/archiver"
 	"github.com/airplanedev/cli/pkg/api"
 	"github.com/airplanedev/cli/pkg/build/ignore"
 	"github.com/airplanedev/cli/pkg/logger"
 	"github.com/airplanedev/cli/pkg/taskdir/definitions"
 	"github.com/airplanedev/cli/pkg/utils"
 	"github.com/dustin/go-humanize"
+	"github.com/mattn/go-isatty"
 	"github.com/pkg/errors"
 )
 
+// cancelBuildTimeout is how long cancelling a remote build may take.
+const cancelBuildTimeout = 10 * time.Second
+
 func remote(ctx context.Context, req Request) (*Response, error) {
+	if !IsDefaultPlatform(req.Platforms) {
+		return nil, errors.Errorf("remote builds only support %s, build with --local to target %s", DefaultPlatform, strings.Join(req.Platforms, ", "))
+	}
+	if req.Tag != "" {
+		return nil, errors.New("remote builds are tagged with their build ID, build with --local to set a tag")
+	}
+	if req.SkipPush {
+		return nil, errors.New("remote builds are always pushed, build with --local to skip pushing")
+	}
+
 	if err := confirmBuildRoot(req.Root); err != nil {
 		return nil, err
 	}
@@ -29,29 +43,48 @@ func remote(ctx context.Context, req Request) (*Response, error) {
 
 	// Before performing a remote build, we must first update kind/kindOptions
 	// since the remote build relies on pulling those from the tasks table (for now).
-	if err := updateKindAndOptions(ctx, req.Client, req.Def, req.Shim); err != nil {
+	if req.ReadOnly {
+		if err := checkKindAndOptions(ctx, req.Client, req.Def, req.Shim); err != nil {
+			return nil, err
+		}
+	} else if err := updateKindAndOptions(ctx, req.Client, req.Def, req.Shim); err != nil {
 		return nil, err
 	}
 
+	if req.DockerfileOutput != "" {
+		// The builder generates its own Dockerfile from the task's kind and
+		// kind options. This one is generated the same way by the CLI, which
+		// may be older or newer than the builder.
+		if err := writeDockerfile(req, req.DockerfileOutput); err != nil {
+			return nil, err
+		}
+		buildLog(api.LogLevelInfo, logger.Gray("Wrote the Dockerfile that this CLI generates to %s, the remote builder generates its own.", req.DockerfileOutput))
+	}
+
 	buildLog(api.LogLevelInfo, logger.Gray("Authenticating with Airplane..."))
 	registry, err := req.Client.GetRegistryToken(ctx)
 	if err != nil {
 		return nil, errors.Wrap(err, "getting registry token")
 	}
 
-	tmpdir, err := ioutil.TempDir("", "airplane-builds-")
+	include, err := ignore.Func(req.Root)
 	if err != nil {
-		return nil, errors.Wrap(err, "creating temporary directory for remote build")
+		return nil, err
 	}
-	defer os.RemoveAll(tmpdir)
-
-	archivePath := path.Join(tmpdir, "archive.tar.gz")
-	buildLog(api.LogLevelInfo, logger.Gray("Packaging and uploading %s to build the task...", req.Root))
-	if err := archiveTaskDir(req.Def, req.Root, archivePath); err != nil {
+	bc, err := checkContext(req.Root, include, req.Context)
+	if err != nil {
 		return nil, err
 	}
+	archive := contextArchive{root: req.Root, include: include}
 
-	uploadID, err := uploadArchive(ctx, req.Root, req.Client, archivePath)
+	if req.ContextOutput != "" {
+		if err := archive.extract(req.ContextOutput); err != nil {
+			return nil, err
+		}
+	}
+
+	buildLog(api.LogLevelInfo, logger.Gray("Packaging and uploading %s to build the task...", req.Root))
+	uploadID, err := uploadArchive(ctx, req.Client, archive, bc)
 	if err != nil {
 		return nil, err
 	}
@@ -67,6 +100,11 @@ func remote(ctx context.Context, req Request) (*Response, error) {
 	logger.Debug("Created build with id=%s", build.Build.ID)
 
 	if err := waitForBuild(ctx, req.Client, build.Build.ID); err != nil {
+		if ctx.Err() != nil {
+			// The build was interrupted or timed out: stop the builder too,
+			// instead of leaving it running.
+			cancelBuild(req.Client, build.Build.ID)
+		}
 		return nil, err
 	}
 
@@ -125,72 +163,110 @@ func updateKindAndOptions(ctx context.Context, client *api.Client, def definitio
 	return nil
 }
 
-func archiveTaskDir(def definitions.Definition, root string, archivePath string) error {
-	// mholt/archiver takes a list of "sources" (files/directories) that will
-	// be included in the root of the archive. In our case, we want the root of
-	// the archive to be the contents of the task directory, rather than the
-	// task directory itself.
-	var sources []string
-	if files, err := ioutil.ReadDir(root); err != nil {
-		return errors.Wrap(err, "inspecting files in task root")
-	} else {
-		for _, f := range files {
-			sources = append(sources, path.Join(root, f.Name()))
-		}
+// checkKindAndOptions verifies that the deployed task is built with the same
+// kind and kind options as def, since remote builds read them from the task.
+func checkKindAndOptions(ctx context.Context, client *api.Client, def definitions.Definition, shim bool) error {
+	task, err := client.GetTask(ctx, def.Slug)
+	if err != nil {
+		return err
 	}
-
-	var err error
-	arch := archiver.NewTarGz()
-	arch.Tar.IncludeFunc, err = ignore.Func(root)
+	remote, err := definitions.NewDefinitionFromTask(task)
 	if err != nil {
 		return err
 	}
 
-	if err := arch.Archive(sources, archivePath); err != nil {
-		return errors.Wrap(err, "building archive")
+	kind, kindOptions, err := def.GetKindAndOptions()
+	if err != nil {
+		return err
+	}
+	remoteKind, remoteOptions, err := remote.GetKindAndOptions()
+	if err != nil {
+		return err
 	}
+	remoteShim := task.KindOptions["shim"] == "true"
 
+	if kind != remoteKind || shim != remoteShim || !reflect.DeepEqual(normalizeOptions(kindOptions), normalizeOptions(remoteOptions)) {
+		return errors.Errorf("the build configuration of %s differs from the deployed task: deploy it first or build with --local", def.Slug)
+	}
 	return nil
 }
 
-func uploadArchive(ctx context.Context, root string, client *api.Client, archivePath string) (string, error) {
-	archive, err := os.OpenFile(archivePath, os.O_RDONLY, 0)
-	if err != nil {
-		return "", errors.Wrap(err, "opening archive file")
+// normalizeOptions converts kind options into their JSON representation so
+// that f.e. options read from a definition and from the API compare as equal.
+func normalizeOptions(options api.KindOptions) map[string]interface{} {
+	out := map[string]interface{}{}
+	if buf, err := json.Marshal(options); err == nil {
+		_ = json.Unmarshal(buf, &out)
 	}
-	defer archive.Close()
+	return out
+}
 
-	info, err := archive.Stat()
+// writeDockerfile writes the Dockerfile that req is built with to path.
+//
+// For remote builds, it's the Dockerfile that this CLI generates, which
+// only matches the builder's if both generate Dockerfiles the same way.
+func writeDockerfile(req Request, path string) error {
+	kind, options, err := req.Def.GetKindAndOptions()
 	if err != nil {
-		return "", errors.Wrap(err, "stat on archive file")
+		return err
 	}
-	sizeBytes := int(info.Size())
-
-	buildLog(api.LogLevelInfo, logger.Gray("Uploading %s build archive...",
-		humanize.Bytes(uint64(sizeBytes)),
-	))
+	if req.Shim {
+		options["shim"] = "true"
+	}
+	dockerfile, err := BuildDockerfile(DockerfileConfig{
+		Builder:   string(kind),
+		Root:      req.Root,
+		Options:   options,
+		Platforms: req.Platforms,
+	})
+	if err != nil {
+		return errors.Wrap(err, "creating dockerfile")
+	}
+	if err := ioutil.WriteFile(path, []byte(dockerfile), 0644); err != nil {
+		return errors.Wrap(err, "writing dockerfile")
+	}
+	return nil
+}
 
+// uploadArchive streams the archive of bc to a new upload and returns its ID.
+func uploadArchive(ctx context.Context, client *api.Client, archive contextArchive, bc buildContext) (string, error) {
+	maxSize := bc.maxArchiveSize()
 	upload, err := client.CreateBuildUpload(ctx, api.CreateBuildUploadRequest{
-		SizeBytes: sizeBytes,
+		SizeBytes: int(maxSize),
+		Resumable: true,
 	})
 	if err != nil {
 		return "", errors.Wrap(err, "creating upload")
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "PUT", upload.WriteOnlyURL, archive)
-	if err != nil {
-		return "", errors.Wrap(err, "creating GCS upload request")
+	var progress io.Writer
+	if isatty.IsTerminal(os.Stderr.Fd()) {
+		progress = os.Stderr
+	}
+	bar := newProgressBar(progress, int64(bc.Size))
+	archive.progress = bar.add
+
+	var stream *archiveStream
+	err = newUploader().upload(ctx, upload.WriteOnlyURL, int64(maxSize), func() io.ReadCloser {
+		bar.reset()
+		stream = archive.stream()
+		return stream
+	})
+	bar.done()
+	if stream == nil {
+		return "", errors.Wrap(err, "uploading build archive")
+	}
+	// Streams are closed when the upload fails, which fails writing them.
+	size, digest, serr := stream.result()
+	if serr != nil && !errors.Is(serr, io.ErrClosedPipe) {
+		return "", serr
 	}
-	req.Header.Add("X-Goog-Content-Length-Range", fmt.Sprintf("0,%d", sizeBytes))
-
-	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return "", errors.Wrap(err, "uploading to GCS")
+		return "", errors.Wrap(err, "uploading build archive")
 	}
-	defer resp.Body.Close()
 
+	buildLog(api.LogLevelInfo, logger.Gray("Uploaded %s build archive %s", humanize.Bytes(uint64(size)), digest))
 	logger.Debug("Upload complete: %s", upload.Upload.URL)
-
 	return upload.Upload.ID, nil
 }
 
@@ -248,6 +324,21 @@ func waitForBuild(ctx context.Context, client *api.Client, buildID string) error
 	}
 }
 
+// cancelBuild cancels a remote build. Since it's called once the build's
+// context is done, it uses a context of its own.
+func cancelBuild(client *api.Client, buildID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), cancelBuildTimeout)
+	defer cancel()
+
+	buildLog(api.LogLevelInfo, logger.Gray("Cancelling build..."))
+	if err := client.CancelBuild(ctx, buildID); err != nil {
+		logger.Warning("Unable to cancel build %s: %v", buildID, err)
+		logger.Log("Cancel it with: airplane builds cancel %s", buildID)
+		return
+	}
+	logger.Log("\nBuild " + logger.Bold(logger.Yellow("cancelled")))
+}
+
 func buildLog(level api.LogLevel, msg string, args ...interface{}) {
 	if level == api.LogLevelDebug {
 		logger.Log("["+logger.Yellow("build")+"] ["+logger.Blue("debug")+"] "+msg, args...)
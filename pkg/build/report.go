@@ -0,0 +1,152 @@
+package build
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/airplanedev/cli/pkg/api"
+	"github.com/airplanedev/cli/pkg/build/sbom"
+	"github.com/airplanedev/cli/pkg/logger"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	dockerJSONMessage "github.com/docker/docker/pkg/jsonmessage"
+	"github.com/pkg/errors"
+)
+
+// SBOMOptions configures the software bill of materials (SBOM) of a built
+// image, and the vulnerability check against it.
+type SBOMOptions struct {
+	// Output is the path that the SBOM is written to, if set.
+	Output string
+
+	// Format is the format of the SBOM, defaults to SPDX.
+	Format sbom.Format
+
+	// VulnDB is the path of a local vulnerability database that the SBOM
+	// is checked against, if set. See sbom.Database.
+	VulnDB string
+
+	// FailOn fails the build if the image has vulnerabilities of this
+	// severity or higher. Requires VulnDB.
+	FailOn sbom.Severity
+}
+
+// report generates the SBOM of the image of resp and checks it for
+// vulnerabilities, as configured by opts.
+//
+// The SBOM lists the OS packages of the image, which is pulled if it isn't
+// in the local daemon, and the dependencies of the lockfiles in root.
+func report(ctx context.Context, c *api.Client, root string, resp *Response, opts SBOMOptions) error {
+	if opts.FailOn != "" && opts.VulnDB == "" {
+		return errors.New("failing on vulnerabilities requires a vulnerability database")
+	}
+	if opts.Format == "" {
+		opts.Format = sbom.FormatSPDX
+	}
+
+	logger.Log("Generating SBOM...")
+	docker, err := client.NewClientWithOpts(
+		client.FromEnv,
+		client.WithAPIVersionNegotiation(),
+	)
+	if err != nil {
+		return errors.Wrap(err, "creating docker client")
+	}
+	defer docker.Close()
+
+	if err := ensureImage(ctx, docker, c, resp.ImageURL); err != nil {
+		return err
+	}
+	osPackages, err := sbom.OSPackages(ctx, docker, resp.ImageURL)
+	if err != nil {
+		return errors.Wrap(err, "reading OS packages")
+	}
+	deps, err := sbom.FromLockfiles(root)
+	if err != nil {
+		return errors.Wrap(err, "reading lockfiles")
+	}
+
+	s := sbom.SBOM{Image: resp.ImageURL, Created: time.Now()}
+	s.Add(osPackages...)
+	s.Add(deps...)
+
+	if opts.Output != "" {
+		f, err := os.Create(opts.Output)
+		if err != nil {
+			return errors.Wrap(err, "creating SBOM file")
+		}
+		defer f.Close()
+		if err := s.Write(f, opts.Format); err != nil {
+			return err
+		}
+		logger.Log("Wrote SBOM with %d components to %s", len(s.Components), opts.Output)
+	}
+
+	if opts.VulnDB == "" {
+		return nil
+	}
+	db, err := sbom.ReadDatabase(opts.VulnDB)
+	if err != nil {
+		return err
+	}
+	findings := db.Match(s)
+	if len(findings) == 0 {
+		logger.Log("No known vulnerabilities found.")
+		return nil
+	}
+
+	var failed int
+	logger.Log("Found %d known vulnerabilities:", len(findings))
+	for _, f := range findings {
+		logger.Log("  %-8s  %s  %s %s", strings.ToUpper(string(f.Vulnerability.Severity)), f.Vulnerability.ID, f.Component.Name, f.Component.Version)
+		if opts.FailOn != "" && f.Vulnerability.Severity.AtLeast(opts.FailOn) {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return errors.Errorf("%s has %d vulnerabilities of severity %s or higher", resp.ImageURL, failed, opts.FailOn)
+	}
+	return nil
+}
+
+// ensureImage pulls image into the daemon, unless it is present already
+// f.e. after a local build.
+func ensureImage(ctx context.Context, docker *client.Client, c *api.Client, image string) error {
+	if _, _, err := docker.ImageInspectWithRaw(ctx, image); err == nil {
+		return nil
+	} else if !client.IsErrNotFound(err) {
+		return errors.Wrap(err, "inspecting image")
+	}
+
+	registry, err := c.GetRegistryToken(ctx)
+	if err != nil {
+		return errors.Wrap(err, "getting registry token")
+	}
+	auth := RegistryAuth{Token: registry.Token, Repo: registry.Repo}
+	var authConfig types.AuthConfig
+	if strings.SplitN(image, "/", 2)[0] == auth.host() {
+		authConfig = auth.authConfig()
+	}
+	authjson, err := json.Marshal(authConfig)
+	if err != nil {
+		return err
+	}
+
+	logger.Log("Pulling %s...", image)
+	rc, err := docker.ImagePull(ctx, image, types.ImagePullOptions{
+		RegistryAuth: base64.URLEncoding.EncodeToString(authjson),
+	})
+	if err != nil {
+		return errors.Wrap(err, "pulling image")
+	}
+	defer rc.Close()
+	if err := dockerJSONMessage.DisplayJSONMessagesStream(rc, ioutil.Discard, 0, false, nil); err != nil {
+		return errors.Wrap(err, "pulling image")
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+# This file includes a shim that will execute your task code.
+
+require "json"
+
+def run(args)
+  $LOAD_PATH.unshift("{{.TaskRoot}}")
+
+  if args.length != 1
+    raise "usage: ruby ./shim.rb <args>"
+  end
+
+  # Tasks with a Gemfile run with the gems that it locks, also when the
+  # shim isn't run with `bundle exec`.
+  gemfile = File.join("{{.TaskRoot}}", "Gemfile")
+  if File.exist?(gemfile)
+    ENV["BUNDLE_GEMFILE"] ||= gemfile
+    require "bundler/setup"
+  end
+
+  load File.join("{{.TaskRoot}}", "{{.Entrypoint}}")
+  unless respond_to?(:main, true)
+    raise "{{.Entrypoint}} must define a main(params) method"
+  end
+
+  begin
+    main(JSON.parse(args[0]))
+  rescue StandardError
+    warn "executing {{.Entrypoint}}"
+    raise
+  end
+end
+
+run(ARGV)
@@ -0,0 +1,152 @@
+package build
+
+import (
+	_ "embed"
+	"path/filepath"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/airplanedev/cli/pkg/api"
+	"github.com/airplanedev/cli/pkg/fsx"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	Register(NameRuby, rubyBuilder{})
+}
+
+// rubyBuilder builds Ruby tasks.
+type rubyBuilder struct{}
+
+// Dockerfile implementation.
+func (rubyBuilder) Dockerfile(root string, options api.KindOptions) (string, error) {
+	return ruby(root, options)
+}
+
+// Root implementation.
+//
+// The root is the closest parent directory that contains a Gemfile.
+func (rubyBuilder) Root(path string) (string, error) {
+	return findRoot(path, "Gemfile"), nil
+}
+
+// Workdir implementation.
+func (b rubyBuilder) Workdir(path string) (string, error) {
+	return b.Root(path)
+}
+
+// Shim implementation.
+func (rubyBuilder) Shim(root, entrypoint string) (string, error) {
+	return RubyShim(root, entrypoint)
+}
+
+// NeedsBuilding implementation.
+func (rubyBuilder) NeedsBuilding() bool {
+	return true
+}
+
+// Ruby creates a dockerfile for Ruby.
+//
+// The shim loads the entrypoint and calls its `main` method with the
+// params. If the task has a Gemfile, its gems are installed with Bundler
+// in a layer of their own and the shim runs with `bundle exec`.
+func ruby(root string, options api.KindOptions) (string, error) {
+	// Assert that the entrypoint file exists:
+	entrypoint, _ := options["entrypoint"].(string)
+	if err := fsx.AssertExistsAll(filepath.Join(root, entrypoint)); err != nil {
+		return "", err
+	}
+
+	rubyVersion, _ := options["rubyVersion"].(string)
+	base, err := getBaseRubyImage(rubyVersion)
+	if err != nil {
+		return "", err
+	}
+
+	shim, err := RubyShim("/airplane", entrypoint)
+	if err != nil {
+		return "", err
+	}
+
+	hasGemfile := fsx.Exists(filepath.Join(root, "Gemfile"))
+	hasGemfileLock := fsx.Exists(filepath.Join(root, "Gemfile.lock"))
+	install := "bundle config set --local without development:test && bundle install"
+	if hasGemfileLock {
+		// Fail instead of resolving other versions than the locked ones.
+		install = "bundle config set --local frozen true && " + install
+	}
+
+	entrypointCmd := []string{"ruby", ".airplane/shim.rb"}
+	if hasGemfile {
+		entrypointCmd = append([]string{"bundle", "exec"}, entrypointCmd...)
+	}
+
+	df, err := applyTemplate(heredoc.Doc(`
+		# syntax=docker/dockerfile:1.2
+		FROM {{.Base}}
+
+		WORKDIR /airplane
+		RUN mkdir -p .airplane && {{.InlineShim}} > .airplane/shim.rb
+		{{if .HasGemfile}}
+		COPY Gemfile {{if .HasGemfileLock}}Gemfile.lock {{end}}./
+		RUN {{.Install}}
+		{{end}}
+		COPY . .
+
+		ENTRYPOINT {{.Entrypoint}}
+	`), struct {
+		Base           string
+		InlineShim     string
+		HasGemfile     bool
+		HasGemfileLock bool
+		Install        string
+		Entrypoint     string
+	}{
+		Base:           base,
+		InlineShim:     inlinePrintf(shim),
+		HasGemfile:     hasGemfile,
+		HasGemfileLock: hasGemfileLock,
+		Install:        install,
+		Entrypoint:     jsonArray(entrypointCmd...),
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "rendering dockerfile")
+	}
+	return df, nil
+}
+
+//go:embed ruby-shim.rb
+var rubyShim string
+
+// RubyShim generates a shim file for running Ruby tasks.
+func RubyShim(taskRoot, entrypoint string) (string, error) {
+	shim, err := applyTemplate(rubyShim, struct {
+		TaskRoot   string
+		Entrypoint string
+	}{
+		// Ruby interpolates `#{...}` in double-quoted strings.
+		TaskRoot:   backslashEscape(taskRoot, `"#`),
+		Entrypoint: backslashEscape(entrypoint, `"#`),
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "rendering shim")
+	}
+
+	return shim, nil
+}
+
+// getBaseRubyImage returns the base image for the given Ruby version,
+// defaulting to the latest supported Ruby 3.
+func getBaseRubyImage(version string) (string, error) {
+	if version == "" {
+		version = "3"
+	}
+	base, err := getImage(NameRuby, version)
+	if err != nil {
+		return "", err
+	}
+	if base == "" {
+		return "", errors.Errorf("unsupported ruby version %q", version)
+	}
+
+	return base, nil
+}
@@ -0,0 +1,105 @@
+package build
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/airplanedev/cli/pkg/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuby(t *testing.T) {
+	useTestVersions(t)
+	for _, test := range []struct {
+		root       string
+		entrypoint string
+		copy       string
+		install    string
+		cmd        string
+	}{
+		{
+			root:       "testdata/ruby/bundler",
+			entrypoint: "lib/main.rb",
+			copy:       "COPY Gemfile Gemfile.lock ./",
+			install:    "RUN bundle config set --local frozen true && bundle config set --local without development:test && bundle install",
+			cmd:        `ENTRYPOINT ["bundle", "exec", "ruby", ".airplane/shim.rb"]`,
+		},
+		{
+			root:       "testdata/ruby/none",
+			entrypoint: "main.rb",
+			cmd:        `ENTRYPOINT ["ruby", ".airplane/shim.rb"]`,
+		},
+	} {
+		t.Run(test.root, func(t *testing.T) {
+			require := require.New(t)
+
+			dockerfile, err := BuildDockerfile(DockerfileConfig{
+				Builder: string(NameRuby),
+				Root:    test.root,
+				Options: api.KindOptions{"entrypoint": test.entrypoint},
+			})
+			require.NoError(err)
+
+			instrs := instructions(dockerfile)
+			v, err := GetVersion(NameRuby, "3")
+			require.NoError(err)
+			require.Equal("FROM "+v.String(), instrs[0])
+			require.Equal(test.cmd, instrs[len(instrs)-1])
+			if test.install != "" {
+				require.Contains(instrs, test.copy)
+				require.Contains(instrs, test.install)
+			} else {
+				require.NotContains(dockerfile, "bundle install")
+			}
+
+			b, err := Lookup(NameRuby)
+			require.NoError(err)
+			abs, err := filepath.Abs(filepath.Join(test.root, test.entrypoint))
+			require.NoError(err)
+			root, err := b.Root(abs)
+			require.NoError(err)
+			expected, err := filepath.Abs(test.root)
+			require.NoError(err)
+			require.Equal(expected, root)
+		})
+	}
+}
+
+func TestRubyShim(t *testing.T) {
+	require := require.New(t)
+
+	shim, err := RubyShim("/airplane", `lib/#{weird}".rb`)
+	require.NoError(err)
+	require.Contains(shim, `$LOAD_PATH.unshift("/airplane")`)
+	require.Contains(shim, `load File.join("/airplane", "lib/\#{weird}\".rb")`)
+	require.Contains(shim, "main(JSON.parse(args[0]))")
+}
+
+func TestRubyVersions(t *testing.T) {
+	require := require.New(t)
+
+	// Every Ruby version of versions.json builds from its pinned image:
+	for _, version := range []string{"", "3", "3.0", "2.7"} {
+		dockerfile, err := BuildDockerfile(DockerfileConfig{
+			Builder: string(NameRuby),
+			Root:    "testdata/ruby/none",
+			Options: api.KindOptions{"entrypoint": "main.rb", "rubyVersion": version},
+		})
+		require.NoError(err, "ruby %q", version)
+
+		if version == "" {
+			version = "3"
+		}
+		v, err := GetVersion(NameRuby, version)
+		require.NoError(err)
+		require.Equal("FROM "+v.String(), instructions(dockerfile)[0])
+	}
+
+	_, err := BuildDockerfile(DockerfileConfig{
+		Builder: string(NameRuby),
+		Root:    "testdata/ruby/none",
+		Options: api.KindOptions{"entrypoint": "main.rb", "rubyVersion": "1.9"},
+	})
+	require.Error(err)
+	require.Contains(err.Error(), `unsupported ruby version "1.9"`)
+}
@@ -0,0 +1,138 @@
+package sbom
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"io"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/pkg/errors"
+)
+
+// packageDatabases are the paths of the OS package databases within an
+// image. Distroless images list dpkg packages in status.d, one per file.
+var packageDatabases = map[string]func(io.Reader) ([]Component, error){
+	"/var/lib/dpkg/status":   parseDpkgStatus,
+	"/var/lib/dpkg/status.d": parseDpkgStatus,
+	"/lib/apk/db/installed":  parseAPKInstalled,
+}
+
+// OSPackages returns the OS packages that are installed in image, read
+// from its dpkg and apk databases. The image must exist in the daemon.
+//
+// The image is never run: its filesystem is read from a container that is
+// created, but not started.
+func OSPackages(ctx context.Context, docker *client.Client, image string) (components []Component, rerr error) {
+	// Images without a command, f.e. scratch, require one to be set.
+	c, err := docker.ContainerCreate(ctx, &container.Config{
+		Image:      image,
+		Entrypoint: []string{"/airplane-sbom"},
+	}, nil, nil, nil, "")
+	if err != nil {
+		return nil, errors.Wrap(err, "creating container")
+	}
+	defer func() {
+		if err := docker.ContainerRemove(ctx, c.ID, types.ContainerRemoveOptions{Force: true}); err != nil && rerr == nil {
+			rerr = errors.Wrap(err, "removing container")
+		}
+	}()
+
+	for path, parse := range packageDatabases {
+		rc, _, err := docker.CopyFromContainer(ctx, c.ID, path)
+		if client.IsErrNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "reading %s", path)
+		}
+		cs, err := parseTar(rc, parse)
+		rc.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing %s", path)
+		}
+		components = append(components, cs...)
+	}
+	return components, nil
+}
+
+// parseTar parses every file of the tar archive r.
+func parseTar(r io.Reader, parse func(io.Reader) ([]Component, error)) ([]Component, error) {
+	var components []Component
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return components, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		cs, err := parse(tr)
+		if err != nil {
+			return nil, err
+		}
+		components = append(components, cs...)
+	}
+}
+
+// parseDpkgStatus parses the installed packages of a dpkg status file,
+// which consists of stanzas of `Field: value` lines.
+func parseDpkgStatus(r io.Reader) ([]Component, error) {
+	return parseStanzas(r, ": ", func(fields map[string]string) (Component, bool) {
+		if status := fields["Status"]; status != "" && !strings.HasSuffix(status, " installed") {
+			return Component{}, false
+		}
+		return Component{Ecosystem: EcosystemDeb, Name: fields["Package"], Version: fields["Version"]}, true
+	})
+}
+
+// parseAPKInstalled parses an apk database, which consists of stanzas of
+// `K:value` lines. P is the package name and V its version.
+func parseAPKInstalled(r io.Reader) ([]Component, error) {
+	return parseStanzas(r, ":", func(fields map[string]string) (Component, bool) {
+		return Component{Ecosystem: EcosystemAPK, Name: fields["P"], Version: fields["V"]}, true
+	})
+}
+
+// parseStanzas parses blank line separated stanzas of `key<sep>value`
+// lines into components. Continuation lines are ignored.
+func parseStanzas(r io.Reader, sep string, component func(map[string]string) (Component, bool)) ([]Component, error) {
+	var components []Component
+	fields := map[string]string{}
+	flush := func() {
+		if len(fields) == 0 {
+			return
+		}
+		if c, ok := component(fields); ok && c.Name != "" {
+			components = append(components, c)
+		}
+		fields = map[string]string{}
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			continue
+		}
+		kv := strings.SplitN(line, sep, 2)
+		if len(kv) == 2 {
+			fields[kv[0]] = strings.TrimSpace(kv[1])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return components, nil
+}
@@ -0,0 +1,330 @@
+package sbom
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// lockfiles are the lockfiles that dependencies are read from, keyed by
+// file name.
+var lockfiles = map[string]func(io.Reader) ([]Component, error){
+	"package-lock.json": parsePackageLock,
+	"yarn.lock":         parseYarnLock,
+	"pnpm-lock.yaml":    parsePNPMLock,
+	"requirements.txt":  parseRequirements,
+	"poetry.lock":       parsePoetryLock,
+	"Pipfile.lock":      parsePipfileLock,
+	"go.mod":            parseGoMod,
+}
+
+// skipDirs are directories that are not searched for lockfiles, since they
+// contain installed dependencies rather than the task's own lockfiles.
+var skipDirs = map[string]bool{
+	".git":         true,
+	".airplane":    true,
+	"node_modules": true,
+	"vendor":       true,
+	".venv":        true,
+	"venv":         true,
+	"__pycache__":  true,
+}
+
+// FromLockfiles returns the npm, pip and go dependencies that are declared
+// by the lockfiles in root and its subdirectories.
+func FromLockfiles(root string) ([]Component, error) {
+	var components []Component
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != root && skipDirs[info.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		parse, ok := lockfiles[info.Name()]
+		if !ok {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return errors.Wrap(err, "opening lockfile")
+		}
+		defer f.Close()
+		cs, err := parse(f)
+		if err != nil {
+			return errors.Wrapf(err, "parsing %s", path)
+		}
+		components = append(components, cs...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return components, nil
+}
+
+// parsePackageLock parses a package-lock.json. Lockfile version 2 and
+// later list packages by their path in node_modules, version 1 nests them.
+func parsePackageLock(r io.Reader) ([]Component, error) {
+	type dependency struct {
+		Version      string                     `json:"version"`
+		Link         bool                       `json:"link"`
+		Dependencies map[string]json.RawMessage `json:"dependencies"`
+	}
+	var lock struct {
+		Packages     map[string]dependency      `json:"packages"`
+		Dependencies map[string]json.RawMessage `json:"dependencies"`
+	}
+	if err := json.NewDecoder(r).Decode(&lock); err != nil {
+		return nil, err
+	}
+
+	var components []Component
+	if len(lock.Packages) > 0 {
+		for path, pkg := range lock.Packages {
+			i := strings.LastIndex(path, "node_modules/")
+			if i < 0 || pkg.Link {
+				continue
+			}
+			components = append(components, Component{
+				Ecosystem: EcosystemNPM,
+				Name:      path[i+len("node_modules/"):],
+				Version:   pkg.Version,
+			})
+		}
+		return components, nil
+	}
+
+	var walk func(map[string]json.RawMessage) error
+	walk = func(deps map[string]json.RawMessage) error {
+		for name, raw := range deps {
+			var dep dependency
+			if err := json.Unmarshal(raw, &dep); err != nil {
+				return err
+			}
+			components = append(components, Component{
+				Ecosystem: EcosystemNPM,
+				Name:      name,
+				Version:   dep.Version,
+			})
+			if err := walk(dep.Dependencies); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(lock.Dependencies); err != nil {
+		return nil, err
+	}
+	return components, nil
+}
+
+// parseYarnLock parses a yarn.lock of Yarn 1 or Yarn 2+.
+//
+// Entries start with a line of comma-separated descriptors, f.e.
+// `"@types/node@^16.0.0", "@types/node@^16.4.0":`, followed by indented
+// fields which include the resolved version.
+func parseYarnLock(r io.Reader) ([]Component, error) {
+	var components []Component
+	var name string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !strings.HasPrefix(line, " ") {
+			descriptor := strings.SplitN(strings.TrimSuffix(line, ":"), ",", 2)[0]
+			descriptor = strings.Trim(descriptor, `"`)
+			name = ""
+			if i := strings.LastIndex(descriptor, "@"); i > 0 {
+				name = descriptor[:i]
+			}
+			continue
+		}
+
+		field := strings.TrimSpace(line)
+		var version string
+		if strings.HasPrefix(field, "version ") {
+			version = strings.TrimPrefix(field, "version ")
+		} else if strings.HasPrefix(field, "version: ") {
+			version = strings.TrimPrefix(field, "version: ")
+		} else {
+			continue
+		}
+		version = strings.Trim(version, `"`)
+		// Yarn 2+ lists the packages of the workspace with a placeholder.
+		if name == "" || strings.HasSuffix(version, "-use.local") {
+			continue
+		}
+		components = append(components, Component{Ecosystem: EcosystemNPM, Name: name, Version: version})
+		name = ""
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return components, nil
+}
+
+// parsePNPMLock parses a pnpm-lock.yaml, whose packages are keyed by
+// `/<name>/<version>` or `/<name>@<version>`.
+func parsePNPMLock(r io.Reader) ([]Component, error) {
+	var lock struct {
+		Packages map[string]interface{} `yaml:"packages"`
+	}
+	if err := yaml.NewDecoder(r).Decode(&lock); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	var components []Component
+	for key := range lock.Packages {
+		key = strings.TrimPrefix(key, "/")
+		// Peer dependencies are appended to the version, f.e.
+		// `1.0.0_react@17.0.2` or `1.0.0(react@17.0.2)`.
+		key = strings.SplitN(key, "(", 2)[0]
+
+		var name, version string
+		if i := strings.LastIndex(key, "/"); i > 0 && i+1 < len(key) && key[i+1] >= '0' && key[i+1] <= '9' {
+			name, version = key[:i], strings.SplitN(key[i+1:], "_", 2)[0]
+		} else if i := strings.LastIndex(key, "@"); i > 0 {
+			name, version = key[:i], key[i+1:]
+		}
+		components = append(components, Component{Ecosystem: EcosystemNPM, Name: name, Version: version})
+	}
+	return components, nil
+}
+
+// parseRequirements parses the pinned requirements, f.e. `requests==2.25.1`,
+// of a requirements.txt. Other requirements don't resolve to a version.
+func parseRequirements(r io.Reader) ([]Component, error) {
+	var components []Component
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.SplitN(scanner.Text(), "#", 2)[0])
+		// Environment markers, f.e. `; python_version < "3.8"`.
+		line = strings.TrimSpace(strings.SplitN(line, ";", 2)[0])
+		i := strings.Index(line, "==")
+		if i < 0 {
+			continue
+		}
+		name := strings.TrimSpace(line[:i])
+		// Extras, f.e. `requests[security]`.
+		name = strings.SplitN(name, "[", 2)[0]
+		// Options, f.e. `--hash=sha256:...`.
+		version := strings.Fields(strings.TrimPrefix(line[i+2:], "="))
+		if len(version) == 0 {
+			continue
+		}
+		components = append(components, Component{Ecosystem: EcosystemPyPI, Name: name, Version: version[0]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return components, nil
+}
+
+// parsePoetryLock parses the `[[package]]` tables of a poetry.lock.
+func parsePoetryLock(r io.Reader) ([]Component, error) {
+	var components []Component
+	var current *Component
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			if current != nil {
+				components = append(components, *current)
+				current = nil
+			}
+			if line == "[[package]]" {
+				current = &Component{Ecosystem: EcosystemPyPI}
+			}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		value := strings.Trim(strings.TrimSpace(kv[1]), `"`)
+		switch strings.TrimSpace(kv[0]) {
+		case "name":
+			current.Name = value
+		case "version":
+			current.Version = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if current != nil {
+		components = append(components, *current)
+	}
+	return components, nil
+}
+
+// parsePipfileLock parses the default packages of a Pipfile.lock, which
+// excludes development packages.
+func parsePipfileLock(r io.Reader) ([]Component, error) {
+	var lock struct {
+		Default map[string]struct {
+			Version string `json:"version"`
+		} `json:"default"`
+	}
+	if err := json.NewDecoder(r).Decode(&lock); err != nil {
+		return nil, err
+	}
+
+	var components []Component
+	for name, pkg := range lock.Default {
+		components = append(components, Component{
+			Ecosystem: EcosystemPyPI,
+			Name:      name,
+			Version:   strings.TrimPrefix(pkg.Version, "=="),
+		})
+	}
+	return components, nil
+}
+
+// parseGoMod parses the requirements of a go.mod.
+func parseGoMod(r io.Reader) ([]Component, error) {
+	var components []Component
+	var block bool
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.SplitN(scanner.Text(), "//", 2)[0])
+		switch {
+		case line == "require (":
+			block = true
+			continue
+		case block && line == ")":
+			block = false
+			continue
+		case strings.HasPrefix(line, "require "):
+			line = strings.TrimPrefix(line, "require ")
+		case !block:
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		components = append(components, Component{Ecosystem: EcosystemGolang, Name: fields[0], Version: fields[1]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return components, nil
+}
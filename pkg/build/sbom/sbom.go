@@ -0,0 +1,304 @@
+// Package sbom generates software bills of materials (SBOMs) of task images
+// and checks them against a vulnerability database.
+package sbom
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Ecosystem is the package ecosystem of a component.
+type Ecosystem string
+
+const (
+	EcosystemDeb    Ecosystem = "deb"
+	EcosystemAPK    Ecosystem = "apk"
+	EcosystemNPM    Ecosystem = "npm"
+	EcosystemPyPI   Ecosystem = "pypi"
+	EcosystemGolang Ecosystem = "golang"
+)
+
+// Component is a package that is part of an image.
+type Component struct {
+	Ecosystem Ecosystem
+	Name      string
+	Version   string
+}
+
+// PURL returns the package URL of c, f.e. `pkg:npm/%40types/node@16.0.0`.
+//
+// See https://github.com/package-url/purl-spec.
+func (c Component) PURL() string {
+	var namespace string
+	name := c.Name
+	switch c.Ecosystem {
+	case EcosystemDeb:
+		namespace = "debian"
+	case EcosystemAPK:
+		namespace = "alpine"
+	case EcosystemPyPI:
+		name = normalizePyPI(name)
+	}
+
+	var segments []string
+	if namespace != "" {
+		segments = append(segments, namespace)
+	}
+	for _, s := range strings.Split(name, "/") {
+		segments = append(segments, strings.ReplaceAll(url.PathEscape(s), "@", "%40"))
+	}
+	return fmt.Sprintf("pkg:%s/%s@%s", c.Ecosystem, strings.Join(segments, "/"), url.PathEscape(c.Version))
+}
+
+// normalizePyPI normalizes the name of a Python package, which is case
+// insensitive and treats `-`, `_` and `.` as equal.
+func normalizePyPI(name string) string {
+	name = strings.ToLower(name)
+	return strings.NewReplacer("_", "-", ".", "-").Replace(name)
+}
+
+// SBOM is the software bill of materials of an image.
+type SBOM struct {
+	// Image is the reference of the image.
+	Image string
+	// Created is the time that the SBOM was created at.
+	Created time.Time
+	// Components are the OS packages and dependencies of the image.
+	Components []Component
+}
+
+// Add adds components to s, skipping components that it already contains.
+func (s *SBOM) Add(components ...Component) {
+	seen := map[Component]bool{}
+	for _, c := range s.Components {
+		seen[c] = true
+	}
+	for _, c := range components {
+		if c.Name == "" || c.Version == "" || seen[c] {
+			continue
+		}
+		seen[c] = true
+		s.Components = append(s.Components, c)
+	}
+	sort.SliceStable(s.Components, func(i, j int) bool {
+		a, b := s.Components[i], s.Components[j]
+		if a.Ecosystem != b.Ecosystem {
+			return a.Ecosystem < b.Ecosystem
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Version < b.Version
+	})
+}
+
+// Format is the format that an SBOM is written in.
+type Format string
+
+const (
+	FormatSPDX      Format = "spdx"
+	FormatCycloneDX Format = "cyclonedx"
+)
+
+// ParseFormat parses an SBOM format, f.e. from a flag.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case FormatSPDX, FormatCycloneDX:
+		return f, nil
+	default:
+		return "", errors.Errorf("unknown SBOM format %q: expected %s or %s", s, FormatSPDX, FormatCycloneDX)
+	}
+}
+
+// Write writes s to w as JSON in the given format.
+func (s SBOM) Write(w io.Writer, format Format) error {
+	var doc interface{}
+	switch format {
+	case FormatSPDX:
+		doc = s.spdx()
+	case FormatCycloneDX:
+		doc = s.cycloneDX()
+	default:
+		return errors.Errorf("unknown SBOM format %q", format)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return errors.Wrap(err, "encoding SBOM")
+	}
+	return nil
+}
+
+const toolName = "airplane-cli"
+
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	Name             string            `json:"name"`
+	SPDXID           string            `json:"SPDXID"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	LicenseConcluded string            `json:"licenseConcluded"`
+	LicenseDeclared  string            `json:"licenseDeclared"`
+	CopyrightText    string            `json:"copyrightText"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs,omitempty"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+// spdx returns s as an SPDX 2.2 document.
+func (s SBOM) spdx() spdxDocument {
+	const noAssertion = "NOASSERTION"
+	const imageID = "SPDXRef-Image"
+
+	doc := spdxDocument{
+		SPDXVersion:       "SPDX-2.2",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              s.Image,
+		DocumentNamespace: "https://airplane.dev/spdx/" + s.digest(),
+		CreationInfo: spdxCreationInfo{
+			Created:  s.Created.UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: " + toolName},
+		},
+		Packages: []spdxPackage{{
+			Name:             s.Image,
+			SPDXID:           imageID,
+			DownloadLocation: noAssertion,
+			LicenseConcluded: noAssertion,
+			LicenseDeclared:  noAssertion,
+			CopyrightText:    noAssertion,
+		}},
+		Relationships: []spdxRelationship{{
+			SPDXElementID:      "SPDXRef-DOCUMENT",
+			RelationshipType:   "DESCRIBES",
+			RelatedSPDXElement: imageID,
+		}},
+	}
+	for i, c := range s.Components {
+		id := fmt.Sprintf("SPDXRef-Package-%d", i+1)
+		doc.Packages = append(doc.Packages, spdxPackage{
+			Name:             c.Name,
+			SPDXID:           id,
+			VersionInfo:      c.Version,
+			DownloadLocation: noAssertion,
+			LicenseConcluded: noAssertion,
+			LicenseDeclared:  noAssertion,
+			CopyrightText:    noAssertion,
+			ExternalRefs: []spdxExternalRef{{
+				ReferenceCategory: "PACKAGE_MANAGER",
+				ReferenceType:     "purl",
+				ReferenceLocator:  c.PURL(),
+			}},
+		})
+		doc.Relationships = append(doc.Relationships, spdxRelationship{
+			SPDXElementID:      imageID,
+			RelationshipType:   "CONTAINS",
+			RelatedSPDXElement: id,
+		})
+	}
+	return doc
+}
+
+type cycloneDXDocument struct {
+	BOMFormat    string               `json:"bomFormat"`
+	SpecVersion  string               `json:"specVersion"`
+	SerialNumber string               `json:"serialNumber"`
+	Version      int                  `json:"version"`
+	Metadata     cycloneDXMetadata    `json:"metadata"`
+	Components   []cycloneDXComponent `json:"components"`
+}
+
+type cycloneDXMetadata struct {
+	Timestamp string             `json:"timestamp"`
+	Tools     []cycloneDXTool    `json:"tools"`
+	Component cycloneDXComponent `json:"component"`
+}
+
+type cycloneDXTool struct {
+	Vendor string `json:"vendor"`
+	Name   string `json:"name"`
+}
+
+type cycloneDXComponent struct {
+	BOMRef  string `json:"bom-ref,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	PURL    string `json:"purl,omitempty"`
+}
+
+// cycloneDX returns s as a CycloneDX 1.3 document.
+func (s SBOM) cycloneDX() cycloneDXDocument {
+	digest := s.digest()
+	doc := cycloneDXDocument{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.3",
+		SerialNumber: fmt.Sprintf("urn:uuid:%s-%s-%s-%s-%s", digest[0:8], digest[8:12], digest[12:16], digest[16:20], digest[20:32]),
+		Version:      1,
+		Metadata: cycloneDXMetadata{
+			Timestamp: s.Created.UTC().Format(time.RFC3339),
+			Tools:     []cycloneDXTool{{Vendor: "Airplane", Name: toolName}},
+			Component: cycloneDXComponent{Type: "container", Name: s.Image},
+		},
+		Components: []cycloneDXComponent{},
+	}
+	for _, c := range s.Components {
+		purl := c.PURL()
+		doc.Components = append(doc.Components, cycloneDXComponent{
+			BOMRef:  purl,
+			Type:    "library",
+			Name:    c.Name,
+			Version: c.Version,
+			PURL:    purl,
+		})
+	}
+	return doc
+}
+
+// digest returns a hex digest of the contents of s, which identifies the
+// SBOM in its documents.
+func (s SBOM) digest() string {
+	h := sha256.New()
+	fmt.Fprintln(h, s.Image)
+	fmt.Fprintln(h, s.Created.UTC().Format(time.RFC3339Nano))
+	for _, c := range s.Components {
+		fmt.Fprintln(h, c.PURL())
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,181 @@
+package sbom
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromLockfiles(t *testing.T) {
+	for _, test := range []struct {
+		root       string
+		components []Component
+	}{
+		{
+			root: "testdata/lockfiles/npm",
+			components: []Component{
+				{EcosystemNPM, "@types/node", "16.4.0"},
+				{EcosystemNPM, "lodash", "4.17.20"},
+			},
+		},
+		{
+			root: "testdata/lockfiles/yarn",
+			components: []Component{
+				{EcosystemNPM, "@types/node", "16.4.0"},
+				{EcosystemNPM, "lodash", "4.17.21"},
+			},
+		},
+		{
+			root: "testdata/lockfiles/berry",
+			components: []Component{
+				{EcosystemNPM, "lodash", "4.17.21"},
+			},
+		},
+		{
+			root: "testdata/lockfiles/pnpm",
+			components: []Component{
+				{EcosystemNPM, "@types/node", "16.4.0"},
+				{EcosystemNPM, "react-dom", "17.0.2"},
+				{EcosystemNPM, "string_decoder", "1.3.0"},
+			},
+		},
+		{
+			root: "testdata/lockfiles/pip",
+			components: []Component{
+				{EcosystemPyPI, "requests", "2.25.1"},
+				{EcosystemPyPI, "urllib3", "1.26.4"},
+			},
+		},
+		{
+			root: "testdata/lockfiles/poetry",
+			components: []Component{
+				{EcosystemPyPI, "PyYAML", "5.4.1"},
+				{EcosystemPyPI, "requests", "2.25.1"},
+			},
+		},
+		{
+			root: "testdata/lockfiles/pipenv",
+			components: []Component{
+				{EcosystemPyPI, "requests", "2.25.1"},
+			},
+		},
+		{
+			root: "testdata/lockfiles/go",
+			components: []Component{
+				{EcosystemGolang, "github.com/pkg/errors", "v0.9.1"},
+				{EcosystemGolang, "golang.org/x/sync", "v0.0.0-20210220032951-036812b2e83c"},
+				{EcosystemGolang, "gopkg.in/yaml.v3", "v3.0.0-20200605160147-a5ece683394c"},
+			},
+		},
+	} {
+		t.Run(test.root, func(t *testing.T) {
+			require := require.New(t)
+
+			components, err := FromLockfiles(test.root)
+			require.NoError(err)
+			sort.Slice(components, func(i, j int) bool {
+				return components[i].Name < components[j].Name
+			})
+			require.Equal(test.components, components)
+		})
+	}
+}
+
+func TestOSPackages(t *testing.T) {
+	require := require.New(t)
+
+	f, err := os.Open("testdata/os/status")
+	require.NoError(err)
+	defer f.Close()
+	components, err := parseDpkgStatus(f)
+	require.NoError(err)
+	require.Equal([]Component{
+		{EcosystemDeb, "libc6", "2.28-10"},
+		{EcosystemDeb, "openssl", "1.1.1d-0+deb10u6"},
+	}, components)
+
+	f, err = os.Open("testdata/os/installed")
+	require.NoError(err)
+	defer f.Close()
+	components, err = parseAPKInstalled(f)
+	require.NoError(err)
+	require.Equal([]Component{
+		{EcosystemAPK, "musl", "1.2.2-r3"},
+		{EcosystemAPK, "busybox", "1.33.1-r3"},
+	}, components)
+}
+
+func TestPURL(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal("pkg:npm/%40types/node@16.4.0", Component{EcosystemNPM, "@types/node", "16.4.0"}.PURL())
+	require.Equal("pkg:pypi/pyyaml@5.4.1", Component{EcosystemPyPI, "PyYAML", "5.4.1"}.PURL())
+	require.Equal("pkg:deb/debian/openssl@1.1.1d-0+deb10u6", Component{EcosystemDeb, "openssl", "1.1.1d-0+deb10u6"}.PURL())
+	require.Equal("pkg:golang/github.com/pkg/errors@v0.9.1", Component{EcosystemGolang, "github.com/pkg/errors", "v0.9.1"}.PURL())
+}
+
+func TestWrite(t *testing.T) {
+	s := SBOM{
+		Image:   "us-docker.pkg.dev/airplane/task-abc:latest",
+		Created: time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC),
+	}
+	s.Add(
+		Component{EcosystemNPM, "lodash", "4.17.20"},
+		Component{EcosystemDeb, "libc6", "2.28-10"},
+		Component{EcosystemNPM, "lodash", "4.17.20"},
+	)
+
+	t.Run("spdx", func(t *testing.T) {
+		require := require.New(t)
+
+		var buf bytes.Buffer
+		require.NoError(s.Write(&buf, FormatSPDX))
+		var doc struct {
+			SPDXVersion string `json:"spdxVersion"`
+			Packages    []struct {
+				Name         string `json:"name"`
+				VersionInfo  string `json:"versionInfo"`
+				ExternalRefs []struct {
+					ReferenceLocator string `json:"referenceLocator"`
+				} `json:"externalRefs"`
+			} `json:"packages"`
+		}
+		require.NoError(json.Unmarshal(buf.Bytes(), &doc))
+		require.Equal("SPDX-2.2", doc.SPDXVersion)
+		// The image itself, followed by its components:
+		require.Len(doc.Packages, 3)
+		require.Equal(s.Image, doc.Packages[0].Name)
+		require.Equal("libc6", doc.Packages[1].Name)
+		require.Equal("pkg:deb/debian/libc6@2.28-10", doc.Packages[1].ExternalRefs[0].ReferenceLocator)
+		require.Equal("4.17.20", doc.Packages[2].VersionInfo)
+	})
+
+	t.Run("cyclonedx", func(t *testing.T) {
+		require := require.New(t)
+
+		var buf bytes.Buffer
+		require.NoError(s.Write(&buf, FormatCycloneDX))
+		var doc struct {
+			BOMFormat  string `json:"bomFormat"`
+			Components []struct {
+				Name string `json:"name"`
+				PURL string `json:"purl"`
+			} `json:"components"`
+		}
+		require.NoError(json.Unmarshal(buf.Bytes(), &doc))
+		require.Equal("CycloneDX", doc.BOMFormat)
+		require.Len(doc.Components, 2)
+		require.Equal("pkg:npm/lodash@4.17.20", doc.Components[1].PURL)
+	})
+
+	// SBOMs of the same image and components are identical:
+	var a, b bytes.Buffer
+	require.NoError(t, s.Write(&a, FormatSPDX))
+	require.NoError(t, s.Write(&b, FormatSPDX))
+	require.Equal(t, a.String(), b.String())
+}
@@ -0,0 +1,10 @@
+module example.com/task
+
+go 1.16
+
+require github.com/pkg/errors v0.9.1
+
+require (
+	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c // indirect
+	gopkg.in/yaml.v3 v3.0.0-20200605160147-a5ece683394c
+)
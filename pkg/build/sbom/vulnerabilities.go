@@ -0,0 +1,241 @@
+package sbom
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// Severity is the severity of a vulnerability.
+type Severity string
+
+const (
+	SeverityLow      Severity = "low"
+	SeverityMedium   Severity = "medium"
+	SeverityHigh     Severity = "high"
+	SeverityCritical Severity = "critical"
+)
+
+var severities = []Severity{SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical}
+
+// ParseSeverity parses a severity, f.e. from a flag.
+func ParseSeverity(s string) (Severity, error) {
+	for _, sev := range severities {
+		if Severity(strings.ToLower(s)) == sev {
+			return sev, nil
+		}
+	}
+	return "", errors.Errorf("unknown severity %q: expected one of low, medium, high or critical", s)
+}
+
+// AtLeast returns true if s is as severe as other or more severe.
+func (s Severity) AtLeast(other Severity) bool {
+	return s.rank() >= other.rank()
+}
+
+func (s Severity) rank() int {
+	for i, sev := range severities {
+		if strings.ToLower(string(s)) == string(sev) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Database is a vulnerability database, read from a local JSON file:
+//
+//	{
+//	  "vulnerabilities": [
+//	    {
+//	      "id": "CVE-2021-23337",
+//	      "severity": "high",
+//	      "ecosystem": "npm",
+//	      "package": "lodash",
+//	      "ranges": [{"introduced": "0", "fixed": "4.17.21"}]
+//	    }
+//	  ]
+//	}
+//
+// A vulnerability affects the listed versions and every version within one
+// of its ranges. Ranges include the introduced version and exclude the fixed
+// version, either may be omitted.
+type Database struct {
+	Vulnerabilities []Vulnerability `json:"vulnerabilities"`
+}
+
+// Vulnerability is a vulnerability of a package.
+type Vulnerability struct {
+	ID        string    `json:"id"`
+	Severity  Severity  `json:"severity"`
+	Ecosystem Ecosystem `json:"ecosystem"`
+	Package   string    `json:"package"`
+	Versions  []string  `json:"versions,omitempty"`
+	Ranges    []Range   `json:"ranges,omitempty"`
+}
+
+// Range is a range of affected versions.
+type Range struct {
+	Introduced string `json:"introduced,omitempty"`
+	Fixed      string `json:"fixed,omitempty"`
+}
+
+// ReadDatabase reads the vulnerability database at path.
+func ReadDatabase(path string) (Database, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return Database{}, errors.Wrap(err, "reading vulnerability database")
+	}
+	var db Database
+	if err := json.Unmarshal(buf, &db); err != nil {
+		return Database{}, errors.Wrapf(err, "parsing vulnerability database %s", path)
+	}
+	for _, v := range db.Vulnerabilities {
+		if v.Severity.rank() < 0 {
+			return Database{}, errors.Errorf("vulnerability %s has an unknown severity %q", v.ID, v.Severity)
+		}
+	}
+	return db, nil
+}
+
+// Finding is a vulnerability that affects a component.
+type Finding struct {
+	Component     Component
+	Vulnerability Vulnerability
+}
+
+// Match returns the vulnerabilities of db that affect the components of s,
+// most severe first.
+func (db Database) Match(s SBOM) []Finding {
+	byPackage := map[string][]Vulnerability{}
+	key := func(ecosystem Ecosystem, name string) string {
+		if ecosystem == EcosystemPyPI {
+			name = normalizePyPI(name)
+		}
+		return string(ecosystem) + "/" + name
+	}
+	for _, v := range db.Vulnerabilities {
+		k := key(v.Ecosystem, v.Package)
+		byPackage[k] = append(byPackage[k], v)
+	}
+
+	var findings []Finding
+	for _, c := range s.Components {
+		for _, v := range byPackage[key(c.Ecosystem, c.Name)] {
+			if v.Affects(c.Version) {
+				findings = append(findings, Finding{Component: c, Vulnerability: v})
+			}
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Vulnerability.Severity.rank() > findings[j].Vulnerability.Severity.rank()
+	})
+	return findings
+}
+
+// Affects returns true if version is affected by v.
+func (v Vulnerability) Affects(version string) bool {
+	for _, affected := range v.Versions {
+		if compareVersions(version, affected) == 0 {
+			return true
+		}
+	}
+	for _, r := range v.Ranges {
+		if r.Introduced != "" && r.Introduced != "0" && compareVersions(version, r.Introduced) < 0 {
+			continue
+		}
+		if r.Fixed != "" && compareVersions(version, r.Fixed) >= 0 {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// compareVersions compares two versions of a package, returning -1, 0 or 1.
+//
+// Versions are compared by their numeric and alphabetic segments, which
+// orders most versions of all ecosystems: semver, PEP 440 and Debian
+// versions. A leading `v` is ignored, f.e. for Go modules.
+func compareVersions(a, b string) int {
+	as, bs := versionSegments(a), versionSegments(b)
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y string
+		if i < len(as) {
+			x = as[i]
+		}
+		if i < len(bs) {
+			y = bs[i]
+		}
+		if c := compareSegments(x, y); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func versionSegments(v string) []string {
+	v = strings.TrimPrefix(v, "v")
+	var segments []string
+	var cur strings.Builder
+	var digits bool
+	for _, r := range v {
+		isDigit := unicode.IsDigit(r)
+		if !isDigit && !unicode.IsLetter(r) {
+			if cur.Len() > 0 {
+				segments = append(segments, cur.String())
+				cur.Reset()
+			}
+			continue
+		}
+		if cur.Len() > 0 && isDigit != digits {
+			segments = append(segments, cur.String())
+			cur.Reset()
+		}
+		digits = isDigit
+		cur.WriteRune(r)
+	}
+	if cur.Len() > 0 {
+		segments = append(segments, cur.String())
+	}
+	return segments
+}
+
+// compareSegments compares two version segments. Numbers are compared by
+// value, missing segments equal zero and order after pre-release tags, f.e.
+// `1.0` = `1.0.0` and `1.0.0-rc1` < `1.0.0`.
+func compareSegments(a, b string) int {
+	if a == "" && b == "" {
+		return 0
+	}
+	if a == "" {
+		return -compareSegments(b, a)
+	}
+	an, aErr := strconv.Atoi(a)
+	if b == "" {
+		if aErr != nil {
+			return -1
+		}
+		b = "0"
+	}
+	bn, bErr := strconv.Atoi(b)
+	switch {
+	case aErr == nil && bErr == nil:
+		if an < bn {
+			return -1
+		} else if an > bn {
+			return 1
+		}
+		return 0
+	case aErr == nil:
+		return 1
+	case bErr == nil:
+		return -1
+	default:
+		return strings.Compare(a, b)
+	}
+}
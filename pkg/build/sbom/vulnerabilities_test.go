@@ -0,0 +1,78 @@
+package sbom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareVersions(t *testing.T) {
+	for _, test := range []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"1.0", "1.0.0", 0},
+		{"v0.9.1", "0.9.1", 0},
+		{"4.17.20", "4.17.21", -1},
+		{"4.17.21", "4.9.0", 1},
+		{"1.0.0-rc1", "1.0.0", -1},
+		{"1.0.0-alpha", "1.0.0-beta", -1},
+		{"1.1.1d-0+deb10u6", "1.1.1d-0+deb10u7", -1},
+		{"1.1.1k", "1.1.1d", 1},
+	} {
+		require.Equal(t, test.expected, compareVersions(test.a, test.b), "%s <=> %s", test.a, test.b)
+		require.Equal(t, -test.expected, compareVersions(test.b, test.a), "%s <=> %s", test.b, test.a)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	require := require.New(t)
+
+	db := Database{Vulnerabilities: []Vulnerability{
+		{
+			ID:        "CVE-2021-23337",
+			Severity:  SeverityHigh,
+			Ecosystem: EcosystemNPM,
+			Package:   "lodash",
+			Ranges:    []Range{{Introduced: "0", Fixed: "4.17.21"}},
+		},
+		{
+			ID:        "CVE-2021-3449",
+			Severity:  SeverityCritical,
+			Ecosystem: EcosystemDeb,
+			Package:   "openssl",
+			Versions:  []string{"1.1.1d-0+deb10u6"},
+		},
+		{
+			ID:        "CVE-2020-0001",
+			Severity:  SeverityLow,
+			Ecosystem: EcosystemPyPI,
+			Package:   "pyyaml",
+			Ranges:    []Range{{Introduced: "5.0", Fixed: "5.4"}},
+		},
+	}}
+
+	var s SBOM
+	s.Add(
+		Component{EcosystemNPM, "lodash", "4.17.20"},
+		Component{EcosystemDeb, "openssl", "1.1.1d-0+deb10u6"},
+		// Fixed:
+		Component{EcosystemPyPI, "PyYAML", "5.4.1"},
+	)
+	findings := db.Match(s)
+	require.Len(findings, 2)
+	require.Equal("CVE-2021-3449", findings[0].Vulnerability.ID)
+	require.Equal("CVE-2021-23337", findings[1].Vulnerability.ID)
+
+	// Python package names are normalized:
+	s.Add(Component{EcosystemPyPI, "PyYAML", "5.3.1"})
+	findings = db.Match(s)
+	require.Len(findings, 3)
+	require.Equal("CVE-2020-0001", findings[2].Vulnerability.ID)
+
+	require.True(SeverityCritical.AtLeast(SeverityHigh))
+	require.False(SeverityMedium.AtLeast(SeverityHigh))
+	_, err := ParseSeverity("severe")
+	require.Error(err)
+}
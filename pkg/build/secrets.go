@@ -0,0 +1,54 @@
+package build
+
+import (
+	"strings"
+)
+
+// Build secrets are `BUILD_`-prefixed env vars that are mounted into
+// individual RUN instructions with BuildKit's `--mount=type=secret`.
+//
+// Unlike build args, secret mounts are not persisted in image layers
+// or in the image history.
+
+// withNPMSecrets wraps cmd, an npm or yarn install command, with the
+// BUILD_NPM_RC and BUILD_NPM_TOKEN build secrets that configure private
+// registry auth.
+//
+// The secrets are written to `~/.npmrc` for the duration of cmd and removed
+// in the same RUN instruction, so they never land in an image layer.
+func withNPMSecrets(cmd string) string {
+	return strings.Join([]string{
+		"--mount=type=secret,id=BUILD_NPM_RC --mount=type=secret,id=BUILD_NPM_TOKEN \\",
+		"\t([ ! -f /run/secrets/BUILD_NPM_RC ] || cp /run/secrets/BUILD_NPM_RC ~/.npmrc) && \\",
+		"\t([ ! -f /run/secrets/BUILD_NPM_TOKEN ] || echo \"//registry.npmjs.org/:_authToken=$(cat /run/secrets/BUILD_NPM_TOKEN)\" >> ~/.npmrc) && \\",
+		"\t" + cmd + " && \\",
+		"\trm -f ~/.npmrc",
+	}, "\n")
+}
+
+// withPipSecrets wraps cmd, a pip install command, with the BUILD_PIP_CONF
+// and BUILD_PIP_INDEX_URL build secrets that configure private package
+// indexes.
+//
+// BUILD_PIP_CONF is mounted as the global pip.conf and BUILD_PIP_INDEX_URL
+// is only exported to cmd, so neither lands in an image layer.
+func withPipSecrets(cmd string) string {
+	return strings.Join([]string{
+		"--mount=type=secret,id=BUILD_PIP_CONF,target=/etc/pip.conf --mount=type=secret,id=BUILD_PIP_INDEX_URL \\",
+		"\t([ ! -f /run/secrets/BUILD_PIP_INDEX_URL ] || export PIP_INDEX_URL=\"$(cat /run/secrets/BUILD_PIP_INDEX_URL)\"; " + cmd + ")",
+	}, "\n")
+}
+
+// withGoSecrets wraps cmd, a go command that downloads modules, with the
+// BUILD_GOPRIVATE and BUILD_NETRC build secrets that configure private
+// modules.
+//
+// BUILD_GOPRIVATE is exported to cmd as GOPRIVATE, which also skips the
+// checksum database and proxy for those modules. BUILD_NETRC is mounted as
+// `~/.netrc` to authenticate against their hosts.
+func withGoSecrets(cmd string) string {
+	return strings.Join([]string{
+		"--mount=type=secret,id=BUILD_NETRC,target=/root/.netrc --mount=type=secret,id=BUILD_GOPRIVATE \\",
+		"\t([ ! -f /run/secrets/BUILD_GOPRIVATE ] || export GOPRIVATE=\"$(cat /run/secrets/BUILD_GOPRIVATE)\"; " + cmd + ")",
+	}, "\n")
+}
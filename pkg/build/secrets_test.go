@@ -0,0 +1,129 @@
+package build
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/airplanedev/cli/pkg/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDockerfileSecrets(t *testing.T) {
+	useTestVersions(t)
+	for _, test := range []struct {
+		name    string
+		builder Name
+		root    string
+		options api.KindOptions
+		install string
+		build   string
+		secrets []string
+	}{
+		{
+			name:    "node",
+			builder: NameNode,
+			root:    "testdata/secrets/node",
+			options: api.KindOptions{"shim": "true", "entrypoint": "main.ts", "nodeVersion": "16"},
+			install: "npm install",
+			secrets: []string{"BUILD_NPM_RC", "BUILD_NPM_TOKEN"},
+		},
+		{
+			name:    "node legacy",
+			builder: NameNode,
+			root:    "testdata/secrets/node",
+			options: api.KindOptions{"entrypoint": "main.ts", "language": "typescript", "nodeVersion": "16"},
+			install: "npm install",
+			secrets: []string{"BUILD_NPM_RC", "BUILD_NPM_TOKEN"},
+		},
+		{
+			name:    "python",
+			builder: NamePython,
+			root:    "testdata/secrets/python",
+			options: api.KindOptions{"shim": "true", "entrypoint": "main.py"},
+			install: "pip install",
+			secrets: []string{"BUILD_PIP_CONF", "BUILD_PIP_INDEX_URL"},
+		},
+		{
+			name:    "python legacy",
+			builder: NamePython,
+			root:    "testdata/secrets/python",
+			options: api.KindOptions{"entrypoint": "main.py"},
+			install: "pip install",
+			secrets: []string{"BUILD_PIP_CONF", "BUILD_PIP_INDEX_URL"},
+		},
+		{
+			name:    "go",
+			builder: NameGo,
+			root:    "testdata/secrets/go",
+			options: api.KindOptions{"entrypoint": "main.go"},
+			install: "go mod download",
+			build:   "go build",
+			secrets: []string{"BUILD_NETRC", "BUILD_GOPRIVATE"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+
+			dockerfile, err := BuildDockerfile(DockerfileConfig{
+				Builder: string(test.builder),
+				Root:    test.root,
+				Options: test.options,
+			})
+			require.NoError(err)
+			require.True(strings.HasPrefix(dockerfile, "# syntax=docker/dockerfile:1.2\n"))
+
+			var installs, builds int
+			for _, instr := range instructions(dockerfile) {
+				// Secrets must never be passed as build args, since those are
+				// visible in the image history.
+				require.False(strings.HasPrefix(instr, "ARG "), instr)
+				require.NotContains(instr, "ENV BUILD_")
+
+				if !strings.Contains(instr, "--mount=type=secret") {
+					require.NotContains(instr, "/run/secrets", instr)
+					require.NotContains(instr, ".npmrc", instr)
+					continue
+				}
+
+				if test.build != "" && strings.Contains(instr, test.build) {
+					builds++
+				} else {
+					require.Contains(instr, test.install, instr)
+					installs++
+				}
+				for _, secret := range test.secrets {
+					require.Contains(instr, "--mount=type=secret,id="+secret, instr)
+				}
+				// Credentials written to disk are removed in the same layer:
+				if strings.Contains(instr, "~/.npmrc") {
+					require.True(strings.HasSuffix(instr, "rm -f ~/.npmrc"), instr)
+				}
+			}
+			require.Equal(1, installs)
+			if test.build != "" {
+				require.Equal(1, builds)
+			}
+		})
+	}
+}
+
+// instructions splits dockerfile into its instructions, joining
+// continuation lines and dropping comments.
+func instructions(dockerfile string) []string {
+	var instrs []string
+	var cur strings.Builder
+	for _, line := range strings.Split(dockerfile, "\n") {
+		line = strings.TrimSpace(line)
+		if cur.Len() == 0 && (line == "" || strings.HasPrefix(line, "#")) {
+			continue
+		}
+		if strings.HasSuffix(line, "\\") {
+			cur.WriteString(strings.TrimSuffix(line, "\\"))
+			continue
+		}
+		cur.WriteString(line)
+		instrs = append(instrs, cur.String())
+		cur.Reset()
+	}
+	return instrs
+}
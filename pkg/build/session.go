@@ -0,0 +1,71 @@
+package build
+
+import (
+	"context"
+	"net"
+
+	"github.com/airplanedev/cli/pkg/logger"
+	"github.com/docker/docker/api/types"
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/session/auth"
+	"github.com/moby/buildkit/session/secrets/secretsprovider"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+)
+
+// Session starts a BuildKit session that serves the build secrets and
+// registry credentials to the daemon for the duration of a build.
+func (b *LocalBuilder) session(ctx context.Context) (*session.Session, error) {
+	sess, err := session.NewSession(ctx, "airplane", "")
+	if err != nil {
+		return nil, errors.Wrap(err, "creating buildkit session")
+	}
+
+	secrets := make(map[string][]byte, len(b.buildSecrets))
+	for k, v := range b.buildSecrets {
+		secrets[k] = []byte(v)
+	}
+	sess.Allow(secretsprovider.FromMap(secrets))
+	sess.Allow(&registryAuthProvider{
+		host: b.auth.host(),
+		auth: b.registryAuth(),
+	})
+
+	go func() {
+		dialer := func(ctx context.Context, proto string, meta map[string][]string) (net.Conn, error) {
+			return b.client.DialHijack(ctx, "/session", proto, meta)
+		}
+		if err := sess.Run(ctx, dialer); err != nil {
+			logger.Debug("buildkit session: %s", err)
+		}
+	}()
+
+	return sess, nil
+}
+
+// registryAuthProvider serves the Airplane registry credentials to BuildKit,
+// f.e. to import a build cache from the registry.
+//
+// Other registries are accessed anonymously.
+type registryAuthProvider struct {
+	auth.UnimplementedAuthServer
+
+	host string
+	auth types.AuthConfig
+}
+
+// Register implementation.
+func (p *registryAuthProvider) Register(server *grpc.Server) {
+	auth.RegisterAuthServer(server, p)
+}
+
+// Credentials implementation.
+func (p *registryAuthProvider) Credentials(ctx context.Context, req *auth.CredentialsRequest) (*auth.CredentialsResponse, error) {
+	if req.Host != p.host {
+		return &auth.CredentialsResponse{}, nil
+	}
+	return &auth.CredentialsResponse{
+		Username: p.auth.Username,
+		Secret:   p.auth.Password,
+	}, nil
+}
@@ -3,8 +3,9 @@
 # Params are based in as param_slug_1=value1, param_slug_2=value2
 # Export as environment varaibles, PARAM_SLUG_1=value1, PARAM_SLUG_2=value2
 for param in "${@:2}"; do
-    param_slug="$(cut -d '=' -f 1 <<< "${param}")"
-    param_value="$(cut -d '=' -f 2 <<< "${param}")"
+    # Split on the first `=`, values may contain `=` themselves
+    param_slug="${param%%=*}"
+    param_value="${param#*=}"
     # Convert to uppercase
     var_name="$(echo "PARAM_${param_slug}" | tr '[:lower:]' '[:upper:]')"
     # Export env var
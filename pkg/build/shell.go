@@ -4,6 +4,7 @@ import (
 	_ "embed"
 	"io/ioutil"
 	"path/filepath"
+	"strings"
 
 	"github.com/MakeNowJust/heredoc"
 	"github.com/airplanedev/cli/pkg/api"
@@ -12,6 +13,46 @@ import (
 	"github.com/pkg/errors"
 )
 
+func init() {
+	Register(NameShell, shellBuilder{})
+}
+
+// shellBuilder builds shell tasks.
+type shellBuilder struct{}
+
+// Dockerfile implementation.
+func (shellBuilder) Dockerfile(root string, options api.KindOptions) (string, error) {
+	return shell(root, options)
+}
+
+// Root implementation.
+//
+// The root is the closest parent directory that contains a Dockerfile,
+// which shell tasks are built from if present.
+func (shellBuilder) Root(path string) (string, error) {
+	for _, filePath := range DockerfilePaths() {
+		if root, ok := fsx.Find(path, filePath); ok {
+			return root, nil
+		}
+	}
+	return filepath.Dir(path), nil
+}
+
+// Workdir implementation.
+func (b shellBuilder) Workdir(path string) (string, error) {
+	return b.Root(path)
+}
+
+// Shim implementation.
+func (shellBuilder) Shim(root, entrypoint string) (string, error) {
+	return ShellShim(), nil
+}
+
+// NeedsBuilding implementation.
+func (shellBuilder) NeedsBuilding() bool {
+	return true
+}
+
 func shell(root string, options api.KindOptions) (string, error) {
 	// Assert that the entrypoint file exists:
 	entrypoint, _ := options["entrypoint"].(string)
@@ -22,49 +63,93 @@ func shell(root string, options api.KindOptions) (string, error) {
 		return "", err
 	}
 
+	baseImage, _ := options["baseImage"].(string)
+	aptPackages := stringSlice(options["aptPackages"])
+	apkPackages := stringSlice(options["apkPackages"])
+	setup, _ := options["setup"].(string)
+	if len(aptPackages) > 0 && len(apkPackages) > 0 {
+		return "", errors.New("aptPackages and apkPackages cannot both be set")
+	}
+
 	// Build off of the dockerfile if provided:
-	var dockerfileTemplate string
+	var base string
 	if dockerfilePath := FindDockerfile(root); dockerfilePath != "" {
+		if baseImage != "" || len(aptPackages) > 0 || len(apkPackages) > 0 || setup != "" {
+			return "", errors.Errorf("baseImage, aptPackages, apkPackages and setup cannot be set for tasks with a Dockerfile: found %s", dockerfilePath)
+		}
 		contents, err := ioutil.ReadFile(dockerfilePath)
 		if err != nil {
 			return "", errors.Wrap(err, "opening dockerfile")
 		}
-		dockerfileTemplate = string(contents)
+		base = string(contents)
 	} else {
-		dockerfileTemplate = heredoc.Doc(`
-			FROM ubuntu:21.04
-			# Install some common libraries
+		customBase := baseImage != ""
+		if !customBase {
+			if len(apkPackages) > 0 {
+				return "", errors.New("apkPackages require an Alpine-based baseImage, use aptPackages instead")
+			}
+			var err error
+			if baseImage, err = getImage(NameShell, "ubuntu"); err != nil {
+				return "", err
+			}
+			aptPackages = append(append([]string{}, defaultAptPackages...), aptPackages...)
+		}
+		// The shim runs with bash, which Alpine does not ship with. Other
+		// custom base images are checked for it, since they may be Alpine
+		// too.
+		var ensureBash bool
+		if len(apkPackages) > 0 {
+			apkPackages = append([]string{"bash"}, apkPackages...)
+		} else {
+			ensureBash = customBase
+		}
+
+		var inlineSetup string
+		if setup != "" {
+			inlineSetup = inlinePrintf(setup)
+		}
+
+		var err error
+		base, err = applyTemplate(heredoc.Doc(`
+			FROM {{.Base}}
+			{{if .AptPackages}}
 			RUN apt-get update && export DEBIAN_FRONTEND=noninteractive \
 				&& apt-get -y install --no-install-recommends \
-					apt-utils \
-					openssh-client \
-					gnupg2 \
-					iproute2 \
-					procps \
-					lsof \
-					htop \
-					net-tools \
-					curl \
-					wget \
-					ca-certificates \
-					unzip \
-					zip \
-					nano \
-					vim-tiny \
-					less \
-					jq \
-					lsb-release \
-					apt-transport-https \
-					dialog \
-					zlib1g \
-					locales \
-					strace \
+					{{.AptPackages}} \
 				&& apt-get autoremove -y && apt-get clean -y && rm -rf /var/lib/apt/lists/*
-		`)
+			{{end}}
+			{{if .APKPackages}}
+			RUN apk add --no-cache {{.APKPackages}}
+			{{end}}
+			{{if .EnsureBash}}
+			RUN command -v bash > /dev/null || apk add --no-cache bash \
+				|| (echo "shell tasks require bash, install it in the baseImage" >&2 && exit 1)
+			{{end}}
+			{{if .Setup}}
+			# The setup script can span multiple lines, so it is written to a file.
+			RUN {{.Setup}} > /tmp/airplane-setup.sh \
+				&& bash -e /tmp/airplane-setup.sh && rm /tmp/airplane-setup.sh
+			{{end}}
+		`), struct {
+			Base        string
+			AptPackages string
+			APKPackages string
+			EnsureBash  bool
+			Setup       string
+		}{
+			Base:        baseImage,
+			AptPackages: strings.Join(aptPackages, " \\\n\t\t"),
+			APKPackages: strings.Join(apkPackages, " "),
+			EnsureBash:  ensureBash,
+			Setup:       inlineSetup,
+		})
+		if err != nil {
+			return "", err
+		}
 	}
 
-	// Extend template with our own logic - set up a WORKDIR and shim.
-	dockerfileTemplate = dockerfileTemplate + heredoc.Doc(`
+	// Extend the base image with our own logic - set up a WORKDIR and shim.
+	df, err := applyTemplate(heredoc.Doc(`
 		WORKDIR /airplane
 		RUN mkdir -p .airplane && {{.InlineShim}} > .airplane/shim.sh
 		
@@ -72,14 +157,30 @@ func shell(root string, options api.KindOptions) (string, error) {
 		RUN chmod +x {{.Entrypoint}}
 		
 		ENTRYPOINT ["bash", ".airplane/shim.sh", "/airplane/{{.Entrypoint}}"]
-	`)
-	return applyTemplate(dockerfileTemplate, struct {
+	`), struct {
 		InlineShim string
 		Entrypoint string
 	}{
-		InlineShim: inlineString(ShellShim()),
+		InlineShim: inlinePrintf(ShellShim()),
 		Entrypoint: backslashEscape(entrypoint, `"`),
 	})
+	if err != nil {
+		return "", err
+	}
+	return base + df, nil
+}
+
+// defaultAptPackages are installed into the default base image of shell
+// tasks.
+var defaultAptPackages = []string{
+	"ca-certificates",
+	"curl",
+	"jq",
+	"less",
+	"procps",
+	"unzip",
+	"wget",
+	"zip",
 }
 
 //go:embed shell-shim.sh
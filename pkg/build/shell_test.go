@@ -0,0 +1,186 @@
+package build
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/airplanedev/cli/pkg/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShellDockerfile(t *testing.T) {
+	useTestVersions(t)
+	for _, test := range []struct {
+		name     string
+		options  api.KindOptions
+		base     string
+		contains []string
+		err      bool
+	}{
+		{
+			name:     "default",
+			options:  api.KindOptions{"entrypoint": "main.sh"},
+			base:     "ubuntu",
+			contains: []string{"apt-get -y install --no-install-recommends ca-certificates curl jq"},
+		},
+		{
+			name: "apt packages",
+			options: api.KindOptions{
+				"entrypoint": "main.sh",
+				// Kind options that come from the API are decoded from JSON:
+				"aptPackages": []interface{}{"postgresql-client"},
+				"setup":       "curl -sSL https://example.com/install.sh | bash",
+			},
+			base: "ubuntu",
+			contains: []string{
+				"zip postgresql-client",
+				"RUN printf '%b' 'curl -sSL https://example.com/install.sh | bash' > /tmp/airplane-setup.sh",
+			},
+		},
+		{
+			name: "apk packages",
+			options: api.KindOptions{
+				"entrypoint":  "main.sh",
+				"baseImage":   "alpine:3.14",
+				"apkPackages": []string{"curl"},
+			},
+			base:     "alpine:3.14",
+			contains: []string{"RUN apk add --no-cache bash curl"},
+		},
+		{
+			name: "custom base image",
+			options: api.KindOptions{
+				"entrypoint": "main.sh",
+				"baseImage":  "alpine:3.14",
+			},
+			base:     "alpine:3.14",
+			contains: []string{"RUN command -v bash > /dev/null || apk add --no-cache bash"},
+		},
+		{
+			name: "multi-line setup",
+			options: api.KindOptions{
+				"entrypoint": "main.sh",
+				"setup":      "apt-get update\napt-get install -y \\\n  postgresql-client\n",
+			},
+			base:     "ubuntu",
+			contains: []string{"> /tmp/airplane-setup.sh && bash -e /tmp/airplane-setup.sh"},
+		},
+		{
+			name: "apk packages on the default base image",
+			options: api.KindOptions{
+				"entrypoint":  "main.sh",
+				"apkPackages": []string{"curl"},
+			},
+			err: true,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+
+			dockerfile, err := BuildDockerfile(DockerfileConfig{
+				Builder: string(NameShell),
+				Root:    "testdata/shell",
+				Options: test.options,
+			})
+			if test.err {
+				require.Error(err)
+				return
+			}
+			require.NoError(err)
+
+			// Bases that are listed in versions.json are pinned:
+			base := test.base
+			if v, err := GetVersion(NameShell, base); err == nil && v.Image != "" {
+				base = v.String()
+			}
+			instrs := instructions(dockerfile)
+			require.Equal("FROM "+base, instrs[0])
+			for _, c := range test.contains {
+				require.Contains(strings.Join(instrs, "\n"), c)
+			}
+			require.Equal(`ENTRYPOINT ["bash", ".airplane/shim.sh", "/airplane/main.sh"]`, instrs[len(instrs)-1])
+			if test.options["baseImage"] == nil || test.options["apkPackages"] != nil {
+				require.NotContains(dockerfile, "command -v bash")
+			}
+		})
+	}
+}
+
+func TestShellDefaultImage(t *testing.T) {
+	require := require.New(t)
+
+	// Shell tasks without a base image build from the pinned ubuntu image
+	// of versions.json:
+	dockerfile, err := BuildDockerfile(DockerfileConfig{
+		Builder: string(NameShell),
+		Root:    "testdata/shell",
+		Options: api.KindOptions{"entrypoint": "main.sh"},
+	})
+	require.NoError(err)
+
+	v, err := GetVersion(NameShell, "ubuntu")
+	require.NoError(err)
+	require.NotEmpty(v.Digest)
+	require.Equal("FROM "+v.String(), instructions(dockerfile)[0])
+}
+
+func TestShellSetup(t *testing.T) {
+	useTestVersions(t)
+	require := require.New(t)
+
+	setup := heredoc.Doc(`
+		# Install the CLI:
+		curl -sSL 'https://example.com/install.sh' \
+			| bash -s -- --version "1.2"
+		echo "done" > /tmp/setup.log
+	`)
+	dockerfile, err := BuildDockerfile(DockerfileConfig{
+		Builder: string(NameShell),
+		Root:    "testdata/shell",
+		Options: api.KindOptions{"entrypoint": "main.sh", "setup": setup},
+	})
+	require.NoError(err)
+
+	// Every line of the script is part of a single RUN instruction:
+	var run string
+	for _, instr := range instructions(dockerfile) {
+		if strings.Contains(instr, "airplane-setup.sh") {
+			require.Empty(run, "expected a single setup instruction")
+			run = instr
+		}
+	}
+	require.NotEmpty(run)
+	require.True(strings.HasPrefix(run, "RUN printf"), run)
+	for _, instr := range instructions(dockerfile) {
+		require.False(strings.HasPrefix(instr, "curl") || strings.HasPrefix(instr, "echo"), instr)
+	}
+
+	// The script is written verbatim, after the line continuations of the
+	// RUN instruction are joined:
+	require.Contains(dockerfile, "RUN "+inlinePrintf(setup)+" > /tmp/airplane-setup.sh")
+	write := strings.ReplaceAll(inlinePrintf(setup), "\\\n", "")
+	out, err := exec.Command("sh", "-c", write).Output()
+	require.NoError(err)
+	require.Equal(setup, string(out))
+}
+
+func TestShellShim(t *testing.T) {
+	require := require.New(t)
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash is not installed")
+	}
+
+	// The shim is written by the Dockerfile with printf, after the line
+	// continuations of the RUN instruction are joined:
+	write := strings.ReplaceAll(inlinePrintf(ShellShim()), "\\\n", "")
+	out, err := exec.Command("sh", "-c", write).Output()
+	require.NoError(err)
+	require.Equal(ShellShim(), string(out))
+
+	out, err = exec.Command("bash", "-c", ShellShim(), "shim", "env", "query=a=b&c=d", "name=").Output()
+	require.NoError(err)
+	require.Contains(string(out), "PARAM_QUERY=a=b&c=d\n")
+	require.Contains(string(out), "PARAM_NAME=\n")
+}
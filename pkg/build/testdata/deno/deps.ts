@@ -0,0 +1 @@
+export * as path from "https://deno.land/std@0.97.0/path/mod.ts";
@@ -0,0 +1,3 @@
+import { path } from "./deps.ts";
+
+console.log(path.join(Deno.args[0]));
@@ -0,0 +1,7 @@
+package tasks;
+
+public class Main {
+    public static void main(String[] args) {
+        System.out.println("parameters: " + args[0]);
+    }
+}
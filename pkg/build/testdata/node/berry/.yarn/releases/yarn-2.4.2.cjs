@@ -0,0 +1 @@
+// yarn release
@@ -0,0 +1,5 @@
+import { name } from "@monorepo/lib";
+
+export default async function () {
+  console.log(name);
+}
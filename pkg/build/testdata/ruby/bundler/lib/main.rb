@@ -0,0 +1,5 @@
+require "httparty"
+
+def main(params)
+  puts "parameters: #{params}"
+end
@@ -0,0 +1,3 @@
+def main(params)
+  puts "parameters: #{params}"
+end
@@ -0,0 +1,518 @@
+package build
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/airplanedev/cli/pkg/logger"
+	"github.com/dustin/go-humanize"
+	"github.com/pkg/errors"
+)
+
+const (
+	// uploadChunkSize is the size of the chunks of resumable uploads. GCS
+	// requires it to be a multiple of 256 KiB.
+	uploadChunkSize = 32 * 256 * 1024
+
+	// uploadRetries is the number of times that a chunk is retried.
+	uploadRetries = 6
+
+	// uploadIdleTimeout is how long a request may make no progress, f.e.
+	// because its connection stalled, before it's cancelled and retried.
+	uploadIdleTimeout = time.Minute
+)
+
+// statusResumeIncomplete is the status of a resumable upload that hasn't
+// received all bytes yet.
+const statusResumeIncomplete = 308
+
+// uploader uploads build archives to GCS as they are streamed.
+//
+// Archives are uploaded in chunks with GCS's resumable upload protocol,
+// which allows the total size to be unknown until the last chunk. Only
+// the current chunk and the next ones that are read ahead are buffered: if
+// a chunk fails, the uploader asks GCS how many bytes it persisted and
+// resumes from there. URLs that can't start a
+// resumable session are uploaded with a single PUT instead, which streams
+// the archive again on retries.
+//
+// See https://cloud.google.com/storage/docs/performing-resumable-uploads.
+type uploader struct {
+	client    *http.Client
+	chunkSize int64
+	retries   int
+	// backoff returns how long to wait before the given retry, from 1.
+	backoff func(retry int) time.Duration
+	// idleTimeout cancels requests that send no bytes and receive no
+	// response for this long. Zero disables the timeout.
+	idleTimeout time.Duration
+}
+
+func newUploader() *uploader {
+	return &uploader{
+		client:      &http.Client{},
+		chunkSize:   uploadChunkSize,
+		retries:     uploadRetries,
+		backoff:     exponentialBackoff(500*time.Millisecond, 10*time.Second),
+		idleTimeout: uploadIdleTimeout,
+	}
+}
+
+// do sends req, and cancels it once it made no progress for u.idleTimeout.
+//
+// A timeout of the whole request would fail large single-request uploads,
+// so the timeout is reset whenever a part of the body is sent. The response
+// must be closed to stop the timeout.
+func (u *uploader) do(req *http.Request) (*http.Response, error) {
+	if u.idleTimeout <= 0 {
+		return u.client.Do(req)
+	}
+
+	parent := req.Context()
+	ctx, cancel := context.WithCancel(parent)
+	timer := time.AfterFunc(u.idleTimeout, cancel)
+	stop := func() {
+		timer.Stop()
+		cancel()
+	}
+	req = req.WithContext(ctx)
+	if req.Body != nil && req.Body != http.NoBody {
+		req.Body = progressReader{ReadCloser: req.Body, progress: func() {
+			timer.Reset(u.idleTimeout)
+		}}
+	}
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		stop()
+		if parent.Err() == nil && ctx.Err() != nil {
+			return nil, errors.Errorf("no progress for %s", u.idleTimeout)
+		}
+		return nil, err
+	}
+	resp.Body = closeFunc{ReadCloser: resp.Body, close: stop}
+	return resp, nil
+}
+
+// progressReader calls progress whenever bytes are read from it.
+type progressReader struct {
+	io.ReadCloser
+	progress func()
+}
+
+func (r progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.progress()
+	}
+	return n, err
+}
+
+// closeFunc calls close after it was closed.
+type closeFunc struct {
+	io.ReadCloser
+	close func()
+}
+
+func (c closeFunc) Close() error {
+	err := c.ReadCloser.Close()
+	c.close()
+	return err
+}
+
+// exponentialBackoff doubles the wait of every retry, starting at min, up to max.
+func exponentialBackoff(min, max time.Duration) func(int) time.Duration {
+	return func(retry int) time.Duration {
+		d := min
+		for i := 1; i < retry && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+		return d
+	}
+}
+
+// retryableError is an error that is retried.
+type retryableError struct {
+	err error
+}
+
+func (e retryableError) Error() string {
+	return e.err.Error()
+}
+
+// statusError returns the error of an unexpected response, which is
+// retryable if the server may succeed later.
+func statusError(resp *http.Response) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	err := errors.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusRequestTimeout {
+		return retryableError{err}
+	}
+	return err
+}
+
+// retry calls fn until it succeeds, fails with a non-retryable error or
+// runs out of retries.
+func (u *uploader) retry(ctx context.Context, fn func() error) error {
+	for retry := 0; ; retry++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		var re retryableError
+		if !errors.As(err, &re) || retry >= u.retries {
+			return err
+		}
+		wait := u.backoff(retry + 1)
+		logger.Debug("Retrying upload in %s: %v", wait, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// upload uploads the archive that open streams to url. The archive may be
+// at most maxSize bytes.
+//
+// Open is called again to retry uploads that can't be resumed, so it must
+// stream the same bytes every time.
+func (u *uploader) upload(ctx context.Context, url string, maxSize int64, open func() io.ReadCloser) error {
+	var session string
+	err := u.retry(ctx, func() error {
+		var err error
+		session, err = u.startSession(ctx, url, maxSize)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if session == "" {
+		logger.Debug("Resumable uploads are not supported, uploading the archive at once")
+		return u.retry(ctx, func() error {
+			r := open()
+			defer r.Close()
+			return u.put(ctx, url, r, maxSize)
+		})
+	}
+
+	r := open()
+	defer r.Close()
+	return u.putChunks(ctx, session, r)
+}
+
+// startSession starts a resumable upload session and returns its URL, or
+// an empty string if url doesn't support resumable uploads.
+func (u *uploader) startSession(ctx context.Context, url string, maxSize int64) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "creating upload session request")
+	}
+	req.Header.Set("X-Goog-Resumable", "start")
+	req.Header.Set("X-Goog-Content-Length-Range", fmt.Sprintf("0,%d", maxSize))
+
+	resp, err := u.do(req)
+	if err != nil {
+		return "", retryableError{errors.Wrap(err, "starting upload session")}
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusCreated && resp.Header.Get("Location") != "":
+		return resp.Header.Get("Location"), nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusRequestTimeout:
+		// URLs that are signed for PUT requests reject the session request.
+		return "", nil
+	default:
+		return "", errors.Wrap(statusError(resp), "starting upload session")
+	}
+}
+
+// put uploads the whole archive with a single request. Its size is
+// unknown, so it's sent with chunked transfer encoding.
+func (u *uploader) put(ctx context.Context, url string, body io.Reader, maxSize int64) error {
+	req, err := http.NewRequestWithContext(ctx, "PUT", url, body)
+	if err != nil {
+		return errors.Wrap(err, "creating upload request")
+	}
+	req.ContentLength = -1
+	req.Header.Set("X-Goog-Content-Length-Range", fmt.Sprintf("0,%d", maxSize))
+
+	resp, err := u.do(req)
+	if err != nil {
+		return retryableError{errors.Wrap(err, "uploading to GCS")}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return errors.Wrap(statusError(resp), "uploading to GCS")
+	}
+	return nil
+}
+
+// putChunks uploads r to session, one chunk at a time.
+//
+// Chunks can't be uploaded concurrently: a resumable session only accepts
+// bytes at the offset that GCS persisted so far, and parallel composite
+// uploads would need an object per part, which the signed upload URL
+// doesn't allow. Instead, the next chunk is read from r, which usually
+// streams an archive that is still being written, while the current chunk
+// is uploaded.
+func (u *uploader) putChunks(ctx context.Context, session string, r io.Reader) error {
+	ctx, cancel := context.WithCancel(ctx)
+	chunks := make(chan uploadChunk, 1)
+	go u.readChunks(ctx, r, chunks)
+	defer func() {
+		// Wait for the reader, r must not be read once this returns.
+		cancel()
+		for range chunks {
+		}
+	}()
+
+	for c := range chunks {
+		if c.err != nil {
+			return c.err
+		}
+		if err := u.putChunk(ctx, session, c.data, c.offset, c.total); err != nil {
+			return err
+		}
+		if c.total >= 0 {
+			return nil
+		}
+	}
+	return ctx.Err()
+}
+
+// uploadChunk is a chunk of an archive that is uploaded to a resumable
+// session.
+type uploadChunk struct {
+	data   []byte
+	offset int64
+	// total is the size of the archive if this is the last chunk, -1
+	// otherwise.
+	total int64
+	err   error
+}
+
+// readChunks reads r into chunks until it's read entirely, fails or ctx is
+// cancelled, and closes chunks.
+func (u *uploader) readChunks(ctx context.Context, r io.Reader, chunks chan<- uploadChunk) {
+	defer close(chunks)
+
+	br := bufio.NewReader(r)
+	var offset int64
+	for {
+		buf := make([]byte, u.chunkSize)
+		n, err := io.ReadFull(br, buf)
+		c := uploadChunk{data: buf[:n], offset: offset, total: -1}
+
+		// The total size is sent with the last chunk.
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			c.err = errors.Wrap(err, "reading archive")
+		} else if err != nil {
+			c.total = offset + int64(n)
+		} else if _, err := br.Peek(1); err == io.EOF {
+			c.total = offset + int64(n)
+		} else if err != nil {
+			c.err = errors.Wrap(err, "reading archive")
+		}
+
+		select {
+		case chunks <- c:
+		case <-ctx.Done():
+			return
+		}
+		if c.err != nil || c.total >= 0 {
+			return
+		}
+		offset += int64(n)
+	}
+}
+
+// putChunk uploads chunk, which starts at offset, to session. Total is the
+// size of the archive if chunk is the last chunk, -1 otherwise.
+func (u *uploader) putChunk(ctx context.Context, session string, chunk []byte, offset, total int64) error {
+	end := offset + int64(len(chunk))
+	start := offset
+	return u.retry(ctx, func() error {
+		for {
+			next, complete, err := u.putBytes(ctx, session, chunk[start-offset:], start, total)
+			if err != nil {
+				// The connection may drop after GCS persisted some of
+				// the chunk, so ask it where to resume.
+				if next, complete, qerr := u.queryOffset(ctx, session, total); qerr == nil {
+					if complete || (total < 0 && next >= end) {
+						return nil
+					}
+					if next < offset || next > end {
+						return errors.Errorf("upload resumes at byte %d, outside of bytes %d-%d", next, offset, end-1)
+					}
+					start = next
+				}
+				return err
+			}
+			if complete || (total < 0 && next >= end) {
+				return nil
+			}
+			if next < offset || next > end {
+				return errors.Errorf("upload resumes at byte %d, outside of bytes %d-%d", next, offset, end-1)
+			}
+			if next <= start {
+				return retryableError{errors.Errorf("upload made no progress at byte %d", start)}
+			}
+			// GCS persisted only part of the chunk.
+			start = next
+		}
+	})
+}
+
+// putBytes uploads data, which starts at offset, to session and returns
+// the offset that the next request starts at.
+func (u *uploader) putBytes(ctx context.Context, session string, data []byte, offset, total int64) (int64, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "PUT", session, bytes.NewReader(data))
+	if err != nil {
+		return 0, false, errors.Wrap(err, "creating chunk request")
+	}
+	size := "*"
+	if total >= 0 {
+		size = strconv.FormatInt(total, 10)
+	}
+	if len(data) == 0 {
+		req.Header.Set("Content-Range", "bytes */"+size)
+	} else {
+		req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%s", offset, offset+int64(len(data))-1, size))
+	}
+
+	resp, err := u.do(req)
+	if err != nil {
+		return 0, false, retryableError{errors.Wrapf(err, "uploading bytes %d-%d", offset, offset+int64(len(data))-1)}
+	}
+	defer resp.Body.Close()
+	return uploadOffset(resp)
+}
+
+// queryOffset asks GCS how many bytes of the session it persisted.
+func (u *uploader) queryOffset(ctx context.Context, session string, total int64) (int64, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "PUT", session, nil)
+	if err != nil {
+		return 0, false, errors.Wrap(err, "creating upload status request")
+	}
+	size := "*"
+	if total >= 0 {
+		size = strconv.FormatInt(total, 10)
+	}
+	req.Header.Set("Content-Range", "bytes */"+size)
+
+	resp, err := u.do(req)
+	if err != nil {
+		return 0, false, errors.Wrap(err, "getting upload status")
+	}
+	defer resp.Body.Close()
+	return uploadOffset(resp)
+}
+
+// uploadOffset returns the number of bytes that GCS persisted, according
+// to the response of a chunk or status request, and whether the upload is
+// complete.
+func uploadOffset(resp *http.Response) (int64, bool, error) {
+	switch {
+	case resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated:
+		return 0, true, nil
+	case resp.StatusCode == statusResumeIncomplete:
+		// The Range header, f.e. `bytes=0-1023`, is missing if no bytes
+		// were persisted yet.
+		rng := resp.Header.Get("Range")
+		if rng == "" {
+			return 0, false, nil
+		}
+		i := strings.LastIndex(rng, "-")
+		last, err := strconv.ParseInt(rng[i+1:], 10, 64)
+		if i < 0 || err != nil {
+			return 0, false, errors.Errorf("unexpected upload range %q", rng)
+		}
+		return last + 1, false, nil
+	default:
+		return 0, false, errors.Wrap(statusError(resp), "uploading chunk")
+	}
+}
+
+// progressBar draws the progress of an upload.
+type progressBar struct {
+	w     io.Writer
+	total int64
+
+	mu      sync.Mutex
+	current int64
+	drawn   time.Time
+}
+
+// newProgressBar returns a bar that is drawn on w, or a bar that isn't
+// drawn if w is nil.
+func newProgressBar(w io.Writer, total int64) *progressBar {
+	return &progressBar{w: w, total: total}
+}
+
+// add advances the bar by n bytes.
+func (p *progressBar) add(n int64) {
+	if p.w == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.current += n
+	if time.Since(p.drawn) < 100*time.Millisecond && p.current < p.total {
+		return
+	}
+	p.drawn = time.Now()
+	p.draw()
+}
+
+// reset moves the bar back to the start, f.e. when an upload is retried.
+func (p *progressBar) reset() {
+	if p.w == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.current = 0
+	p.draw()
+}
+
+// done finishes the bar.
+func (p *progressBar) done() {
+	if p.w == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.draw()
+	fmt.Fprintln(p.w)
+}
+
+func (p *progressBar) draw() {
+	const width = 30
+	ratio := 1.0
+	if p.total > 0 && p.current < p.total {
+		ratio = float64(p.current) / float64(p.total)
+	}
+	filled := int(ratio * width)
+	fmt.Fprintf(p.w, "\r[%s%s] %3.0f%% %s / %s ",
+		strings.Repeat("=", filled),
+		strings.Repeat(" ", width-filled),
+		ratio*100,
+		humanize.Bytes(uint64(p.current)),
+		humanize.Bytes(uint64(p.total)),
+	)
+}
@@ -0,0 +1,320 @@
+package build
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// gcsStandIn is a local stand-in for GCS's resumable upload protocol that
+// drops connections mid-upload.
+type gcsStandIn struct {
+	t *testing.T
+	// resumable is false if session requests are rejected, like URLs that
+	// are signed for PUT requests.
+	resumable bool
+	// drop is the number of requests whose connection is dropped after
+	// some of their body was read, keyed by the offset they start at.
+	drop map[int64]int
+	// unavailable is the number of chunk requests that fail with 503.
+	unavailable int
+	// stall is the number of requests that never receive a response,
+	// keyed by the offset they start at.
+	stall map[int64]int
+
+	mu   sync.Mutex
+	data []byte
+}
+
+func (s *gcsStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case r.Method == "POST" && r.URL.Path == "/upload":
+		if !s.resumable || r.Header.Get("X-Goog-Resumable") != "start" {
+			http.Error(w, "SignatureDoesNotMatch", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Location", "http://"+r.Host+"/session")
+		w.WriteHeader(http.StatusCreated)
+
+	case r.Method == "PUT" && r.URL.Path == "/upload":
+		if s.resumable {
+			http.Error(w, "SignatureDoesNotMatch", http.StatusForbidden)
+			return
+		}
+		if s.drop[0] > 0 {
+			s.drop[0]--
+			s.hangUp(w, r)
+			return
+		}
+		if s.stall[0] > 0 {
+			s.stall[0]--
+			s.hang(r)
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(s.t, err)
+		s.data = body
+		w.WriteHeader(http.StatusOK)
+
+	case r.Method == "PUT" && r.URL.Path == "/session":
+		start, end, size := s.parseRange(r.Header.Get("Content-Range"))
+		if start < 0 {
+			// A status request.
+			s.writeStatus(w, size)
+			return
+		}
+		require.Equal(s.t, int64(len(s.data)), start, "chunks must be uploaded in order")
+		if s.drop[start] > 0 {
+			s.drop[start]--
+			s.hangUp(w, r)
+			return
+		}
+		if s.stall[start] > 0 {
+			s.stall[start]--
+			s.hang(r)
+			return
+		}
+		if s.unavailable > 0 {
+			s.unavailable--
+			http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(s.t, err)
+		require.Equal(s.t, end-start+1, int64(len(body)))
+		s.data = append(s.data, body...)
+		if size < 0 {
+			require.Zero(s.t, len(s.data)%1024, "only the last chunk may be partial")
+		}
+		s.writeStatus(w, size)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// hangUp reads some of the body of r and closes the connection without
+// responding. Nothing of the body is persisted.
+func (s *gcsStandIn) hangUp(w http.ResponseWriter, r *http.Request) {
+	_, err := io.CopyN(ioutil.Discard, r.Body, 100)
+	if err != io.EOF {
+		require.NoError(s.t, err)
+	}
+	conn, _, err := w.(http.Hijacker).Hijack()
+	require.NoError(s.t, err)
+	conn.Close()
+}
+
+// hang reads the body of r and doesn't respond until the client cancels
+// the request. Nothing of the body is persisted.
+func (s *gcsStandIn) hang(r *http.Request) {
+	_, err := io.Copy(ioutil.Discard, r.Body)
+	require.NoError(s.t, err)
+	s.mu.Unlock()
+	defer s.mu.Lock()
+	<-r.Context().Done()
+}
+
+func (s *gcsStandIn) writeStatus(w http.ResponseWriter, size int64) {
+	if size >= 0 && int64(len(s.data)) == size {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if len(s.data) > 0 {
+		w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(s.data)-1))
+	}
+	w.WriteHeader(statusResumeIncomplete)
+}
+
+// parseRange parses a `bytes <start>-<end>/<size>` or `bytes */<size>`
+// Content-Range header. Start is -1 for the latter, size is -1 if it's
+// unknown.
+func (s *gcsStandIn) parseRange(h string) (start, end, size int64) {
+	h = strings.TrimPrefix(h, "bytes ")
+	parts := strings.SplitN(h, "/", 2)
+	require.Len(s.t, parts, 2, "malformed Content-Range %q", h)
+	size = -1
+	if parts[1] != "*" {
+		var err error
+		size, err = strconv.ParseInt(parts[1], 10, 64)
+		require.NoError(s.t, err)
+	}
+	if parts[0] == "*" {
+		return -1, -1, size
+	}
+	_, err := fmt.Sscanf(parts[0], "%d-%d", &start, &end)
+	require.NoError(s.t, err)
+	return start, end, size
+}
+
+func TestUploader(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		server *gcsStandIn
+		err    string
+		// size is the size of the archive, defaults to 10 chunks and a
+		// partial last chunk.
+		size int
+		// opened is the number of times that the archive is streamed.
+		opened int
+	}{
+		{
+			name:   "resumable",
+			server: &gcsStandIn{resumable: true},
+			opened: 1,
+		},
+		{
+			name:   "resumable with a full last chunk",
+			server: &gcsStandIn{resumable: true, drop: map[int64]int{9 * 1024: 1}},
+			size:   10 * 1024,
+			opened: 1,
+		},
+		{
+			name: "resumable with dropped connections",
+			server: &gcsStandIn{
+				resumable:   true,
+				drop:        map[int64]int{0: 1, 3 * 1024: 2, 10 * 1024: 1},
+				unavailable: 1,
+			},
+			opened: 1,
+		},
+		{
+			name:   "resumable runs out of retries",
+			server: &gcsStandIn{resumable: true, drop: map[int64]int{2 * 1024: 4}},
+			err:    "uploading bytes 2048-3071",
+		},
+		{
+			name:   "resumable with stalled requests",
+			server: &gcsStandIn{resumable: true, stall: map[int64]int{0: 1, 5 * 1024: 1}},
+			opened: 1,
+		},
+		{
+			name:   "single request with a stalled request",
+			server: &gcsStandIn{stall: map[int64]int{0: 1}},
+			opened: 2,
+		},
+		{
+			name:   "single request",
+			server: &gcsStandIn{drop: map[int64]int{0: 2}},
+			opened: 3,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+			test.server.t = t
+			if test.size == 0 {
+				test.size = 10*1024 + 17
+			}
+			data := make([]byte, test.size)
+			rand.New(rand.NewSource(1)).Read(data)
+			srv := httptest.NewServer(test.server)
+			defer srv.Close()
+
+			u := &uploader{
+				client:      srv.Client(),
+				chunkSize:   1024,
+				retries:     3,
+				backoff:     func(int) time.Duration { return time.Millisecond },
+				idleTimeout: 100 * time.Millisecond,
+			}
+			var opened int
+			err := u.upload(context.Background(), srv.URL+"/upload", int64(len(data)), func() io.ReadCloser {
+				opened++
+				return ioutil.NopCloser(bytes.NewReader(data))
+			})
+			if test.err != "" {
+				require.Error(err)
+				require.Contains(err.Error(), test.err)
+				return
+			}
+			require.NoError(err)
+			require.Equal(data, test.server.data)
+			for offset, n := range test.server.drop {
+				require.Zero(n, "connection at offset %d was not dropped", offset)
+			}
+			for offset, n := range test.server.stall {
+				require.Zero(n, "request at offset %d did not stall", offset)
+			}
+			require.Zero(test.server.unavailable)
+			require.Equal(test.opened, opened)
+		})
+	}
+}
+
+func TestUploaderReadsAhead(t *testing.T) {
+	require := require.New(t)
+
+	server := &gcsStandIn{t: t, resumable: true}
+	readAhead := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first chunk is only accepted once the next one was read.
+		if r.URL.Path == "/session" && strings.HasPrefix(r.Header.Get("Content-Range"), "bytes 0-") {
+			select {
+			case <-readAhead:
+			case <-time.After(time.Second):
+				t.Error("the next chunk was not read while the first one was uploaded")
+			}
+		}
+		server.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	// Chunks are larger than the read buffer, so that reading the second
+	// chunk is only done ahead of the upload of the first one.
+	const chunkSize = 8 * 1024
+	data := make([]byte, 3*chunkSize)
+	rand.New(rand.NewSource(1)).Read(data)
+	u := &uploader{
+		client:    srv.Client(),
+		chunkSize: chunkSize,
+		backoff:   func(int) time.Duration { return time.Millisecond },
+	}
+	err := u.upload(context.Background(), srv.URL+"/upload", int64(len(data)), func() io.ReadCloser {
+		return ioutil.NopCloser(&notifyReader{r: bytes.NewReader(data), after: 2 * chunkSize, notify: readAhead})
+	})
+	require.NoError(err)
+	require.Equal(data, server.data)
+}
+
+// notifyReader closes notify once more than after bytes were read from r.
+type notifyReader struct {
+	r      io.Reader
+	after  int
+	notify chan struct{}
+	read   int
+}
+
+func (r *notifyReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if r.read <= r.after && r.read+n > r.after {
+		close(r.notify)
+	}
+	r.read += n
+	return n, err
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	require := require.New(t)
+
+	backoff := exponentialBackoff(time.Second, 5*time.Second)
+	require.Equal(time.Second, backoff(1))
+	require.Equal(2*time.Second, backoff(2))
+	require.Equal(4*time.Second, backoff(3))
+	require.Equal(5*time.Second, backoff(4))
+	require.Equal(5*time.Second, backoff(10))
+}
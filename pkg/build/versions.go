@@ -12,6 +12,10 @@ import (
 // If you change the versions in this file, make sure to:
 //   1. Update the digest to match. The tags are just a convenience to note
 //      which version the digest correlates to without consulting DockerHub.
+//      Digests for platforms other than linux/amd64 go into `digests`, keyed
+//      by platform. scripts/pin_versions.sh fills in missing digests.
+//      Builder tests use testdata/versions.json, which lists the same
+//      versions with fake digests.
 //   2. Manually push the new base images into the public cache in the
 //      Airplane Registry. See Slab:
 //      https://airplane.slab.com/posts/publishing-to-the-public-cache-registry-8bzwq93d
@@ -23,8 +27,9 @@ import (
 var versionsJSON []byte
 
 // Versions contains a mapping table of (builder, version) to
-// (node, tag, digest) image tuples. The digests are always for
-// images built for the linux/amd64 architecture.
+// (node, tag, digest) image tuples. The digest is always for
+// images built for the linux/amd64 architecture, digests for other
+// platforms are listed in `digests`, keyed by platform.
 //
 // This lookup table is used to construct Dockerfiles that always
 // pull from the most-up-date version of the underlying base image
@@ -32,9 +37,10 @@ var versionsJSON []byte
 type Versions map[string]map[string]Version
 
 type Version struct {
-	Image  string `json:"image"`
-	Tag    string `json:"tag"`
-	Digest string `json:"digest"`
+	Image   string            `json:"image"`
+	Tag     string            `json:"tag"`
+	Digest  string            `json:"digest,omitempty"`
+	Digests map[string]string `json:"digests,omitempty"`
 }
 
 func (v Version) String() string {
@@ -45,6 +51,32 @@ func (v Version) String() string {
 	return v.Image + "@" + v.Digest
 }
 
+// ForPlatform returns the image for the given platform, f.e. linux/arm64,
+// or an empty string if it isn't pinned to a digest for platform.
+func (v Version) ForPlatform(platform string) string {
+	if platform == "" || platform == DefaultPlatform {
+		return v.String()
+	}
+	if v.Image == "" || v.Digests[platform] == "" {
+		return ""
+	}
+	return v.Image + "@" + v.Digests[platform]
+}
+
+// getImage returns the image of builder's version, pinned to its digest, or
+// an empty string if the version isn't listed. Versions that are listed but
+// not pinned yet are an error, since their tag may be moved.
+func getImage(builder Name, version string) (string, error) {
+	v, err := GetVersion(builder, version)
+	if err != nil {
+		return "", err
+	}
+	if v.Image != "" && v.Digest == "" {
+		return "", errors.Errorf("the %s %s base image (%s:%s) is not pinned to a digest yet", builder, version, v.Image, v.Tag)
+	}
+	return v.String(), nil
+}
+
 func GetVersions() (Versions, error) {
 	var versions Versions
 	if err := json.Unmarshal(versionsJSON, &versions); err != nil {
@@ -0,0 +1,103 @@
+package build
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var digestRegex = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+
+// useTestVersions replaces versions.json with testdata/versions.json for the
+// rest of the test. The fixture lists the same versions as versions.json, but
+// pins all of them to fake digests for linux/amd64 and linux/arm64, so that
+// builders can be tested regardless of which base images are pinned yet.
+func useTestVersions(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/versions.json")
+	require.NoError(t, err)
+	orig := versionsJSON
+	versionsJSON = buf
+	t.Cleanup(func() { versionsJSON = orig })
+}
+
+func TestVersions(t *testing.T) {
+	require := require.New(t)
+
+	var versions Versions
+	require.NoError(json.Unmarshal(versionsJSON, &versions))
+	var unpinned []string
+	for builder, vs := range versions {
+		for name, v := range vs {
+			require.NotEmpty(v.Image, "%s %s", builder, name)
+			require.NotEmpty(v.Tag, "%s %s", builder, name)
+			if v.Digest == "" {
+				unpinned = append(unpinned, fmt.Sprintf("%s %s (%s:%s)", builder, name, v.Image, v.Tag))
+			} else {
+				require.Regexp(digestRegex, v.Digest, "%s %s", builder, name)
+			}
+			for p, digest := range v.Digests {
+				require.Regexp(platformRegex, p, "%s %s", builder, name)
+				require.Regexp(digestRegex, digest, "%s %s %s", builder, name, p)
+				require.NotEqual(v.Digest, digest, "%s %s %s has the digest of %s", builder, name, p, DefaultPlatform)
+			}
+		}
+	}
+	sort.Strings(unpinned)
+	require.Empty(unpinned, "base images must be pinned, run scripts/pin_versions.sh")
+}
+
+func TestTestVersions(t *testing.T) {
+	require := require.New(t)
+
+	var versions, fixture Versions
+	require.NoError(json.Unmarshal(versionsJSON, &versions))
+	buf, err := ioutil.ReadFile("testdata/versions.json")
+	require.NoError(err)
+	require.NoError(json.Unmarshal(buf, &fixture))
+
+	// The fixture must be updated along with versions.json:
+	strip := func(versions Versions) map[string]map[string]string {
+		images := map[string]map[string]string{}
+		for builder, vs := range versions {
+			images[builder] = map[string]string{}
+			for name, v := range vs {
+				images[builder][name] = v.Image + ":" + v.Tag
+			}
+		}
+		return images
+	}
+	require.Equal(strip(versions), strip(fixture))
+}
+
+func TestGetImage(t *testing.T) {
+	require := require.New(t)
+
+	orig := versionsJSON
+	defer func() { versionsJSON = orig }()
+	versionsJSON = []byte(`{
+		"ruby": {
+			"3": {"image": "registry.hub.docker.com/library/ruby", "tag": "3.0.2-buster", "digest": "sha256:f00"},
+			"2.7": {"image": "registry.hub.docker.com/library/ruby", "tag": "2.7.4-buster"}
+		}
+	}`)
+
+	image, err := getImage(NameRuby, "3")
+	require.NoError(err)
+	require.Equal("registry.hub.docker.com/library/ruby@sha256:f00", image)
+
+	// Versions that aren't listed are left to the builder:
+	image, err = getImage(NameRuby, "1.9")
+	require.NoError(err)
+	require.Empty(image)
+
+	// Tags are never used instead of digests:
+	_, err = getImage(NameRuby, "2.7")
+	require.EqualError(err, "the ruby 2.7 base image (registry.hub.docker.com/library/ruby:2.7.4-buster) is not pinned to a digest yet")
+	_, err = getBaseRubyImage("2.7")
+	require.Error(err)
+}
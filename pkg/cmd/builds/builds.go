@@ -0,0 +1,38 @@
+package builds
+
+import (
+	"github.com/MakeNowJust/heredoc"
+	"github.com/airplanedev/cli/pkg/cli"
+	"github.com/airplanedev/cli/pkg/cmd/auth/login"
+	"github.com/airplanedev/cli/pkg/cmd/builds/cancel"
+	"github.com/airplanedev/cli/pkg/cmd/builds/get"
+	"github.com/airplanedev/cli/pkg/cmd/builds/list"
+	"github.com/airplanedev/cli/pkg/cmd/builds/logs"
+	"github.com/airplanedev/cli/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+// New returns a new cobra command.
+func New(c *cli.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "builds",
+		Short: "Manage remote builds",
+		Long:  "Manage builds of tasks on Airplane-hosted builders",
+		Example: heredoc.Doc(`
+			airplane builds list --task my-task
+			airplane builds get <id>
+			airplane builds logs --follow <id>
+			airplane builds cancel <id>
+		`),
+		PersistentPreRunE: utils.WithParentPersistentPreRunE(func(cmd *cobra.Command, args []string) error {
+			return login.EnsureLoggedIn(cmd.Root().Context(), c)
+		}),
+	}
+
+	cmd.AddCommand(list.New(c))
+	cmd.AddCommand(get.New(c))
+	cmd.AddCommand(logs.New(c))
+	cmd.AddCommand(cancel.New(c))
+
+	return cmd
+}
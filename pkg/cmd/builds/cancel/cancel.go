@@ -0,0 +1,47 @@
+package cancel
+
+import (
+	"context"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/airplanedev/cli/pkg/cli"
+	"github.com/airplanedev/cli/pkg/logger"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// New returns a new cancel command.
+func New(c *cli.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cancel",
+		Short: "Cancel a build",
+		Example: heredoc.Doc(`
+			airplane builds cancel <id>
+		`),
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd.Root().Context(), c, args[0])
+		},
+	}
+	return cmd
+}
+
+// Run runs the cancel command.
+func run(ctx context.Context, c *cli.Config, id string) error {
+	var client = c.Client
+
+	resp, err := client.GetBuild(ctx, id)
+	if err != nil {
+		return errors.Wrap(err, "get build")
+	}
+	if resp.Build.Status.Stopped() {
+		return errors.Errorf("build %s already stopped: %s", id, resp.Build.Status)
+	}
+
+	if err := client.CancelBuild(ctx, id); err != nil {
+		return errors.Wrap(err, "cancel build")
+	}
+
+	logger.Log("Cancelled build %s", id)
+	return nil
+}
@@ -0,0 +1,42 @@
+package get
+
+import (
+	"context"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/airplanedev/cli/pkg/cli"
+	"github.com/airplanedev/cli/pkg/print"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// New returns a new get command.
+func New(c *cli.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "get",
+		Short: "Get information about a build",
+		Example: heredoc.Doc(`
+			airplane builds get <id>
+			airplane builds get <id> -o yaml
+			airplane builds get <id> -o json
+		`),
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd.Root().Context(), c, args[0])
+		},
+	}
+	return cmd
+}
+
+// Run runs the get command.
+func run(ctx context.Context, c *cli.Config, id string) error {
+	var client = c.Client
+
+	resp, err := client.GetBuild(ctx, id)
+	if err != nil {
+		return errors.Wrap(err, "get build")
+	}
+
+	print.Build(resp.Build)
+	return nil
+}
@@ -0,0 +1,66 @@
+package list
+
+import (
+	"context"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/airplanedev/cli/pkg/api"
+	"github.com/airplanedev/cli/pkg/cli"
+	"github.com/airplanedev/cli/pkg/print"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type config struct {
+	slug  string
+	limit int
+}
+
+// New returns a new list command.
+func New(c *cli.Config) *cobra.Command {
+	var cfg config
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "Lists builds",
+		Example: heredoc.Doc(`
+			airplane builds list
+			airplane builds list --task <slug>
+			airplane builds list --task <slug> -o json
+		`),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd.Root().Context(), c, cfg)
+		},
+	}
+
+	cmd.Flags().StringVarP(&cfg.slug, "task", "t", "", "Filter builds by task slug")
+	cmd.Flags().IntVar(&cfg.limit, "limit", 100, "If >0, returns at most --limit items.")
+
+	return cmd
+}
+
+// Run runs the list command.
+func run(ctx context.Context, c *cli.Config, cfg config) error {
+	var client = c.Client
+
+	req := api.ListBuildsRequest{
+		Limit: cfg.limit,
+	}
+
+	// If a task slug was provided, look up its task ID:
+	if cfg.slug != "" {
+		task, err := client.GetTask(ctx, cfg.slug)
+		if err != nil {
+			return err
+		}
+		req.TaskID = task.ID
+	}
+
+	resp, err := client.ListBuilds(ctx, req)
+	if err != nil {
+		return errors.Wrap(err, "list builds")
+	}
+
+	print.Builds(resp.Builds)
+	return nil
+}
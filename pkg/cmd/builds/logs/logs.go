@@ -0,0 +1,92 @@
+package logs
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/airplanedev/cli/pkg/api"
+	"github.com/airplanedev/cli/pkg/cli"
+	"github.com/airplanedev/cli/pkg/logger"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type config struct {
+	id     string
+	follow bool
+}
+
+// New returns a new logs command.
+func New(c *cli.Config) *cobra.Command {
+	var cfg config
+
+	cmd := &cobra.Command{
+		Use:   "logs",
+		Short: "Print the logs of a build",
+		Example: heredoc.Doc(`
+			airplane builds logs <id>
+			airplane builds logs --follow <id>
+		`),
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg.id = args[0]
+			return run(cmd.Root().Context(), c, cfg)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&cfg.follow, "follow", "f", false, "Keep printing logs until the build stops.")
+
+	return cmd
+}
+
+// Run runs the logs command.
+func run(ctx context.Context, c *cli.Config, cfg config) error {
+	var client = c.Client
+
+	t := time.NewTicker(time.Second)
+	defer t.Stop()
+
+	var since time.Time
+	var logs []api.LogItem
+	for {
+		// Check the status before getting the logs, so no logs are missed
+		// when the build stops in between.
+		b, err := client.GetBuild(ctx, cfg.id)
+		if err != nil {
+			return errors.Wrap(err, "get build")
+		}
+
+		r, err := client.GetBuildLogs(ctx, cfg.id, since)
+		if err != nil {
+			return errors.Wrap(err, "get build logs")
+		}
+		if len(r.Logs) > 0 {
+			since = r.Logs[len(r.Logs)-1].Timestamp
+		}
+
+		newLogs := api.DedupeLogs(logs, r.Logs)
+		for _, l := range newLogs {
+			text := l.Text
+			if strings.HasPrefix(text, "[builder] ") {
+				text = logger.Gray(strings.TrimPrefix(text, "[builder] "))
+			}
+			if l.Level == api.LogLevelDebug {
+				text = "[" + logger.Blue("debug") + "] " + text
+			}
+			logger.Log("%s", text)
+		}
+		logs = append(logs, newLogs...)
+
+		if !cfg.follow || b.Build.Status.Stopped() {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
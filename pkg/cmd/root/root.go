@@ -12,9 +12,11 @@ import (
 	"github.com/airplanedev/cli/pkg/cmd/auth"
 	"github.com/airplanedev/cli/pkg/cmd/auth/login"
 	"github.com/airplanedev/cli/pkg/cmd/auth/logout"
+	"github.com/airplanedev/cli/pkg/cmd/builds"
 	"github.com/airplanedev/cli/pkg/cmd/configs"
 	"github.com/airplanedev/cli/pkg/cmd/runs"
 	"github.com/airplanedev/cli/pkg/cmd/tasks"
+	"github.com/airplanedev/cli/pkg/cmd/tasks/buildcmd"
 	"github.com/airplanedev/cli/pkg/cmd/tasks/deploy"
 	"github.com/airplanedev/cli/pkg/cmd/tasks/dev"
 	"github.com/airplanedev/cli/pkg/cmd/tasks/execute"
@@ -100,6 +102,7 @@ func New() *cobra.Command {
 
 	// Aliases for popular namespaced commands:
 	cmd.AddCommand(initcmd.New(cfg))
+	cmd.AddCommand(buildcmd.New(cfg))
 	cmd.AddCommand(deploy.New(cfg))
 	cmd.AddCommand(dev.New(cfg))
 	cmd.AddCommand(execute.New(cfg))
@@ -112,6 +115,7 @@ func New() *cobra.Command {
 	cmd.AddCommand(configs.New(cfg))
 	cmd.AddCommand(tasks.New(cfg))
 	cmd.AddCommand(runs.New(cfg))
+	cmd.AddCommand(builds.New(cfg))
 	cmd.AddCommand(version.New(cfg))
 
 	return cmd
@@ -0,0 +1,250 @@
+// buildcmd defines the implementation of the `airplane tasks build` command.
+//
+// The package isn't named "build" since that conflicts with the build package
+// that implements the builders.
+package buildcmd
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/airplanedev/cli/pkg/api"
+	"github.com/airplanedev/cli/pkg/build"
+	"github.com/airplanedev/cli/pkg/cli"
+	"github.com/airplanedev/cli/pkg/cmd/auth/login"
+	"github.com/airplanedev/cli/pkg/cmd/tasks/deploy"
+	"github.com/airplanedev/cli/pkg/logger"
+	"github.com/airplanedev/cli/pkg/print"
+	"github.com/airplanedev/cli/pkg/taskdir"
+	"github.com/airplanedev/cli/pkg/taskdir/definitions"
+	"github.com/airplanedev/cli/pkg/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type config struct {
+	root         *cli.Config
+	file         string
+	slug         string
+	vars         []string
+	local        bool
+	push         bool
+	tag          string
+	platform     string
+	buildTimeout time.Duration
+
+	outputDockerfile string
+	outputContext    string
+	sbom             deploy.SBOMFlags
+	contextFlags     deploy.ContextFlags
+	contextOpts      build.ContextOptions
+}
+
+// New returns a new build command.
+func New(c *cli.Config) *cobra.Command {
+	var cfg = config{root: c}
+
+	cmd := &cobra.Command{
+		Use:   "build ./path/to/file",
+		Short: "Build a task without deploying it",
+		Long: heredoc.Doc(`
+			Builds the image of a task and prints its URL, without updating the task.
+
+			Remote builds use the build configuration of the deployed task, build
+			with --local to try out changes to it.
+		`),
+		Example: heredoc.Doc(`
+			airplane build ./task.ts
+			airplane build --local --push=false ./my-task.yml
+			airplane build --local --tag debug --output-dockerfile Dockerfile ./my-task.yml
+			airplane build --output-context ./context ./my-task.yml
+			airplane build --sbom sbom.json --sbom-format cyclonedx ./my-task.yml
+			airplane build --explain-context ./my-task.yml
+			airplane build --build-timeout 30m ./my-task.yml
+		`),
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg.file = args[0]
+			var err error
+			if cfg.contextOpts, err = cfg.contextFlags.Options(cmd.Flags(), cfg.local); err != nil {
+				return err
+			}
+			return run(cmd.Root().Context(), cfg)
+		},
+		PersistentPreRunE: utils.WithParentPersistentPreRunE(func(cmd *cobra.Command, args []string) error {
+			return login.EnsureLoggedIn(cmd.Root().Context(), c)
+		}),
+	}
+
+	cmd.Flags().BoolVarP(&cfg.local, "local", "L", false, "use a local Docker daemon (instead of an Airplane-hosted builder)")
+	cmd.Flags().BoolVar(&cfg.push, "push", true, "Push the image to the registry, requires --local to disable.")
+	cmd.Flags().StringVar(&cfg.tag, "tag", "", "Tag of the image, requires --local. Defaults to latest.")
+	cmd.Flags().StringVar(&cfg.platform, "platform", "", "Comma-separated platforms to build for, f.e. linux/arm64.")
+	cmd.Flags().StringVar(&cfg.slug, "slug", "", "Slug of the task to build, if the file defines multiple tasks.")
+	cmd.Flags().StringArrayVar(&cfg.vars, "var", nil, "Set a task definition variable (key=value). Can be repeated.")
+	cmd.Flags().StringVar(&cfg.outputDockerfile, "output-dockerfile", "", "Write the generated Dockerfile to this path. Remote builders generate their own Dockerfile, which may differ if they run a different version.")
+	cmd.Flags().StringVar(&cfg.outputContext, "output-context", "", "Write the build context, after applying ignore rules, to this directory.")
+	cmd.Flags().DurationVar(&cfg.buildTimeout, "build-timeout", 0, "Cancel the build if it takes longer than this, f.e. 30m. Defaults to no timeout.")
+	cfg.sbom.Register(cmd.Flags())
+	cfg.contextFlags.Register(cmd.Flags())
+
+	return cmd
+}
+
+// result is the output of the build command.
+type result struct {
+	Slug     string `json:"slug" yaml:"slug"`
+	ImageURL string `json:"imageURL" yaml:"imageURL"`
+	BuildID  string `json:"buildID,omitempty" yaml:"buildID,omitempty"`
+}
+
+func run(ctx context.Context, cfg config) error {
+	var client = cfg.root.Client
+
+	var def definitions.Definition
+	var task api.Task
+	var root string
+	var shim bool
+	if ext := filepath.Ext(cfg.file); ext == ".yml" || ext == ".yaml" {
+		dir, d, err := readDefinition(cfg)
+		if err != nil {
+			return err
+		}
+		defer dir.Close()
+		def, root = d, dir.DefinitionRootPath()
+
+		task, err = client.GetTask(ctx, def.Slug)
+		if _, ok := err.(*api.TaskMissingError); ok {
+			return errors.Errorf("task %s does not exist yet, deploy it first: airplane deploy %s", def.Slug, cfg.file)
+		} else if err != nil {
+			return errors.Wrap(err, "getting task")
+		}
+	} else {
+		var err error
+		def, task, root, err = deploy.ScriptDefinition(ctx, client, cfg.file)
+		if err != nil {
+			return err
+		}
+		shim = true
+	}
+
+	kind, _, err := def.GetKindAndOptions()
+	if err != nil {
+		return err
+	}
+	if ok, err := build.NeedsBuilding(kind); err != nil {
+		return err
+	} else if !ok {
+		return errors.Errorf("task %s runs a pre-built image and doesn't need to be built", def.Slug)
+	}
+
+	var platforms []string
+	if cfg.platform != "" {
+		if platforms, err = build.ParsePlatforms(cfg.platform); err != nil {
+			return err
+		}
+	} else if len(def.Platforms) > 0 {
+		if platforms, err = build.ParsePlatforms(strings.Join(def.Platforms, ",")); err != nil {
+			return err
+		}
+	}
+
+	sbomOptions, err := cfg.sbom.Options()
+	if err != nil {
+		return err
+	}
+
+	resp, err := build.Run(ctx, build.Request{
+		Local:     cfg.local,
+		Client:    client,
+		Root:      root,
+		Def:       def,
+		TaskID:    task.ID,
+		TaskEnv:   def.Env,
+		Shim:      shim,
+		Platforms: platforms,
+		Tag:       cfg.tag,
+		SkipPush:  !cfg.push,
+		ReadOnly:  true,
+
+		DockerfileOutput: cfg.outputDockerfile,
+		ContextOutput:    cfg.outputContext,
+		SBOM:             sbomOptions,
+		Context:          cfg.contextOpts,
+		Timeout:          cfg.buildTimeout,
+	})
+	if err != nil {
+		return err
+	}
+
+	r := result{
+		Slug:     def.Slug,
+		ImageURL: resp.ImageURL,
+		BuildID:  resp.BuildID,
+	}
+	print.Print(r, func() {
+		logger.Log("")
+		logger.Log("Built %s", logger.Bold(r.Slug))
+		logger.Log("  image: %s", r.ImageURL)
+		if r.BuildID != "" {
+			logger.Log("  build: %s", r.BuildID)
+		}
+		if cfg.outputDockerfile != "" {
+			logger.Log("  dockerfile: %s", cfg.outputDockerfile)
+		}
+		if cfg.outputContext != "" {
+			logger.Log("  context: %s", cfg.outputContext)
+		}
+	})
+	return nil
+}
+
+// readDefinition reads the task definition to build. The caller is
+// responsible for closing the returned directory.
+func readDefinition(cfg config) (dir taskdir.TaskDirectory, def definitions.Definition, rerr error) {
+	vars, err := taskdir.ParseVars(cfg.vars)
+	if err != nil {
+		return taskdir.TaskDirectory{}, definitions.Definition{}, err
+	}
+
+	dir, err = taskdir.OpenWithVars(cfg.file, vars)
+	if err != nil {
+		return taskdir.TaskDirectory{}, definitions.Definition{}, err
+	}
+	defer func() {
+		if rerr != nil {
+			dir.Close()
+		}
+	}()
+
+	defs, err := dir.ReadDefinitions()
+	if err != nil {
+		return taskdir.TaskDirectory{}, definitions.Definition{}, err
+	}
+
+	var selected *definitions.Definition
+	switch {
+	case cfg.slug != "":
+		for i := range defs {
+			if defs[i].Slug == cfg.slug {
+				selected = &defs[i]
+			}
+		}
+		if selected == nil {
+			return taskdir.TaskDirectory{}, definitions.Definition{}, errors.Errorf("%s does not define task %s", cfg.file, cfg.slug)
+		}
+	case len(defs) == 1:
+		selected = &defs[0]
+	default:
+		return taskdir.TaskDirectory{}, definitions.Definition{}, errors.Errorf("%s defines %d tasks, select one with --slug", cfg.file, len(defs))
+	}
+
+	def, err = selected.Validate()
+	if err != nil {
+		return taskdir.TaskDirectory{}, definitions.Definition{}, err
+	}
+	return dir, def, nil
+}
@@ -0,0 +1,52 @@
+package deploy
+
+import (
+	"github.com/airplanedev/cli/pkg/build"
+	"github.com/dustin/go-humanize"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+// ContextFlags are the flags that check the build context of remote
+// builds before it's uploaded.
+type ContextFlags struct {
+	warnSize string
+	maxSize  string
+	explain  bool
+}
+
+// Register registers the flags on fs.
+func (f *ContextFlags) Register(fs *pflag.FlagSet) {
+	fs.StringVar(&f.warnSize, "context-warn-size", humanize.Bytes(build.DefaultContextWarnSize), "Warn and list the largest files if the build context is larger than this, f.e. 100MB. 0 disables the warning.")
+	fs.StringVar(&f.maxSize, "context-max-size", humanize.Bytes(build.DefaultContextMaxSize), "Fail before uploading if the build context is larger than this, f.e. 1GB. 0 disables the limit.")
+	fs.BoolVar(&f.explain, "explain-context", false, "Print whether each file is included in the build context or excluded by ignore rules.")
+}
+
+// Options returns the build options of the flags.
+//
+// The build context is only checked by remote builds, so the flags can't be
+// changed when building locally.
+func (f ContextFlags) Options(fs *pflag.FlagSet, local bool) (build.ContextOptions, error) {
+	if local {
+		for _, name := range []string{"context-warn-size", "context-max-size", "explain-context"} {
+			if fs.Changed(name) {
+				return build.ContextOptions{}, errors.Errorf("--%s is only supported by remote builds, build without --local", name)
+			}
+		}
+		return build.ContextOptions{}, nil
+	}
+
+	warnSize, err := humanize.ParseBytes(f.warnSize)
+	if err != nil {
+		return build.ContextOptions{}, errors.Wrap(err, "parsing --context-warn-size")
+	}
+	maxSize, err := humanize.ParseBytes(f.maxSize)
+	if err != nil {
+		return build.ContextOptions{}, errors.Wrap(err, "parsing --context-max-size")
+	}
+	return build.ContextOptions{
+		WarnSize: warnSize,
+		MaxSize:  maxSize,
+		Explain:  f.explain,
+	}, nil
+}
@@ -3,12 +3,16 @@ package deploy
 import (
 	"context"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/MakeNowJust/heredoc"
 	"github.com/airplanedev/cli/pkg/api"
+	"github.com/airplanedev/cli/pkg/build"
 	"github.com/airplanedev/cli/pkg/cli"
 	"github.com/airplanedev/cli/pkg/cmd/auth/login"
 	"github.com/airplanedev/cli/pkg/logger"
+	"github.com/airplanedev/cli/pkg/taskdir/definitions"
 	"github.com/airplanedev/cli/pkg/utils"
 	"github.com/airplanedev/cli/pkg/version"
 	"github.com/pkg/errors"
@@ -20,6 +24,17 @@ type config struct {
 	client *api.Client
 	file   string
 	local  bool
+	vars   []string
+	env    string
+
+	cacheFrom    []string
+	cacheTo      string
+	platform     string
+	buildTimeout time.Duration
+	sbom         SBOMFlags
+
+	contextFlags ContextFlags
+	contextOpts  build.ContextOptions
 }
 
 func New(c *cli.Config) *cobra.Command {
@@ -36,6 +51,13 @@ func New(c *cli.Config) *cobra.Command {
 			airplane tasks deploy ./task.ts
 			airplane tasks deploy --local ./task.js
 			airplane tasks deploy ./my-task.yml
+			airplane tasks deploy ./my-task.yml --var env=staging
+			airplane tasks deploy ./my-task.yml --env staging
+			airplane tasks deploy --local ./task.ts --cache-from $REPO/cache --cache-to $REPO/cache
+			airplane tasks deploy --local ./task.ts --platform linux/amd64,linux/arm64
+			airplane tasks deploy ./my-task.yml --sbom sbom.json --vuln-db vulns.json --fail-on critical
+			airplane tasks deploy ./my-task.yml --explain-context --context-max-size 1GB
+			airplane tasks deploy ./my-task.yml --build-timeout 30m
 		`),
 		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -47,6 +69,16 @@ func New(c *cli.Config) *cobra.Command {
 			} else {
 				return errors.New("expected 1 argument: airplane deploy ./path/to/file")
 			}
+			if !cfg.local && (len(cfg.cacheFrom) > 0 || cfg.cacheTo != "") {
+				return errors.New("--cache-from and --cache-to require --local")
+			}
+			if _, err := cfg.sbom.Options(); err != nil {
+				return err
+			}
+			var err error
+			if cfg.contextOpts, err = cfg.contextFlags.Options(cmd.Flags(), cfg.local); err != nil {
+				return err
+			}
 			return run(cmd.Root().Context(), cfg)
 		},
 		PersistentPreRunE: utils.WithParentPersistentPreRunE(func(cmd *cobra.Command, args []string) error {
@@ -57,6 +89,14 @@ func New(c *cli.Config) *cobra.Command {
 	cmd.Flags().BoolVarP(&cfg.local, "local", "L", false, "use a local Docker daemon (instead of an Airplane-hosted builder)")
 	cmd.Flags().StringVarP(&cfg.file, "file", "f", "", "File to deploy (.yaml, .yml, .js, .ts)")
 	cli.Must(cmd.Flags().MarkHidden("file")) // --file is deprecated
+	cmd.Flags().StringArrayVar(&cfg.vars, "var", nil, "Set a task definition variable (key=value), only for .yml and .yaml files. Can be repeated.")
+	cmd.Flags().StringArrayVar(&cfg.cacheFrom, "cache-from", nil, "Image to import the build cache from, requires --local. Can be repeated.")
+	cmd.Flags().StringVar(&cfg.cacheTo, "cache-to", "", "Image to export the build cache to, requires --local.")
+	cmd.Flags().StringVar(&cfg.platform, "platform", "", "Comma-separated platforms to build for, f.e. linux/arm64. Defaults to the platforms of the task definition or linux/amd64.")
+	cmd.Flags().StringVar(&cfg.env, "env", "", "Deploy the task to an environment declared in its definition, f.e. staging.")
+	cmd.Flags().DurationVar(&cfg.buildTimeout, "build-timeout", 0, "Cancel the build if it takes longer than this, f.e. 30m. Defaults to no timeout.")
+	cfg.sbom.Register(cmd.Flags())
+	cfg.contextFlags.Register(cmd.Flags())
 
 	return cmd
 }
@@ -83,5 +123,25 @@ func run(ctx context.Context, cfg config) error {
 		return deployFromYaml(ctx, cfg)
 	}
 
+	if cfg.env != "" {
+		return errors.New("--env is only supported when deploying task definition files (.yml, .yaml)")
+	}
+	if len(cfg.vars) > 0 {
+		return errors.New("--var is only supported when deploying task definition files (.yml, .yaml)")
+	}
+
 	return deployFromScript(ctx, cfg)
 }
+
+// platforms returns the platforms to build def for.
+//
+// The --platform flag takes precedence over the platforms of the definition.
+func platforms(cfg config, def definitions.Definition) ([]string, error) {
+	if cfg.platform != "" {
+		return build.ParsePlatforms(cfg.platform)
+	}
+	if len(def.Platforms) > 0 {
+		return build.ParsePlatforms(strings.Join(def.Platforms, ","))
+	}
+	return nil, nil
+}
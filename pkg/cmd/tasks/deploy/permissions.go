@@ -0,0 +1,87 @@
+package deploy
+
+import (
+	"context"
+
+	"github.com/airplanedev/cli/pkg/api"
+	"github.com/airplanedev/cli/pkg/taskdir/definitions"
+	"github.com/pkg/errors"
+)
+
+// permissionsClient lists the users and groups that permissions reference.
+type permissionsClient interface {
+	ListUsers(ctx context.Context) (api.ListUsersResponse, error)
+	ListGroups(ctx context.Context) (api.ListGroupsResponse, error)
+}
+
+// resolvePermissions converts permissions from a task definition, which reference
+// users by email and groups by name, into API permissions that reference IDs.
+//
+// Users and groups may be listed in several roles, but every action is only
+// granted to them once.
+func resolvePermissions(ctx context.Context, client permissionsClient, def definitions.PermissionsDefinition) (api.Permissions, error) {
+	usersResp, err := client.ListUsers(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "fetching users")
+	}
+	userIDsByEmail := map[string]string{}
+	for _, user := range usersResp.Users {
+		userIDsByEmail[user.Email] = user.ID
+	}
+
+	groupsResp, err := client.ListGroups(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "fetching groups")
+	}
+	groupIDsByName := map[string]string{}
+	for _, group := range groupsResp.Groups {
+		groupIDsByName[group.Name] = group.ID
+	}
+
+	type grant struct {
+		action  api.Action
+		userID  string
+		groupID string
+	}
+	granted := map[grant]bool{}
+
+	permissions := api.Permissions{}
+	for _, role := range def.Roles() {
+		for _, email := range role.Recipients.Users {
+			id, ok := userIDsByEmail[email]
+			if !ok {
+				return nil, errors.Errorf("unknown user: %s", email)
+			}
+			for _, action := range role.Actions {
+				g := grant{action: action, userID: id}
+				if granted[g] {
+					continue
+				}
+				granted[g] = true
+				permissions = append(permissions, api.Permission{
+					Action:    action,
+					SubUserID: &id,
+				})
+			}
+		}
+		for _, name := range role.Recipients.Groups {
+			id, ok := groupIDsByName[name]
+			if !ok {
+				return nil, errors.Errorf("unknown group: %s", name)
+			}
+			for _, action := range role.Actions {
+				g := grant{action: action, groupID: id}
+				if granted[g] {
+					continue
+				}
+				granted[g] = true
+				permissions = append(permissions, api.Permission{
+					Action:     action,
+					SubGroupID: &id,
+				})
+			}
+		}
+	}
+
+	return permissions, nil
+}
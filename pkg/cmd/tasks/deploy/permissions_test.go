@@ -0,0 +1,111 @@
+package deploy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/airplanedev/cli/pkg/api"
+	"github.com/airplanedev/cli/pkg/taskdir/definitions"
+	"github.com/stretchr/testify/require"
+)
+
+type fakePermissionsClient struct{}
+
+func (fakePermissionsClient) ListUsers(ctx context.Context) (api.ListUsersResponse, error) {
+	return api.ListUsersResponse{Users: []api.User{
+		{ID: "usr1", Email: "ada@airplane.dev"},
+		{ID: "usr2", Email: "grace@airplane.dev"},
+	}}, nil
+}
+
+func (fakePermissionsClient) ListGroups(ctx context.Context) (api.ListGroupsResponse, error) {
+	return api.ListGroupsResponse{Groups: []api.Group{
+		{ID: "grp1", Name: "eng"},
+	}}, nil
+}
+
+func userPermission(action api.Action, id string) api.Permission {
+	return api.Permission{Action: action, SubUserID: &id}
+}
+
+func groupPermission(action api.Action, id string) api.Permission {
+	return api.Permission{Action: action, SubGroupID: &id}
+}
+
+func TestResolvePermissions(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		def         definitions.PermissionsDefinition
+		permissions api.Permissions
+		err         string
+	}{
+		{
+			name:        "empty",
+			permissions: api.Permissions{},
+		},
+		{
+			name: "viewers and executers",
+			def: definitions.PermissionsDefinition{
+				Viewers:   definitions.PermissionRecipients{Groups: []string{"eng"}},
+				Executers: definitions.PermissionRecipients{Users: []string{"ada@airplane.dev"}},
+			},
+			permissions: api.Permissions{
+				groupPermission(api.ActionTasksGet, "grp1"),
+				groupPermission(api.ActionRunsGet, "grp1"),
+				userPermission(api.ActionTasksGet, "usr1"),
+				userPermission(api.ActionRunsGet, "usr1"),
+				userPermission(api.ActionTriggerRequestsCreate, "usr1"),
+				userPermission(api.ActionTasksExecute, "usr1"),
+				userPermission(api.ActionRunsCancel, "usr1"),
+			},
+		},
+		{
+			name: "recipients in several roles",
+			def: definitions.PermissionsDefinition{
+				Viewers:    definitions.PermissionRecipients{Users: []string{"ada@airplane.dev"}, Groups: []string{"eng"}},
+				Requesters: definitions.PermissionRecipients{Users: []string{"ada@airplane.dev", "grace@airplane.dev"}},
+				Admins:     definitions.PermissionRecipients{Groups: []string{"eng"}},
+			},
+			permissions: api.Permissions{
+				userPermission(api.ActionTasksGet, "usr1"),
+				userPermission(api.ActionRunsGet, "usr1"),
+				groupPermission(api.ActionTasksGet, "grp1"),
+				groupPermission(api.ActionRunsGet, "grp1"),
+				userPermission(api.ActionTriggerRequestsCreate, "usr1"),
+				userPermission(api.ActionTasksGet, "usr2"),
+				userPermission(api.ActionRunsGet, "usr2"),
+				userPermission(api.ActionTriggerRequestsCreate, "usr2"),
+				groupPermission(api.ActionTriggerRequestsCreate, "grp1"),
+				groupPermission(api.ActionTasksExecute, "grp1"),
+				groupPermission(api.ActionRunsCancel, "grp1"),
+				groupPermission(api.ActionTasksUpdate, "grp1"),
+			},
+		},
+		{
+			name: "unknown user",
+			def: definitions.PermissionsDefinition{
+				Viewers: definitions.PermissionRecipients{Users: []string{"nobody@airplane.dev"}},
+			},
+			err: "unknown user: nobody@airplane.dev",
+		},
+		{
+			name: "unknown group",
+			def: definitions.PermissionsDefinition{
+				Admins: definitions.PermissionRecipients{Groups: []string{"ops"}},
+			},
+			err: "unknown group: ops",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+
+			permissions, err := resolvePermissions(context.Background(), fakePermissionsClient{}, test.def)
+			if test.err != "" {
+				require.EqualError(err, test.err)
+				return
+			}
+			require.NoError(err)
+			require.Equal(test.permissions, permissions)
+		})
+	}
+}
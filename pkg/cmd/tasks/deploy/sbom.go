@@ -0,0 +1,52 @@
+package deploy
+
+import (
+	"github.com/airplanedev/cli/pkg/build"
+	"github.com/airplanedev/cli/pkg/build/sbom"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+// SBOMFlags are the flags that generate a software bill of materials (SBOM)
+// of built images and check them for vulnerabilities.
+type SBOMFlags struct {
+	output string
+	format string
+	vulnDB string
+	failOn string
+}
+
+// Register registers the flags on fs.
+func (f *SBOMFlags) Register(fs *pflag.FlagSet) {
+	fs.StringVar(&f.output, "sbom", "", "Write an SBOM of the built image to this path, only supported for a single task.")
+	fs.StringVar(&f.format, "sbom-format", string(sbom.FormatSPDX), "Format of the SBOM, spdx or cyclonedx.")
+	fs.StringVar(&f.vulnDB, "vuln-db", "", "Check the SBOM against the vulnerability database at this path.")
+	fs.StringVar(&f.failOn, "fail-on", "", "Fail if the image has vulnerabilities of this severity or higher (low, medium, high or critical), requires --vuln-db.")
+}
+
+// Options returns the build options of the flags, or nil if no SBOM was
+// requested.
+func (f SBOMFlags) Options() (*build.SBOMOptions, error) {
+	if f.output == "" && f.vulnDB == "" && f.failOn == "" {
+		return nil, nil
+	}
+
+	format, err := sbom.ParseFormat(f.format)
+	if err != nil {
+		return nil, err
+	}
+	opts := &build.SBOMOptions{
+		Output: f.output,
+		Format: format,
+		VulnDB: f.vulnDB,
+	}
+	if f.failOn != "" {
+		if f.vulnDB == "" {
+			return nil, errors.New("--fail-on requires --vuln-db")
+		}
+		if opts.FailOn, err = sbom.ParseSeverity(f.failOn); err != nil {
+			return nil, err
+		}
+	}
+	return opts, nil
+}
@@ -38,17 +38,7 @@ func deployFromScript(ctx context.Context, cfg config) (rErr error) {
 		})
 	}()
 
-	code, err := ioutil.ReadFile(cfg.file)
-	if err != nil {
-		return errors.Wrapf(err, "reading %s", cfg.file)
-	}
-
-	slug, ok := runtime.Slug(code)
-	if !ok {
-		return runtime.ErrNotLinked{Path: cfg.file}
-	}
-
-	task, err := client.GetTask(ctx, slug)
+	def, task, taskroot, err := ScriptDefinition(ctx, client, cfg.file)
 	if err != nil {
 		return err
 	}
@@ -57,42 +47,16 @@ func deployFromScript(ctx context.Context, cfg config) (rErr error) {
 	tp.taskSlug = task.Slug
 	tp.taskName = task.Name
 
-	r, err := runtime.Lookup(task.Kind, cfg.file)
-	if err != nil {
-		return errors.Wrapf(err, "cannot determine how to deploy %q - check your CLI is up to date", cfg.file)
-	}
-
-	def, err := definitions.NewDefinitionFromTask(task)
-	if err != nil {
-		return err
-	}
-
-	abs, err := filepath.Abs(cfg.file)
-	if err != nil {
+	if def.Platforms, err = platforms(cfg, def); err != nil {
 		return err
 	}
 
-	// Detect the root of the task, if found ensure
-	// that the entrypoint and the root are included
-	// in the build.
-	taskroot, err := r.Root(abs)
-	if err != nil {
-		return err
-	}
-	entrypoint, err := filepath.Rel(taskroot, abs)
+	kind, kindOptions, err := def.GetKindAndOptions()
 	if err != nil {
 		return err
 	}
-	setEntrypoint(&def, entrypoint)
 
-	// TODO(amir): move to `d.SetWorkdir()`.
-	if def.Node != nil {
-		if wd, err := r.Workdir(abs); err == nil {
-			def.Node.Workdir = strings.TrimPrefix(wd, taskroot)
-		}
-	}
-
-	kind, kindOptions, err := def.GetKindAndOptions()
+	sbomOptions, err := cfg.sbom.Options()
 	if err != nil {
 		return err
 	}
@@ -106,6 +70,13 @@ func deployFromScript(ctx context.Context, cfg config) (rErr error) {
 		Def:     def,
 		TaskEnv: def.Env,
 		Shim:    true,
+
+		CacheFrom: cfg.cacheFrom,
+		CacheTo:   cfg.cacheTo,
+		Platforms: def.Platforms,
+		SBOM:      sbomOptions,
+		Context:   cfg.contextOpts,
+		Timeout:   cfg.buildTimeout,
 	})
 	if err != nil {
 		return err
@@ -120,7 +91,7 @@ func deployFromScript(ctx context.Context, cfg config) (rErr error) {
 		Command:                    []string{},
 		Arguments:                  def.Arguments,
 		Parameters:                 def.Parameters,
-		Constraints:                def.Constraints,
+		Constraints:                def.GetRunConstraints(),
 		Env:                        def.Env,
 		ResourceRequests:           def.ResourceRequests,
 		Resources:                  def.Resources,
@@ -150,6 +121,62 @@ func deployFromScript(ctx context.Context, cfg config) (rErr error) {
 	return nil
 }
 
+// ScriptDefinition returns the definition of the task that the script at
+// file is linked to, along with the task and the root to build it from.
+func ScriptDefinition(ctx context.Context, client *api.Client, file string) (definitions.Definition, api.Task, string, error) {
+	code, err := ioutil.ReadFile(file)
+	if err != nil {
+		return definitions.Definition{}, api.Task{}, "", errors.Wrapf(err, "reading %s", file)
+	}
+
+	slug, ok := runtime.Slug(code)
+	if !ok {
+		return definitions.Definition{}, api.Task{}, "", runtime.ErrNotLinked{Path: file}
+	}
+
+	task, err := client.GetTask(ctx, slug)
+	if err != nil {
+		return definitions.Definition{}, api.Task{}, "", err
+	}
+
+	r, err := runtime.Lookup(task.Kind, file)
+	if err != nil {
+		return definitions.Definition{}, api.Task{}, "", errors.Wrapf(err, "cannot determine how to deploy %q - check your CLI is up to date", file)
+	}
+
+	def, err := definitions.NewDefinitionFromTask(task)
+	if err != nil {
+		return definitions.Definition{}, api.Task{}, "", err
+	}
+
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return definitions.Definition{}, api.Task{}, "", err
+	}
+
+	// Detect the root of the task, if found ensure
+	// that the entrypoint and the root are included
+	// in the build.
+	taskroot, err := r.Root(abs)
+	if err != nil {
+		return definitions.Definition{}, api.Task{}, "", err
+	}
+	entrypoint, err := filepath.Rel(taskroot, abs)
+	if err != nil {
+		return definitions.Definition{}, api.Task{}, "", err
+	}
+	setEntrypoint(&def, entrypoint)
+
+	// TODO(amir): move to `d.SetWorkdir()`.
+	if def.Node != nil {
+		if wd, err := r.Workdir(abs); err == nil {
+			def.Node.Workdir = strings.TrimPrefix(wd, taskroot)
+		}
+	}
+
+	return def, task, taskroot, nil
+}
+
 // SetEntrypoint sets the entrypoint on d.
 //
 // TODO(amir): move this to `def.SetEntrypoint()` or whatever.
@@ -159,6 +186,8 @@ func setEntrypoint(d *definitions.Definition, ep string) {
 		d.Node.Entrypoint = ep
 	case api.TaskKindPython:
 		d.Python.Entrypoint = ep
+	case api.TaskKindRuby:
+		d.Ruby.Entrypoint = ep
 	case api.TaskKindShell:
 		d.Shell.Entrypoint = ep
 	default:
@@ -2,6 +2,7 @@ package deploy
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -10,11 +11,82 @@ import (
 	"github.com/airplanedev/cli/pkg/build"
 	"github.com/airplanedev/cli/pkg/logger"
 	"github.com/airplanedev/cli/pkg/taskdir"
+	"github.com/airplanedev/cli/pkg/taskdir/definitions"
 	"github.com/pkg/errors"
 )
 
 // DeployFromYaml deploys from a yaml file.
-func deployFromYaml(ctx context.Context, cfg config) (rErr error) {
+//
+// A yaml file can define several tasks that share a root. Tasks whose kind,
+// options and env match are only built once and share the resulting image.
+func deployFromYaml(ctx context.Context, cfg config) error {
+	vars, err := taskdir.ParseVars(cfg.vars)
+	if err != nil {
+		return err
+	}
+
+	dir, err := taskdir.OpenWithVars(cfg.file, vars)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	defs, err := dir.ReadDefinitions()
+	if err != nil {
+		return err
+	}
+	if cfg.env != "" {
+		for i := range defs {
+			if defs[i], err = defs[i].ForEnvironment(cfg.env); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Every build would write its SBOM to the same path, and tasks that
+	// reuse an image don't build one:
+	if len(defs) > 1 && cfg.sbom.output != "" {
+		return errors.Errorf("--sbom is not supported for files with multiple tasks, %s defines %d", cfg.file, len(defs))
+	}
+
+	images := map[string]*build.Response{}
+	for _, def := range defs {
+		if len(defs) > 1 {
+			logger.Log("Deploying %s...", logger.Bold(def.Slug))
+		}
+		if err := deployDefinition(ctx, cfg, dir, def, images); err != nil {
+			return err
+		}
+	}
+
+	if len(defs) > 1 {
+		logger.Suggest(
+			"⚡ To execute a task from the CLI:",
+			"airplane exec <slug>",
+		)
+		return nil
+	}
+
+	def := defs[0]
+	// Leave off `-- [parameters]` for simplicity - user will get prompted.
+	cmd := fmt.Sprintf("airplane exec %s", def.Slug)
+	logger.Suggest(
+		"⚡ To execute the task from the CLI:",
+		cmd,
+	)
+
+	logger.Suggest(
+		"⚡ To execute the task from the UI:",
+		cfg.client.TaskURL(def.Slug),
+	)
+	return nil
+}
+
+// deployDefinition deploys a single task definition from dir.
+//
+// Images is keyed by buildKey and is used to reuse images across
+// tasks with the same build configuration.
+func deployDefinition(ctx context.Context, cfg config, dir taskdir.TaskDirectory, def definitions.Definition, images map[string]*build.Response) (rErr error) {
 	client := cfg.client
 	props := taskDeployedProps{
 		from: "yaml",
@@ -33,22 +105,15 @@ func deployFromYaml(ctx context.Context, cfg config) (rErr error) {
 		})
 	}()
 
-	dir, err := taskdir.Open(cfg.file)
-	if err != nil {
-		return err
-	}
-	defer dir.Close()
-
-	def, err := dir.ReadDefinition()
+	def, err := def.Validate()
 	if err != nil {
 		return err
 	}
+	props.taskSlug = def.Slug
 
-	def, err = def.Validate()
-	if err != nil {
+	if def.Platforms, err = platforms(cfg, def); err != nil {
 		return err
 	}
-	props.taskSlug = def.Slug
 
 	err = ensureConfigsExist(ctx, client, def)
 	if err != nil {
@@ -79,6 +144,14 @@ func deployFromYaml(ctx context.Context, cfg config) (rErr error) {
 		}
 	}
 
+	var permissions api.Permissions
+	if def.Permissions != nil {
+		permissions, err = resolvePermissions(ctx, client, *def.Permissions)
+		if err != nil {
+			return err
+		}
+	}
+
 	var image *string
 	var command []string
 	if def.Image != nil {
@@ -98,7 +171,7 @@ func deployFromYaml(ctx context.Context, cfg config) (rErr error) {
 			Command:          command,
 			Arguments:        def.Arguments,
 			Parameters:       def.Parameters,
-			Constraints:      def.Constraints,
+			Constraints:      def.GetRunConstraints(),
 			Env:              def.Env,
 			ResourceRequests: def.ResourceRequests,
 			Resources:        resources,
@@ -121,21 +194,58 @@ func deployFromYaml(ctx context.Context, cfg config) (rErr error) {
 	props.taskID = task.ID
 	props.taskName = task.Name
 
+	// Keep the task's existing permissions unless the definition configures them.
+	requireExplicitPermissions := task.RequireExplicitPermissions
+	if def.Permissions != nil {
+		requireExplicitPermissions = def.Permissions.RequireExplicitPermissions
+	} else {
+		permissions = task.Permissions
+	}
+
 	if ok, err := build.NeedsBuilding(kind); err != nil {
 		return err
 	} else if ok {
-		resp, err := build.Run(ctx, build.Request{
-			Local:  cfg.local,
-			Client: client,
-			Root:   dir.DefinitionRootPath(),
-			Def:    def,
-			TaskID: task.ID,
-		})
-		props.buildLocal = cfg.local
-		props.buildID = resp.BuildID
+		key, err := buildKey(def)
 		if err != nil {
 			return err
 		}
+		resp, ok := images[key]
+		if ok {
+			logger.Log("Reusing image %s", logger.Gray(resp.ImageURL))
+		} else {
+			sbomOptions, err := cfg.sbom.Options()
+			if err != nil {
+				return err
+			}
+			resp, err = build.Run(ctx, build.Request{
+				Local:  cfg.local,
+				Client: client,
+				Root:   dir.DefinitionRootPath(),
+				Def:    def,
+				TaskID: task.ID,
+
+				CacheFrom: cfg.cacheFrom,
+				CacheTo:   cfg.cacheTo,
+				Platforms: def.Platforms,
+				SBOM:      sbomOptions,
+				Context:   cfg.contextOpts,
+				Timeout:   cfg.buildTimeout,
+			})
+			props.buildLocal = cfg.local
+			if err != nil {
+				return err
+			}
+			// Only reuse images that later deploys can't change, f.e.
+			// not the "latest" tag of this task's repository.
+			if pinned := resp.PinnedImageURL(); pinned != "" {
+				images[key] = &build.Response{
+					ImageURL: pinned,
+					BuildID:  resp.BuildID,
+					Digest:   resp.Digest,
+				}
+			}
+		}
+		props.buildID = resp.BuildID
 		image = &resp.ImageURL
 	}
 
@@ -147,31 +257,40 @@ func deployFromYaml(ctx context.Context, cfg config) (rErr error) {
 		Command:                    command,
 		Arguments:                  def.Arguments,
 		Parameters:                 def.Parameters,
-		Constraints:                def.Constraints,
+		Constraints:                def.GetRunConstraints(),
 		Env:                        def.Env,
 		ResourceRequests:           def.ResourceRequests,
 		Resources:                  resources,
 		Kind:                       kind,
 		KindOptions:                kindOptions,
 		Repo:                       def.Repo,
-		RequireExplicitPermissions: task.RequireExplicitPermissions,
-		Permissions:                task.Permissions,
+		RequireExplicitPermissions: requireExplicitPermissions,
+		Permissions:                permissions,
 		Timeout:                    def.Timeout,
 	})
 	if err != nil {
 		return errors.Wrapf(err, "updating task %s", def.Slug)
 	}
 
-	// Leave off `-- [parameters]` for simplicity - user will get prompted.
-	cmd := fmt.Sprintf("airplane exec %s", def.Slug)
-	logger.Suggest(
-		"⚡ To execute the task from the CLI:",
-		cmd,
-	)
-
-	logger.Suggest(
-		"⚡ To execute the task from the UI:",
-		client.TaskURL(def.Slug),
-	)
 	return nil
 }
+
+// buildKey returns a key that identifies the build configuration of def.
+//
+// Tasks in the same directory with equal keys produce the same image.
+func buildKey(def definitions.Definition) (string, error) {
+	kind, kindOptions, err := def.GetKindAndOptions()
+	if err != nil {
+		return "", err
+	}
+	buf, err := json.Marshal(struct {
+		Kind        api.TaskKind
+		KindOptions api.KindOptions
+		Env         api.TaskEnv
+		Platforms   []string
+	}{kind, kindOptions, def.Env, def.Platforms})
+	if err != nil {
+		return "", errors.Wrap(err, "marshalling build key")
+	}
+	return string(buf), nil
+}
@@ -31,9 +31,10 @@ import (
 )
 
 type config struct {
-	root *cli.Config
-	file string
-	args []string
+	root   *cli.Config
+	file   string
+	args   []string
+	bundle bool
 }
 
 func New(c *cli.Config) *cobra.Command {
@@ -46,6 +47,7 @@ func New(c *cli.Config) *cobra.Command {
 		Example: heredoc.Doc(`
 			airplane dev ./task.js [-- <parameters...>]
 			airplane dev ./task.ts [-- <parameters...>]
+			airplane dev --bundle ./task.ts [-- <parameters...>]
 		`),
 		PersistentPreRunE: utils.WithParentPersistentPreRunE(func(cmd *cobra.Command, args []string) error {
 			// TODO: update the `dev` command to work w/out internet access
@@ -62,6 +64,8 @@ func New(c *cli.Config) *cobra.Command {
 		},
 	}
 
+	cmd.Flags().BoolVar(&cfg.bundle, "bundle", false, "Bundle a Node task with esbuild before running it, f.e. for faster startup.")
+
 	return cmd
 }
 
@@ -100,10 +104,22 @@ func run(ctx context.Context, cfg config) error {
 		return errors.Wrapf(err, "absolute path of %s", cfg.file)
 	}
 
+	kindOptions := task.KindOptions
+	if cfg.bundle {
+		if task.Kind != api.TaskKindNode {
+			return errors.Errorf("--bundle is only supported for Node tasks, %s is a %s task", task.Slug, task.Kind)
+		}
+		kindOptions = api.KindOptions{}
+		for k, v := range task.KindOptions {
+			kindOptions[k] = v
+		}
+		kindOptions["bundle"] = true
+	}
+
 	cmds, err := r.PrepareRun(ctx, runtime.PrepareRunOptions{
 		Path:        path,
 		ParamValues: paramValues,
-		KindOptions: task.KindOptions,
+		KindOptions: kindOptions,
 	})
 	if err != nil {
 		return err
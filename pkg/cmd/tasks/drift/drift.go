@@ -0,0 +1,537 @@
+package drift
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/airplanedev/cli/pkg/api"
+	"github.com/airplanedev/cli/pkg/cli"
+	"github.com/airplanedev/cli/pkg/logger"
+	"github.com/airplanedev/cli/pkg/print"
+	"github.com/airplanedev/cli/pkg/taskdir"
+	"github.com/airplanedev/cli/pkg/taskdir/definitions"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the drift config.
+type config struct {
+	root   *cli.Config
+	client client
+	path   string
+	fix    string
+	vars   []string
+	env    string
+}
+
+// client is the subset of the API client that is used to compare tasks.
+type client interface {
+	GetTask(ctx context.Context, slug string) (api.Task, error)
+	ListResources(ctx context.Context) (api.ListResourcesResponse, error)
+	ListUsers(ctx context.Context) (api.ListUsersResponse, error)
+	ListGroups(ctx context.Context) (api.ListGroupsResponse, error)
+}
+
+// names maps the IDs of resources, users and groups to the names that task
+// definitions reference them by.
+type names struct {
+	resources map[string]string
+	users     map[string]string
+	groups    map[string]string
+}
+
+// listNames lists the names of all resources, users and groups.
+func listNames(ctx context.Context, client client) (names, error) {
+	n := names{
+		resources: map[string]string{},
+		users:     map[string]string{},
+		groups:    map[string]string{},
+	}
+
+	resources, err := client.ListResources(ctx)
+	if err != nil {
+		return names{}, errors.Wrap(err, "fetching resources")
+	}
+	for _, resource := range resources.Resources {
+		n.resources[resource.ID] = resource.Name
+	}
+
+	users, err := client.ListUsers(ctx)
+	if err != nil {
+		return names{}, errors.Wrap(err, "fetching users")
+	}
+	for _, user := range users.Users {
+		n.users[user.ID] = user.Email
+	}
+
+	groups, err := client.ListGroups(ctx)
+	if err != nil {
+		return names{}, errors.Wrap(err, "fetching groups")
+	}
+	for _, group := range groups.Groups {
+		n.groups[group.ID] = group.Name
+	}
+
+	return n, nil
+}
+
+// New returns a new drift command.
+func New(c *cli.Config) *cobra.Command {
+	var cfg = config{root: c, client: c.Client}
+
+	cmd := &cobra.Command{
+		Use:   "drift [path]",
+		Short: "Detect drift between task definitions and deployed tasks",
+		Long: heredoc.Doc(`
+			Compares every task definition found under path with the task that is
+			deployed to Airplane and reports any fields that differ.
+
+			Definitions with environments are compared with the task of each
+			environment, or only with the one of --env if it's set. Their base
+			task is only compared if it's deployed.
+
+			Exits with a status code of 2 if any drift was detected, which makes it
+			suitable as a scheduled CI check. Task definitions that can't be read
+			or are invalid are reported as well and fail the check.
+		`),
+		Example: heredoc.Doc(`
+			airplane tasks drift
+			airplane tasks drift ./tasks -o json
+			airplane tasks drift ./tasks --var env=staging
+			airplane tasks drift ./tasks --env staging
+			airplane tasks drift ./tasks/my_task.yml --fix=local
+		`),
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg.path = "."
+			if len(args) > 0 {
+				cfg.path = args[0]
+			}
+			if cfg.fix != "" && cfg.fix != "local" {
+				return errors.Errorf("unsupported --fix value %q: expected local", cfg.fix)
+			}
+			return run(cmd.Root().Context(), cfg)
+		},
+	}
+
+	cmd.Flags().StringVar(&cfg.fix, "fix", "", "Resolve drift by pulling remote changes into local definitions (local).")
+	cmd.Flags().StringArrayVar(&cfg.vars, "var", nil, "Set a task definition variable (key=value). Can be repeated.")
+	cmd.Flags().StringVar(&cfg.env, "env", "", "Compare the tasks of an environment declared in task definitions, f.e. staging.")
+
+	return cmd
+}
+
+// taskDrift represents a single drifted field of a task.
+type taskDrift struct {
+	File   string      `json:"file" yaml:"file"`
+	Slug   string      `json:"slug" yaml:"slug"`
+	Field  string      `json:"field" yaml:"field"`
+	Local  interface{} `json:"local" yaml:"local"`
+	Remote interface{} `json:"remote" yaml:"remote"`
+}
+
+// errDrift is returned when drift was detected.
+type errDrift struct {
+	tasks int
+}
+
+func (err errDrift) Error() string {
+	return fmt.Sprintf("drift detected in %d task(s)", err.tasks)
+}
+
+// ExitCode implementation.
+func (err errDrift) ExitCode() int {
+	return 2
+}
+
+// Run runs the drift command.
+func run(ctx context.Context, cfg config) error {
+	var client = cfg.client
+
+	vars, err := taskdir.ParseVars(cfg.vars)
+	if err != nil {
+		return err
+	}
+
+	files, err := findDefinitions(cfg.path)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return errors.Errorf("no task definitions found in %s", cfg.path)
+	}
+
+	names, err := listNames(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	var rows []taskDrift
+	var drifted, invalid int
+	for _, file := range files {
+		dir, targets, err := openDefinitions(file, vars, cfg.env)
+		if err != nil {
+			logger.Warning("Unable to check %s: %s", file, err)
+			invalid++
+			continue
+		}
+		if targets == nil {
+			logger.Debug("skipping %s: not a task definition", file)
+			continue
+		}
+		multi := targets[len(targets)-1].index > 0
+
+		for _, t := range targets {
+			def := t.def
+			drift, remote, err := compare(ctx, client, def, names)
+			if err != nil {
+				dir.Close()
+				return errors.Wrapf(err, "comparing %s", file)
+			}
+			if remote == nil && t.optional {
+				continue
+			}
+			for _, d := range drift {
+				rows = append(rows, taskDrift{
+					File:   file,
+					Slug:   def.Slug,
+					Field:  d.Field,
+					Local:  d.Local,
+					Remote: d.Remote,
+				})
+			}
+			if len(drift) == 0 {
+				continue
+			}
+
+			// Drift that isn't fixed, f.e. because the task doesn't exist
+			// yet, still fails the check.
+			if cfg.fix == "local" && remote != nil {
+				if multi {
+					logger.Warning("--fix=local does not support files with multiple tasks yet, skipping %s", file)
+				} else if err := fixLocal(dir, t, *remote, drift); err != nil {
+					logger.Warning("Unable to update %s from %s: %s", file, def.Slug, err)
+				} else {
+					logger.Step("Updated %s from %s", file, def.Slug)
+					continue
+				}
+			}
+			drifted++
+		}
+		dir.Close()
+	}
+
+	print.Print(rows, func() {
+		if len(rows) == 0 {
+			logger.Log("No drift detected in %d task definition(s).", len(files)-invalid)
+			return
+		}
+		tw := tablewriter.NewWriter(os.Stdout)
+		tw.SetBorder(false)
+		tw.SetAutoWrapText(false)
+		tw.SetHeader([]string{"file", "slug", "field", "local", "remote"})
+		for _, r := range rows {
+			tw.Append([]string{r.File, r.Slug, r.Field, format(r.Local), format(r.Remote)})
+		}
+		tw.Render()
+	})
+
+	if invalid > 0 {
+		return errors.Errorf("unable to check %d task definition(s)", invalid)
+	}
+	if drifted > 0 {
+		return errDrift{tasks: drifted}
+	}
+	return nil
+}
+
+// findDefinitions returns the paths of all YAML files under path.
+func findDefinitions(path string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			name := info.Name()
+			if p != path && (strings.HasPrefix(name, ".") || name == "node_modules") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ext := filepath.Ext(p); ext == ".yml" || ext == ".yaml" {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "walking %s", path)
+	}
+	return files, nil
+}
+
+// definitionKeys are top-level keys that identify a YAML file as a task
+// definition.
+var definitionKeys = []string{
+	"slug", "tasks", "extends",
+	"deno", "dockerfile", "go", "image", "java", "node", "python", "ruby", "shell", "sql", "rest",
+}
+
+// isDefinition reports whether the YAML file at path has any task definition
+// keys. Files that aren't valid YAML are assumed to be definitions, since that
+// can't be ruled out.
+func isDefinition(path string) (bool, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, errors.Wrapf(err, "reading %s", path)
+	}
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(buf, &doc); err != nil {
+		var node yaml.Node
+		if yaml.Unmarshal(buf, &node) == nil {
+			// Valid YAML, but not a map.
+			return false, nil
+		}
+		return true, nil
+	}
+	for _, key := range definitionKeys {
+		if _, ok := doc[key]; ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// target is a task definition that is compared with a deployed task.
+type target struct {
+	def definitions.Definition
+	// index is the index of the definition in its file.
+	index int
+	// env is the environment that def was rendered for, if any.
+	env string
+	// optional is set if the task doesn't have to be deployed, f.e. the
+	// base task of a definition that is deployed to environments.
+	optional bool
+}
+
+// openDefinitions reads and validates the task definitions in file, rendered
+// with vars, and returns the tasks that they are compared with.
+//
+// Definitions with environments are rendered for env if it's set. Otherwise
+// they are rendered for each of their environments, and the base definition
+// is only compared if it's deployed too.
+//
+// If file is not a task definition, f.e. a CI workflow, targets is nil. Any
+// other problem with file is returned as an error.
+func openDefinitions(file string, vars map[string]string, env string) (dir taskdir.TaskDirectory, targets []target, err error) {
+	if ok, err := isDefinition(file); err != nil || !ok {
+		return taskdir.TaskDirectory{}, nil, err
+	}
+
+	dir, err = taskdir.OpenWithVars(file, vars)
+	if err != nil {
+		return taskdir.TaskDirectory{}, nil, err
+	}
+	defs, err := dir.ReadDefinitions()
+	for i := 0; err == nil && i < len(defs); i++ {
+		var ts []target
+		if ts, err = environmentTargets(defs[i], env); err != nil {
+			break
+		}
+		for _, t := range ts {
+			t.index = i
+			if t.def, err = t.def.Validate(); err != nil {
+				break
+			}
+			targets = append(targets, t)
+		}
+	}
+	if err != nil {
+		dir.Close()
+		return taskdir.TaskDirectory{}, nil, err
+	}
+	return dir, targets, nil
+}
+
+// environmentTargets returns the tasks that def is deployed as, see
+// openDefinitions.
+func environmentTargets(def definitions.Definition, env string) ([]target, error) {
+	if len(def.Environments) == 0 {
+		return []target{{def: def}}, nil
+	}
+
+	envs := []string{env}
+	if env == "" {
+		envs = nil
+		for name := range def.Environments {
+			envs = append(envs, name)
+		}
+		sort.Strings(envs)
+	}
+
+	var targets []target
+	if env == "" {
+		targets = append(targets, target{def: def, optional: true})
+	}
+	for _, name := range envs {
+		envDef, err := def.ForEnvironment(name)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target{def: envDef, env: name})
+	}
+	return targets, nil
+}
+
+// compare compares def with its deployed task.
+//
+// If the task does not exist yet, the returned definition is nil.
+func compare(ctx context.Context, client client, def definitions.Definition, names names) ([]definitions.Drift, *definitions.Definition, error) {
+	task, err := client.GetTask(ctx, def.Slug)
+	if _, ok := err.(*api.TaskMissingError); ok {
+		return []definitions.Drift{{Field: "task", Local: def.Slug}}, nil, nil
+	} else if err != nil {
+		return nil, nil, errors.Wrapf(err, "getting task %s", def.Slug)
+	}
+
+	remote, err := definitions.NewDefinitionFromTask(task)
+	if err != nil {
+		return nil, nil, err
+	}
+	remote.Resources = api.Resources{}
+	for ref, id := range task.Resources {
+		remote.Resources[ref] = lookupName(names.resources, id)
+	}
+	if remote.Permissions != nil {
+		for _, role := range []*definitions.PermissionRecipients{
+			&remote.Permissions.Viewers,
+			&remote.Permissions.Requesters,
+			&remote.Permissions.Executers,
+			&remote.Permissions.Admins,
+		} {
+			for i, id := range role.Users {
+				role.Users[i] = lookupName(names.users, id)
+			}
+			for i, id := range role.Groups {
+				role.Groups[i] = lookupName(names.groups, id)
+			}
+		}
+	}
+
+	drift, err := def.Drift(remote)
+	if err != nil {
+		return nil, nil, err
+	}
+	return drift, &remote, nil
+}
+
+// lookupName returns the name of id, or id if it has no name.
+func lookupName(namesByID map[string]string, id string) string {
+	if name, ok := namesByID[id]; ok {
+		return name
+	}
+	return id
+}
+
+// fixLocal updates the drifted fields of def's definition file to the values
+// of remote. All other fields, comments and formatting are left untouched.
+//
+// Templates and definitions with environments are not fixed, since the
+// drifted values can't be mapped back to their source reliably.
+func fixLocal(dir taskdir.TaskDirectory, t target, remote definitions.Definition, drift []definitions.Drift) error {
+	def := t.def
+	if ok, err := dir.IsTemplate(); err != nil {
+		return err
+	} else if ok {
+		return errors.New("definitions that use extends, vars or ${} references must be updated by hand")
+	}
+	if t.env != "" || len(def.Environments) > 0 {
+		return errors.New("definitions with environments must be updated by hand")
+	}
+
+	kind, _, err := def.GetKindAndOptions()
+	if err != nil {
+		return err
+	}
+
+	// Marshal remote into its YAML representation so that values are written
+	// with the same keys as the definition format uses.
+	buf, err := yaml.Marshal(remote)
+	if err != nil {
+		return errors.Wrap(err, "marshalling remote definition")
+	}
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(buf, &values); err != nil {
+		return errors.Wrap(err, "unmarshalling remote definition")
+	}
+
+	fields := map[string]interface{}{}
+	for _, d := range drift {
+		path, err := fieldPath(kind, d.Field)
+		if err != nil {
+			return err
+		}
+		fields[path] = lookup(values, strings.Split(path, "."))
+	}
+
+	return dir.WriteFields(fields)
+}
+
+// fieldPath returns the path of a drifted field in a definition of kind.
+func fieldPath(kind api.TaskKind, field string) (string, error) {
+	switch field {
+	case "kind":
+		return "", errors.New("the kind of the task changed")
+	case "image", "command":
+		return "image." + field, nil
+	}
+
+	if option := strings.TrimPrefix(field, "kindOptions."); option != field {
+		if kind == api.TaskKindREST {
+			switch option {
+			case "body", "bodyType", "formData":
+				return "", errors.Errorf("the %s of the request changed", option)
+			}
+		}
+		return string(kind) + "." + option, nil
+	}
+
+	return field, nil
+}
+
+// lookup returns the value at path in values, or nil if there is none.
+func lookup(values map[string]interface{}, path []string) interface{} {
+	v, ok := values[path[0]]
+	if !ok || len(path) == 1 {
+		return v
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	return lookup(m, path[1:])
+}
+
+func format(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return logger.Gray("<none>")
+	case string:
+		return t
+	default:
+		buf, err := json.Marshal(t)
+		if err != nil {
+			return fmt.Sprintf("%v", t)
+		}
+		return string(buf)
+	}
+}
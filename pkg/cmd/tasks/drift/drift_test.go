@@ -0,0 +1,344 @@
+package drift
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/airplanedev/cli/pkg/api"
+	"github.com/airplanedev/cli/pkg/taskdir/definitions"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClient struct {
+	tasks map[string]api.Task
+}
+
+func (c fakeClient) GetTask(ctx context.Context, slug string) (api.Task, error) {
+	task, ok := c.tasks[slug]
+	if !ok {
+		return api.Task{}, &api.TaskMissingError{}
+	}
+	return task, nil
+}
+
+func (c fakeClient) ListResources(ctx context.Context) (api.ListResourcesResponse, error) {
+	return api.ListResourcesResponse{
+		Resources: []api.Resource{{ID: "res123", Name: "db"}},
+	}, nil
+}
+
+func (c fakeClient) ListUsers(ctx context.Context) (api.ListUsersResponse, error) {
+	return api.ListUsersResponse{
+		Users: []api.User{{ID: "usr123", Email: "hello@airplane.dev"}},
+	}, nil
+}
+
+func (c fakeClient) ListGroups(ctx context.Context) (api.ListGroupsResponse, error) {
+	return api.ListGroupsResponse{
+		Groups: []api.Group{{ID: "grp123", Name: "Support"}},
+	}, nil
+}
+
+func nodeTask(slug, name string) api.Task {
+	return api.Task{
+		Slug: slug,
+		Name: name,
+		Kind: api.TaskKindNode,
+		KindOptions: api.KindOptions{
+			"entrypoint":  "main.ts",
+			"language":    "typescript",
+			"nodeVersion": "16",
+		},
+		Resources: api.Resources{"db": "res123"},
+		Permissions: api.Permissions{
+			{Action: api.ActionTasksGet, SubUserID: &userID},
+			{Action: api.ActionRunsGet, SubUserID: &userID},
+		},
+	}
+}
+
+var userID = "usr123"
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "airplane-drift-*")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+	return dir
+}
+
+const myTask = `# My task.
+slug: my_task
+name: My task
+node:
+  entrypoint: main.ts
+  language: typescript
+  nodeVersion: "16"
+resources:
+  db: db
+permissions:
+  viewers:
+    users: [hello@airplane.dev]
+`
+
+func TestDrift(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		files map[string]string
+		tasks []api.Task
+		vars  []string
+		env   string
+		err   string
+	}{
+		{
+			name: "no drift",
+			files: map[string]string{
+				"my_task.yml": myTask,
+				// Other YAML files are ignored:
+				"ci.yml":          "on: push\njobs: {}\n",
+				"list.yaml":       "- a\n- b\n",
+				".github/ci.yaml": "{{ invalid",
+			},
+			tasks: []api.Task{nodeTask("my_task", "My task")},
+		},
+		{
+			name:  "drift",
+			files: map[string]string{"my_task.yml": myTask},
+			tasks: []api.Task{nodeTask("my_task", "Renamed")},
+			err:   "drift detected in 1 task(s)",
+		},
+		{
+			name:  "permissions drift",
+			files: map[string]string{"my_task.yml": myTask},
+			tasks: []api.Task{func() api.Task {
+				task := nodeTask("my_task", "My task")
+				groupID := "grp123"
+				for _, role := range (definitions.PermissionsDefinition{}).Roles() {
+					for _, action := range role.Actions {
+						task.Permissions = append(task.Permissions, api.Permission{Action: action, SubGroupID: &groupID})
+					}
+				}
+				return task
+			}()},
+			err: "drift detected in 1 task(s)",
+		},
+		{
+			name:  "requireExplicitPermissions drift",
+			files: map[string]string{"my_task.yml": myTask},
+			tasks: []api.Task{func() api.Task {
+				task := nodeTask("my_task", "My task")
+				task.RequireExplicitPermissions = true
+				return task
+			}()},
+			err: "drift detected in 1 task(s)",
+		},
+		{
+			name:  "missing task",
+			files: map[string]string{"my_task.yml": myTask},
+			err:   "drift detected in 1 task(s)",
+		},
+		{
+			name: "invalid definition",
+			files: map[string]string{
+				"my_task.yml": myTask,
+				"broken.yml":  "slug: broken\nname: Broken\n",
+			},
+			tasks: []api.Task{nodeTask("my_task", "My task")},
+			err:   "unable to check 1 task definition(s)",
+		},
+		{
+			name:  "invalid YAML",
+			files: map[string]string{"broken.yml": "slug: [broken\n"},
+			err:   "unable to check 1 task definition(s)",
+		},
+		{
+			name: "undefined var",
+			files: map[string]string{
+				"my_task.yml": "vars:\n  slug:\nslug: ${slug}\nname: My task\nnode:\n  entrypoint: main.ts\n  language: typescript\n  nodeVersion: \"16\"\n",
+			},
+			tasks: []api.Task{nodeTask("my_task", "My task")},
+			err:   "unable to check 1 task definition(s)",
+		},
+		{
+			name: "var",
+			files: map[string]string{
+				"my_task.yml": "slug: ${slug}\nname: My task\nnode:\n  entrypoint: main.ts\n  language: typescript\n  nodeVersion: \"16\"\n",
+			},
+			tasks: []api.Task{{
+				Slug:        "my_task",
+				Name:        "My task",
+				Kind:        api.TaskKindNode,
+				KindOptions: nodeTask("", "").KindOptions,
+			}},
+			vars: []string{"slug=my_task"},
+		},
+		{
+			name: "env",
+			files: map[string]string{
+				"my_task.yml": myTask + "environments:\n  staging:\n    resources:\n      db: db\n",
+			},
+			tasks: []api.Task{nodeTask("my_task_staging", "My task (staging)")},
+			env:   "staging",
+		},
+		{
+			name: "environments",
+			files: map[string]string{
+				"my_task.yml": myTask + "environments:\n  staging: {}\n  prod: {}\n",
+			},
+			// The base task doesn't need to be deployed:
+			tasks: []api.Task{
+				nodeTask("my_task_prod", "My task (prod)"),
+				nodeTask("my_task_staging", "My task (staging)"),
+			},
+		},
+		{
+			name: "drift in an environment",
+			files: map[string]string{
+				"my_task.yml": myTask + "environments:\n  staging: {}\n  prod: {}\n",
+			},
+			tasks: []api.Task{
+				nodeTask("my_task_prod", "My task (prod)"),
+				nodeTask("my_task_staging", "Renamed"),
+			},
+			err: "drift detected in 1 task(s)",
+		},
+		{
+			name: "environment that isn't deployed",
+			files: map[string]string{
+				"my_task.yml": myTask + "environments:\n  staging: {}\n  prod: {}\n",
+			},
+			tasks: []api.Task{nodeTask("my_task_staging", "My task (staging)")},
+			err:   "drift detected in 1 task(s)",
+		},
+		{
+			name: "drift in the deployed base task",
+			files: map[string]string{
+				"my_task.yml": myTask + "environments:\n  staging: {}\n",
+			},
+			tasks: []api.Task{
+				nodeTask("my_task", "Renamed"),
+				nodeTask("my_task_staging", "My task (staging)"),
+			},
+			err: "drift detected in 1 task(s)",
+		},
+		{
+			name: "undefined env",
+			files: map[string]string{
+				"my_task.yml": myTask + "environments:\n  prod: {}\n",
+			},
+			tasks: []api.Task{nodeTask("my_task_staging", "My task (staging)")},
+			env:   "staging",
+			err:   "unable to check 1 task definition(s)",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+
+			client := fakeClient{tasks: map[string]api.Task{}}
+			for _, task := range test.tasks {
+				client.tasks[task.Slug] = task
+			}
+
+			err := run(context.Background(), config{
+				client: client,
+				path:   writeFiles(t, test.files),
+				vars:   test.vars,
+				env:    test.env,
+			})
+			if test.err == "" {
+				require.NoError(err)
+			} else {
+				require.EqualError(err, test.err)
+			}
+		})
+	}
+}
+
+func TestDriftFixLocal(t *testing.T) {
+	require := require.New(t)
+
+	dir := writeFiles(t, map[string]string{"my_task.yml": myTask})
+	remote := nodeTask("my_task", "Renamed")
+	remote.KindOptions["nodeVersion"] = "14"
+	remote.Timeout = 600
+	client := fakeClient{tasks: map[string]api.Task{"my_task": remote}}
+
+	err := run(context.Background(), config{
+		client: client,
+		path:   dir,
+		fix:    "local",
+	})
+	require.NoError(err)
+
+	// Only drifted fields are updated, comments and other fields are retained:
+	buf, err := ioutil.ReadFile(filepath.Join(dir, "my_task.yml"))
+	require.NoError(err)
+	require.Equal(`# My task.
+slug: my_task
+name: Renamed
+node:
+  entrypoint: main.ts
+  language: typescript
+  nodeVersion: "14"
+resources:
+  db: db
+permissions:
+  viewers:
+    users: [hello@airplane.dev]
+timeout: 600
+`, string(buf))
+
+	// The definition no longer drifts:
+	err = run(context.Background(), config{client: client, path: dir})
+	require.NoError(err)
+
+	// Templates are not flattened:
+	template := "vars:\n  name: Renamed\n" + myTask
+	dir = writeFiles(t, map[string]string{"my_task.yml": template})
+	err = run(context.Background(), config{
+		client: client,
+		path:   dir,
+		fix:    "local",
+	})
+	require.EqualError(err, "drift detected in 1 task(s)")
+	buf, err = ioutil.ReadFile(filepath.Join(dir, "my_task.yml"))
+	require.NoError(err)
+	require.Equal(template, string(buf))
+
+	// Definitions with environments are not fixed, neither from the base
+	// task nor from the tasks of its environments:
+	withEnvs := myTask + "environments:\n  staging: {}\n"
+	dir = writeFiles(t, map[string]string{"my_task.yml": withEnvs})
+	client.tasks["my_task_staging"] = nodeTask("my_task_staging", "Renamed")
+	err = run(context.Background(), config{
+		client: client,
+		path:   dir,
+		fix:    "local",
+	})
+	require.EqualError(err, "drift detected in 2 task(s)")
+	buf, err = ioutil.ReadFile(filepath.Join(dir, "my_task.yml"))
+	require.NoError(err)
+	require.Equal(withEnvs, string(buf))
+
+	// Files with multiple tasks are not fixed yet:
+	multi := "node:\n  entrypoint: main.ts\n  language: typescript\n  nodeVersion: \"16\"\ntasks:\n  - slug: my_task\n    name: My task\n  - slug: other_task\n    name: Other task\n"
+	dir = writeFiles(t, map[string]string{"tasks.yml": multi})
+	client.tasks["other_task"] = nodeTask("other_task", "Other task")
+	err = run(context.Background(), config{
+		client: client,
+		path:   dir,
+		fix:    "local",
+	})
+	require.EqualError(err, "drift detected in 2 task(s)")
+	buf, err = ioutil.ReadFile(filepath.Join(dir, "tasks.yml"))
+	require.NoError(err)
+	require.Equal(multi, string(buf))
+}
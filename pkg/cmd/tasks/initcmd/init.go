@@ -22,6 +22,7 @@ import (
 	"github.com/airplanedev/cli/pkg/runtime"
 	_ "github.com/airplanedev/cli/pkg/runtime/javascript"
 	_ "github.com/airplanedev/cli/pkg/runtime/python"
+	_ "github.com/airplanedev/cli/pkg/runtime/ruby"
 	_ "github.com/airplanedev/cli/pkg/runtime/shell"
 	_ "github.com/airplanedev/cli/pkg/runtime/typescript"
 	"github.com/airplanedev/cli/pkg/utils"
@@ -0,0 +1,83 @@
+package render
+
+import (
+	"context"
+	"os"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/airplanedev/cli/pkg/cli"
+	"github.com/airplanedev/cli/pkg/taskdir"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the render config.
+type config struct {
+	root *cli.Config
+	file string
+	vars []string
+	env  string
+}
+
+// New returns a new render command.
+func New(c *cli.Config) *cobra.Command {
+	var cfg = config{root: c}
+
+	cmd := &cobra.Command{
+		Use:   "render ./path/to/airplane.yml",
+		Short: "Print a fully expanded task definition",
+		Long:  "Resolves extends and interpolates variables in a task definition, then prints the result.",
+		Example: heredoc.Doc(`
+			airplane tasks render ./airplane.yml
+			airplane tasks render ./airplane.yml --var env=staging
+			airplane tasks render ./airplane.yml --env staging
+		`),
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg.file = args[0]
+			return run(cmd.Root().Context(), cfg)
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&cfg.vars, "var", nil, "Set a task definition variable (key=value). Can be repeated.")
+	cmd.Flags().StringVar(&cfg.env, "env", "", "Render the task as deployed to an environment declared in its definition.")
+
+	return cmd
+}
+
+// Run runs the render command.
+func run(ctx context.Context, cfg config) error {
+	vars, err := taskdir.ParseVars(cfg.vars)
+	if err != nil {
+		return err
+	}
+
+	dir, err := taskdir.OpenWithVars(cfg.file, vars)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	defs, err := dir.ReadDefinitions()
+	if err != nil {
+		return err
+	}
+	if cfg.env != "" {
+		for i := range defs {
+			if defs[i], err = defs[i].ForEnvironment(cfg.env); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Files with multiple tasks are rendered as multiple YAML documents.
+	enc := yaml.NewEncoder(os.Stdout)
+	enc.SetIndent(2)
+	for _, def := range defs {
+		if err := enc.Encode(def); err != nil {
+			return errors.Wrap(err, "encoding task definition")
+		}
+	}
+	return enc.Close()
+}
@@ -49,6 +49,16 @@ func (j *JSON) run(run api.Run) {
 	j.enc.Encode(run)
 }
 
+// Builds implementation.
+func (j *JSON) builds(builds []api.Build) {
+	j.enc.Encode(builds)
+}
+
+// Build implementation.
+func (j *JSON) build(build api.Build) {
+	j.enc.Encode(build)
+}
+
 // Outputs implementation.
 func (j *JSON) outputs(outputs api.Outputs) {
 	for key, values := range outputs {
@@ -19,6 +19,8 @@ type Formatter interface {
 	task(api.Task)
 	runs([]api.Run)
 	run(api.Run)
+	builds([]api.Build)
+	build(api.Build)
 	outputs(api.Outputs)
 	config(api.Config)
 }
@@ -48,6 +50,16 @@ func Run(run api.Run) {
 	DefaultFormatter.run(run)
 }
 
+// Builds prints the given builds.
+func Builds(builds []api.Build) {
+	DefaultFormatter.builds(builds)
+}
+
+// Build prints a single build.
+func Build(build api.Build) {
+	DefaultFormatter.build(build)
+}
+
 // Outputs prints a collection of outputs.
 func Outputs(outputs api.Outputs) {
 	DefaultFormatter.outputs(outputs)
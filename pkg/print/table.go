@@ -163,6 +163,29 @@ func (t Table) run(run api.Run) {
 	t.runs([]api.Run{run})
 }
 
+// Builds implementation.
+func (t Table) builds(builds []api.Build) {
+	tw := tablewriter.NewWriter(os.Stdout)
+	tw.SetBorder(false)
+	tw.SetHeader([]string{"id", "status", "created at", "task revision"})
+
+	for _, build := range builds {
+		tw.Append([]string{
+			build.ID,
+			string(build.Status),
+			build.CreatedAt.Format(time.RFC3339),
+			build.TaskRevisionID,
+		})
+	}
+
+	tw.Render()
+}
+
+// Build implementation.
+func (t Table) build(build api.Build) {
+	t.builds([]api.Build{build})
+}
+
 // print outputs as table
 func (t Table) outputs(outputs api.Outputs) {
 	// Sort the output keys to match the UI.
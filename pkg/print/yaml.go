@@ -42,6 +42,16 @@ func (YAML) run(run api.Run) {
 	yaml.NewEncoder(os.Stdout).Encode(run)
 }
 
+// Builds implementation.
+func (YAML) builds(builds []api.Build) {
+	yaml.NewEncoder(os.Stdout).Encode(builds)
+}
+
+// Build implementation.
+func (YAML) build(build api.Build) {
+	yaml.NewEncoder(os.Stdout).Encode(build)
+}
+
 // Outputs implementation.
 func (YAML) outputs(outputs api.Outputs) {
 	var rows []api.OutputRow
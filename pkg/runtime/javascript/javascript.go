@@ -59,12 +59,11 @@ func (r Runtime) Generate(t api.Task) ([]byte, error) {
 //
 // For JS, that is the nearest parent directory containing a `package.json`.
 func (r Runtime) Workdir(path string) (string, error) {
-	if p, ok := fsx.Find(path, "package.json"); ok {
-		return p, nil
+	b, err := build.Lookup(build.NameNode)
+	if err != nil {
+		return "", err
 	}
-
-	// Otherwise default to immediate directory of path
-	return filepath.Dir(path), nil
+	return b.Workdir(path)
 }
 
 // Root picks which directory to use as the root of a task's code.
@@ -73,39 +72,11 @@ func (r Runtime) Workdir(path string) (string, error) {
 // For JS, this is usually just the workdir. However, this can be overridden
 // with the `airplane.root` property in the `package.json`.
 func (r Runtime) Root(path string) (string, error) {
-	// By default, the root is the workdir.
-	root, err := r.Workdir(path)
+	b, err := build.Lookup(build.NameNode)
 	if err != nil {
 		return "", err
 	}
-
-	// Unless the root is overridden with an `airplane.root` field
-	// in a `package.json`.
-	pkgjson := filepath.Join(root, "package.json")
-	buf, err := os.ReadFile(pkgjson)
-	if err != nil {
-		// No package.json, use workdir as root.
-		if os.IsNotExist(err) {
-			logger.Debug("no package.json found")
-			return root, nil
-		}
-		return "", errors.Wrapf(err, "javascript: reading %s", pkgjson)
-	}
-	logger.Debug("found package.json at %s", pkgjson)
-
-	var pkg struct {
-		Settings runtime.Settings `json:"airplane"`
-	}
-
-	if err := json.Unmarshal(buf, &pkg); err != nil {
-		return "", fmt.Errorf("javascript: reading %s - %w", root, err)
-	}
-
-	if pkgjsonRoot := pkg.Settings.Root; pkgjsonRoot != "" {
-		return filepath.Join(root, pkgjsonRoot), nil
-	}
-
-	return root, nil
+	return b.Root(path)
 }
 
 // Kind implementation.
@@ -123,16 +94,32 @@ func (r Runtime) FormatComment(s string) string {
 
 func (r Runtime) PrepareRun(ctx context.Context, opts runtime.PrepareRunOptions) ([]string, error) {
 	checkNodeVersion(ctx, opts.KindOptions)
-	isTscNpx, err := checkTscInstalled(ctx)
-	if err != nil {
-		return nil, err
-	}
 
 	root, err := r.Root(opts.Path)
 	if err != nil {
 		return nil, err
 	}
 
+	// Yarn Plug'n'Play projects have no node_modules, their dependencies
+	// (including TypeScript) are resolved through the .pnp.cjs loader.
+	pnp, isPnP := findPnP(root)
+	bundle := build.IsBundle(opts.KindOptions)
+	if bundle && isPnP {
+		return nil, errors.New("bundling is not supported for Yarn Plug'n'Play projects")
+	}
+
+	var isNpx bool
+	switch {
+	case bundle:
+		if isNpx, err = checkInstalled(ctx, esbuildCLI); err != nil {
+			return nil, err
+		}
+	case !isPnP:
+		if isNpx, err = checkInstalled(ctx, typescriptCLI); err != nil {
+			return nil, err
+		}
+	}
+
 	if err := os.Mkdir(filepath.Join(root, ".airplane"), os.ModeDir|0777); err != nil && !os.IsExist(err) {
 		return nil, errors.Wrap(err, "creating .airplane directory")
 	}
@@ -141,7 +128,11 @@ func (r Runtime) PrepareRun(ctx context.Context, opts runtime.PrepareRunOptions)
 	if err != nil {
 		return nil, errors.Wrap(err, "entrypoint is not within the task root")
 	}
-	shim, err := build.NodeShim(entrypoint)
+	b, err := build.Lookup(build.NameNode)
+	if err != nil {
+		return nil, err
+	}
+	shim, err := b.Shim(root, entrypoint)
 	if err != nil {
 		return nil, err
 	}
@@ -150,21 +141,24 @@ func (r Runtime) PrepareRun(ctx context.Context, opts runtime.PrepareRunOptions)
 		return nil, errors.Wrap(err, "writing shim file")
 	}
 
-	// Install the dependencies we need for our shim file:
-	pjson, err := build.GenShimPackageJSON()
-	if err != nil {
-		return nil, err
-	}
-	if err := os.WriteFile(filepath.Join(root, ".airplane/package.json"), pjson, 0644); err != nil {
-		return nil, errors.Wrap(err, "writing shim package.json")
-	}
-	cmd := exec.CommandContext(ctx, "npm", "install")
-	cmd.Dir = filepath.Join(root, ".airplane")
-	logger.Debug("Running %s (in %s)", logger.Bold(strings.Join(cmd.Args, " ")), root)
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		logger.Log(strings.TrimSpace(string(out)))
-		return nil, errors.New("failed to install shim deps")
+	// Install the dependencies we need for our shim file, the bundler
+	// doesn't type check so it doesn't need them:
+	if !bundle {
+		pjson, err := build.GenShimPackageJSON()
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(filepath.Join(root, ".airplane/package.json"), pjson, 0644); err != nil {
+			return nil, errors.Wrap(err, "writing shim package.json")
+		}
+		cmd := exec.CommandContext(ctx, "npm", "install")
+		cmd.Dir = filepath.Join(root, ".airplane")
+		logger.Debug("Running %s (in %s)", logger.Bold(strings.Join(cmd.Args, " ")), root)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			logger.Log(strings.TrimSpace(string(out)))
+			return nil, errors.New("failed to install shim deps")
+		}
 	}
 
 	if content, err := build.GenTSConfig(root, opts.Path, opts.KindOptions); err != nil {
@@ -184,16 +178,27 @@ func (r Runtime) PrepareRun(ctx context.Context, opts runtime.PrepareRunOptions)
 		return nil, errors.New("a package.json is missing")
 	}
 
+	shimPath := filepath.Join(root, ".airplane/dist/.airplane/shim.js")
+
 	start := time.Now()
-	tscArgs := []string{"--pretty", "-p", filepath.Join(root, ".airplane")}
-	if isTscNpx {
-		cmd = exec.CommandContext(ctx, "npx", append([]string{"-p", "typescript", "--no", "tsc", "--"}, tscArgs...)...)
-	} else {
-		cmd = exec.CommandContext(ctx, "tsc", tscArgs...)
+	var cmd *exec.Cmd
+	switch {
+	case bundle:
+		args := build.ESBuildArgs(
+			filepath.Join(root, ".airplane/shim.ts"),
+			filepath.Join(root, ".airplane/tsconfig.json"),
+			shimPath,
+			opts.KindOptions,
+		)
+		cmd = esbuildCLI.command(ctx, isNpx, args...)
+	case isPnP:
+		cmd = exec.CommandContext(ctx, "yarn", "tsc", "--pretty", "-p", filepath.Join(root, ".airplane"))
+	default:
+		cmd = typescriptCLI.command(ctx, isNpx, "--pretty", "-p", filepath.Join(root, ".airplane"))
 	}
 	cmd.Dir = root
 	logger.Debug("Running %s (in %s)", logger.Bold(strings.Join(cmd.Args, " ")), root)
-	out, err = cmd.CombinedOutput()
+	out, err := cmd.CombinedOutput()
 	if err != nil {
 		logger.Log(strings.TrimSpace(string(out)))
 		return nil, errors.Errorf("failed to compile %s", opts.Path)
@@ -205,55 +210,99 @@ func (r Runtime) PrepareRun(ctx context.Context, opts runtime.PrepareRunOptions)
 		return nil, errors.Wrap(err, "serializing param values")
 	}
 
-	return []string{"node", filepath.Join(root, ".airplane/dist/.airplane/shim.js"), string(pv)}, nil
+	if isPnP {
+		return []string{"node", "-r", pnp, shimPath, string(pv)}, nil
+	}
+	return []string{"node", shimPath, string(pv)}, nil
+}
+
+// findPnP returns the path of the Yarn Plug'n'Play loader of the project
+// at root, if it uses Plug'n'Play.
+func findPnP(root string) (string, bool) {
+	for _, name := range []string{".pnp.cjs", ".pnp.js"} {
+		if dir, ok := fsx.Find(root, name); ok {
+			return filepath.Join(dir, name), true
+		}
+	}
+	return "", false
+}
+
+// cli is a Node CLI that is required to run tasks locally.
+type cli struct {
+	// Name is the human-readable name of the CLI.
+	Name string
+	// Package is the npm package of the CLI.
+	Package string
+	// Bin is the name of the executable.
+	Bin string
+	// Version is the version to install if the CLI is missing.
+	Version string
 }
 
-// checkTscInstalled will verify that the Typescript CLI is installed.
+var (
+	typescriptCLI = cli{Name: "TypeScript CLI", Package: "typescript", Bin: "tsc"}
+	esbuildCLI    = cli{Name: "esbuild bundler", Package: "esbuild", Bin: "esbuild", Version: build.ESBuildVersion}
+)
+
+// command returns a command that runs the CLI, either through npx or from
+// the user's PATH.
+func (c cli) command(ctx context.Context, npx bool, args ...string) *exec.Cmd {
+	if npx {
+		return exec.CommandContext(ctx, "npx", append([]string{"-p", c.Package, "--no", c.Bin, "--"}, args...)...)
+	}
+	return exec.CommandContext(ctx, c.Bin, args...)
+}
+
+// checkInstalled will verify that the CLI is installed.
 //
-// If not installed, it will auto-install tsc.
+// If not installed, it will auto-install the CLI.
 //
-// Returns true if tsc is available through npx and false if available
+// Returns true if the CLI is available through npx and false if available
 // on the user's PATH.
-func checkTscInstalled(ctx context.Context) (bool, error) {
-	// Check if the user has tsc installed in their local node_modules:
-	// note: --no will prevent installing typescript if not already installed.
-	cmd := exec.CommandContext(ctx, "npx", "-p", "typescript", "--no", "tsc", "--", "--version")
+func checkInstalled(ctx context.Context, c cli) (bool, error) {
+	// Check if the user has the CLI installed in their local node_modules:
+	// note: --no will prevent installing the CLI if not already installed.
+	cmd := c.command(ctx, true, "--version")
 	logger.Debug("Running %s", logger.Bold(strings.Join(cmd.Args, " ")))
 	if out, err := cmd.CombinedOutput(); err == nil {
-		logger.Debug("TypeScript version: %s", strings.TrimPrefix(strings.TrimSpace(string(out)), "Version "))
-		// tsc is installed, return early
+		logger.Debug("%s version: %s", c.Name, strings.TrimPrefix(strings.TrimSpace(string(out)), "Version "))
+		// The CLI is installed, return early
 		return true, nil
 	}
 
 	// Otherwise, try and see if they have it installed globally.
-	cmd = exec.CommandContext(ctx, "tsc", "--version")
+	cmd = c.command(ctx, false, "--version")
 	logger.Debug("Running %s", logger.Bold(strings.Join(cmd.Args, " ")))
 	if out, err := cmd.CombinedOutput(); err == nil {
-		logger.Debug("TypeScript version: %s", strings.TrimPrefix(strings.TrimSpace(string(out)), "Version "))
-		// tsc is installed, return early
+		logger.Debug("%s version: %s", c.Name, strings.TrimPrefix(strings.TrimSpace(string(out)), "Version "))
+		// The CLI is installed, return early
 		return false, nil
 	}
 
-	// Typescript is not installed. Confirm with the user if they are
+	// The CLI is not installed. Confirm with the user if they are
 	// okay with installing it.
-	cmd = exec.CommandContext(ctx, "npm", "install", "--global", "typescript")
+	pkg := c.Package
+	if c.Version != "" {
+		pkg += "@" + c.Version
+	}
+	cmd = exec.CommandContext(ctx, "npm", "install", "--global", pkg)
 	if utils.CanPrompt() {
-		logger.Log("Airplane needs to run %s to install the TypeScript CLI.", logger.Bold(strings.Join(cmd.Args, " ")))
+		logger.Log("Airplane needs to run %s to install the %s.", logger.Bold(strings.Join(cmd.Args, " ")), c.Name)
 		confirmed, err := utils.Confirm("Run now?")
 		if err != nil {
 			return false, err
 		}
 		if !confirmed {
-			return false, errors.New("unable to run without the TypeScript CLI")
+			return false, errors.Errorf("unable to run without the %s", c.Name)
 		}
 	}
 
 	logger.Debug("Running %s", logger.Bold(strings.Join(cmd.Args, " ")))
 	if err := cmd.Run(); err != nil {
-		return false, errors.Wrap(err, "installing tsc")
+		return false, errors.Wrapf(err, "installing %s", c.Bin)
 	}
 
-	// Since we installed tsc globally, return false.
+	// Since we installed the CLI globally, return false.
 	return false, nil
 }
 
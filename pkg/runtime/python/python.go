@@ -14,7 +14,6 @@ import (
 	"github.com/MakeNowJust/heredoc"
 	"github.com/airplanedev/cli/pkg/api"
 	"github.com/airplanedev/cli/pkg/build"
-	"github.com/airplanedev/cli/pkg/fsx"
 	"github.com/airplanedev/cli/pkg/logger"
 	"github.com/airplanedev/cli/pkg/runtime"
 	"github.com/pkg/errors"
@@ -59,7 +58,11 @@ func (r Runtime) PrepareRun(ctx context.Context, opts runtime.PrepareRunOptions)
 	if err != nil {
 		return nil, errors.Wrap(err, "entrypoint is not within the task root")
 	}
-	shim, err := build.PythonShim(root, entrypoint)
+	b, err := build.Lookup(build.NamePython)
+	if err != nil {
+		return nil, err
+	}
+	shim, err := b.Shim(root, entrypoint)
 	if err != nil {
 		return nil, err
 	}
@@ -73,7 +76,47 @@ func (r Runtime) PrepareRun(ctx context.Context, opts runtime.PrepareRunOptions)
 		return nil, errors.Wrap(err, "serializing param values")
 	}
 
-	return []string{"python3", filepath.Join(root, ".airplane/shim.py"), string(pv)}, nil
+	python, err := pythonInterpreter(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{python, filepath.Join(root, ".airplane/shim.py"), string(pv)}, nil
+}
+
+// pythonInterpreter returns the Python interpreter to run the task at root
+// with. Poetry and Pipenv projects are run with the interpreter of their
+// virtualenv so that the locked dependencies are used.
+func pythonInterpreter(ctx context.Context, root string) (string, error) {
+	pm, err := build.GetPythonPackageManager(root)
+	if err != nil {
+		return "", err
+	}
+
+	var cmd *exec.Cmd
+	switch pm {
+	case build.PythonPoetry:
+		cmd = exec.CommandContext(ctx, "poetry", "env", "info", "--path")
+	case build.PythonPipenv:
+		cmd = exec.CommandContext(ctx, "pipenv", "--py")
+	default:
+		return "python3", nil
+	}
+	cmd.Dir = root
+
+	logger.Debug("Running %s", logger.Bold(strings.Join(cmd.Args, " ")))
+	out, err := cmd.Output()
+	if err != nil || len(bytes.TrimSpace(out)) == 0 {
+		logger.Warning("Unable to find the %s virtualenv of %s, falling back to python3", pm, root)
+		logger.Debug("%s: %v", cmd.Args[0], err)
+		return "python3", nil
+	}
+
+	path := strings.TrimSpace(string(out))
+	if pm == build.PythonPoetry {
+		path = filepath.Join(path, "bin", "python")
+	}
+	return path, nil
 }
 
 // Checks for python3 binary, as per PEP 0394:
@@ -110,12 +153,15 @@ func (r Runtime) Workdir(path string) (string, error) {
 }
 
 // Root implementation.
+//
+// The root is the closest parent directory that contains one of the files
+// of a Python project, f.e. requirements.txt or pyproject.toml.
 func (r Runtime) Root(path string) (string, error) {
-	root, ok := fsx.Find(path, "requirements.txt")
-	if !ok {
-		return filepath.Dir(path), nil
+	b, err := build.Lookup(build.NamePython)
+	if err != nil {
+		return "", err
 	}
-	return root, nil
+	return b.Root(path)
 }
 
 // Kind implementation.
@@ -0,0 +1,139 @@
+package ruby
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/airplanedev/cli/pkg/api"
+	"github.com/airplanedev/cli/pkg/build"
+	"github.com/airplanedev/cli/pkg/logger"
+	"github.com/airplanedev/cli/pkg/runtime"
+	"github.com/pkg/errors"
+)
+
+// Init register the runtime.
+func init() {
+	runtime.Register(".rb", Runtime{})
+}
+
+// Code template.
+var code = template.Must(template.New("rb").Parse(`{{.Comment}}
+
+def main(params)
+  puts "parameters: #{params}"
+end
+`))
+
+// Data represents the data template.
+type data struct {
+	Comment string
+}
+
+// Runtime implementation.
+type Runtime struct{}
+
+// PrepareRun implementation.
+func (r Runtime) PrepareRun(ctx context.Context, opts runtime.PrepareRunOptions) ([]string, error) {
+	if err := checkRubyInstalled(ctx); err != nil {
+		return nil, err
+	}
+
+	root, err := r.Root(opts.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Mkdir(filepath.Join(root, ".airplane"), os.ModeDir|0777); err != nil && !os.IsExist(err) {
+		return nil, errors.Wrap(err, "creating .airplane directory")
+	}
+
+	entrypoint, err := filepath.Rel(root, opts.Path)
+	if err != nil {
+		return nil, errors.Wrap(err, "entrypoint is not within the task root")
+	}
+	b, err := build.Lookup(build.NameRuby)
+	if err != nil {
+		return nil, err
+	}
+	shim, err := b.Shim(root, entrypoint)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.WriteFile(filepath.Join(root, ".airplane/shim.rb"), []byte(shim), 0644); err != nil {
+		return nil, errors.Wrap(err, "writing shim file")
+	}
+
+	pv, err := json.Marshal(opts.ParamValues)
+	if err != nil {
+		return nil, errors.Wrap(err, "serializing param values")
+	}
+
+	return []string{"ruby", filepath.Join(root, ".airplane/shim.rb"), string(pv)}, nil
+}
+
+// checkRubyInstalled checks that the ruby command exists.
+func checkRubyInstalled(ctx context.Context) error {
+	cmd := exec.CommandContext(ctx, "ruby", "--version")
+	logger.Debug("Running %s", logger.Bold(strings.Join(cmd.Args, " ")))
+	if err := cmd.Run(); err != nil {
+		return errors.New(heredoc.Doc(`
+		It looks like the ruby command is not installed.
+
+		Ensure Ruby is installed and the ruby command exists: https://www.ruby-lang.org/en/documentation/installation
+	`))
+	}
+	return nil
+}
+
+// Generate implementation.
+func (r Runtime) Generate(t api.Task) ([]byte, error) {
+	var args = data{Comment: runtime.Comment(r, t)}
+	var buf bytes.Buffer
+
+	if err := code.Execute(&buf, args); err != nil {
+		return nil, fmt.Errorf("ruby: template execute - %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Workdir implementation.
+func (r Runtime) Workdir(path string) (string, error) {
+	return r.Root(path)
+}
+
+// Root implementation.
+//
+// The root is the closest parent directory that contains a Gemfile.
+func (r Runtime) Root(path string) (string, error) {
+	b, err := build.Lookup(build.NameRuby)
+	if err != nil {
+		return "", err
+	}
+	return b.Root(path)
+}
+
+// Kind implementation.
+func (r Runtime) Kind() api.TaskKind {
+	return api.TaskKindRuby
+}
+
+// FormatComment implementation.
+func (r Runtime) FormatComment(s string) string {
+	var lines []string
+
+	for _, line := range strings.Split(s, "\n") {
+		lines = append(lines, "# "+line)
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,35 @@
+package ruby
+
+import (
+	"testing"
+
+	"github.com/airplanedev/cli/pkg/api"
+	"github.com/airplanedev/cli/pkg/runtime"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	require := require.New(t)
+
+	code, err := Runtime{}.Generate(api.Task{URL: "https://app.airplane.dev/t/ruby_task"})
+	require.NoError(err)
+	require.Equal(`# Linked to https://app.airplane.dev/t/ruby_task [do not edit this line]
+
+def main(params)
+  puts "parameters: #{params}"
+end
+`, string(code))
+
+	slug, ok := runtime.Slug(code)
+	require.True(ok)
+	require.Equal("ruby_task", slug)
+}
+
+func TestLookup(t *testing.T) {
+	require := require.New(t)
+
+	r, err := runtime.Lookup(api.TaskKindRuby, "main.rb")
+	require.NoError(err)
+	require.Equal(Runtime{}, r)
+	require.Equal(".rb", runtime.SuggestExt(api.TaskKindRuby))
+}
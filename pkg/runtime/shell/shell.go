@@ -11,7 +11,6 @@ import (
 
 	"github.com/airplanedev/cli/pkg/api"
 	"github.com/airplanedev/cli/pkg/build"
-	"github.com/airplanedev/cli/pkg/fsx"
 	"github.com/airplanedev/cli/pkg/logger"
 	"github.com/airplanedev/cli/pkg/runtime"
 	"github.com/airplanedev/cli/pkg/utils/handlebars"
@@ -58,15 +57,22 @@ func (r Runtime) PrepareRun(ctx context.Context, opts runtime.PrepareRunOptions)
 		return nil, errors.Wrap(err, "creating .airplane directory")
 	}
 
-	shim := build.ShellShim()
-	if err := os.WriteFile(filepath.Join(root, ".airplane/shim.sh"), []byte(shim), 0644); err != nil {
-		return nil, errors.Wrap(err, "writing shim file")
-	}
-
 	entrypoint, err := filepath.Rel(root, opts.Path)
 	if err != nil {
 		return nil, errors.Wrap(err, "entrypoint is not within the task root")
 	}
+	b, err := build.Lookup(build.NameShell)
+	if err != nil {
+		return nil, err
+	}
+	shim, err := b.Shim(root, entrypoint)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.WriteFile(filepath.Join(root, ".airplane/shim.sh"), []byte(shim), 0644); err != nil {
+		return nil, errors.Wrap(err, "writing shim file")
+	}
 
 	cmd := []string{
 		"bash", filepath.Join(root, ".airplane/shim.sh"),
@@ -103,12 +109,11 @@ func (r Runtime) Workdir(path string) (string, error) {
 
 // Root implementation.
 func (r Runtime) Root(path string) (string, error) {
-	for _, filePath := range build.DockerfilePaths() {
-		if root, ok := fsx.Find(path, filePath); ok {
-			return root, nil
-		}
+	b, err := build.Lookup(build.NameShell)
+	if err != nil {
+		return "", err
 	}
-	return filepath.Dir(path), nil
+	return b.Root(path)
 }
 
 // Kind implementation.
@@ -1,6 +1,8 @@
 package definitions
 
 import (
+	"sort"
+
 	"github.com/airplanedev/cli/pkg/api"
 )
 
@@ -17,12 +19,29 @@ type Definition_0_2 struct {
 	Repo             string               `yaml:"repo,omitempty"`
 	Timeout          int                  `yaml:"timeout,omitempty"`
 
+	// Permissions configures who has access to this task. Users are
+	// referenced by email and groups by name, both are resolved to IDs
+	// when the task is deployed.
+	//
+	// If not set, the task's existing permissions are left untouched.
+	Permissions *PermissionsDefinition `yaml:"permissions,omitempty"`
+
+	// Environments contains per-environment overrides, keyed by environment
+	// name. See Definition.ForEnvironment.
+	Environments map[string]EnvironmentDefinition `yaml:"environments,omitempty"`
+
+	// Platforms are the platforms that the task is built for, f.e. linux/arm64.
+	// See Definition.GetRunConstraints.
+	Platforms []string `yaml:"platforms,omitempty"`
+
 	Deno       *DenoDefinition       `yaml:"deno,omitempty"`
 	Image      *ImageDefinition      `yaml:"image,omitempty"`
 	Dockerfile *DockerfileDefinition `yaml:"dockerfile,omitempty"`
 	Go         *GoDefinition         `yaml:"go,omitempty"`
+	Java       *JavaDefinition       `yaml:"java,omitempty"`
 	Node       *NodeDefinition       `yaml:"node,omitempty"`
 	Python     *PythonDefinition     `yaml:"python,omitempty"`
+	Ruby       *RubyDefinition       `yaml:"ruby,omitempty"`
 	Shell      *ShellDefinition      `yaml:"shell,omitempty"`
 
 	SQL  *SQLDefinition  `yaml:"sql,omitempty"`
@@ -38,21 +57,70 @@ type Definition_0_2 struct {
 	Root string `yaml:"root,omitempty"`
 }
 
+type EnvironmentDefinition struct {
+	Env       api.TaskEnv   `yaml:"env,omitempty"`
+	Resources api.Resources `yaml:"resources,omitempty"`
+}
+
+type PermissionsDefinition struct {
+	RequireExplicitPermissions bool `yaml:"requireExplicitPermissions,omitempty"`
+
+	Viewers    PermissionRecipients `yaml:"viewers,omitempty"`
+	Requesters PermissionRecipients `yaml:"requesters,omitempty"`
+	Executers  PermissionRecipients `yaml:"executers,omitempty"`
+	Admins     PermissionRecipients `yaml:"admins,omitempty"`
+}
+
+type PermissionRecipients struct {
+	Users  []string `json:"users,omitempty" yaml:"users,omitempty"`
+	Groups []string `json:"groups,omitempty" yaml:"groups,omitempty"`
+}
+
 type ImageDefinition struct {
 	Image   string   `yaml:"image,omitempty"`
 	Command []string `yaml:"command,omitempty"`
 }
 
 type DenoDefinition struct {
-	Entrypoint string `yaml:"entrypoint" mapstructure:"entrypoint"`
+	Entrypoint  string `yaml:"entrypoint" mapstructure:"entrypoint"`
+	DenoVersion string `yaml:"denoVersion,omitempty" mapstructure:"denoVersion,omitempty"`
+	ImportMap   string `yaml:"importMap,omitempty" mapstructure:"importMap,omitempty"`
+
+	// Permissions are the permissions that the task runs with, f.e.
+	// `net=api.github.com` for `--allow-net=api.github.com`. If not set, the
+	// task is granted all permissions.
+	Permissions []string `yaml:"permissions,omitempty" mapstructure:"permissions,omitempty"`
 }
 
 type DockerfileDefinition struct {
 	Dockerfile string `yaml:"dockerfile" mapstructure:"dockerfile"`
+
+	// BuildArgs are passed to the build as build args, f.e. to configure a
+	// Dockerfile that is shared with other services.
+	BuildArgs map[string]string `yaml:"buildArgs,omitempty" mapstructure:"buildArgs,omitempty"`
+
+	// Target is the stage of a multi-stage Dockerfile to build. If not set,
+	// the last stage is built.
+	Target string `yaml:"target,omitempty" mapstructure:"target,omitempty"`
+
+	// Context is the directory that the task is built in, relative to the
+	// task's root. If not set, the root is the build context. The Dockerfile
+	// is relative to the root either way.
+	Context string `yaml:"context,omitempty" mapstructure:"context,omitempty"`
 }
 
 type GoDefinition struct {
 	Entrypoint string `yaml:"entrypoint" mapstructure:"entrypoint"`
+
+	// Tags and LDFlags are passed to `go build` as -tags and -ldflags.
+	Tags    []string `yaml:"tags,omitempty" mapstructure:"tags,omitempty"`
+	LDFlags string   `yaml:"ldflags,omitempty" mapstructure:"ldflags,omitempty"`
+	// CGO builds the task with CGO_ENABLED=1, the binary then runs in an
+	// image that provides libc.
+	CGO bool `yaml:"cgo,omitempty" mapstructure:"cgo,omitempty"`
+	// BaseImage is the image that the binary runs in, f.e. scratch. If not
+	// set, defaults to a distroless image.
+	BaseImage string `yaml:"baseImage,omitempty" mapstructure:"baseImage,omitempty"`
 }
 
 type NodeDefinition struct {
@@ -60,14 +128,44 @@ type NodeDefinition struct {
 	Entrypoint  string `yaml:"entrypoint" mapstructure:"entrypoint"`
 	Language    string `yaml:"language" mapstructure:"language"`
 	NodeVersion string `yaml:"nodeVersion" mapstructure:"nodeVersion"`
+
+	// Bundle bundles the task into a single file with esbuild. Externals are
+	// excluded from the bundle, f.e. native modules.
+	Bundle    bool     `yaml:"bundle,omitempty" mapstructure:"bundle,omitempty"`
+	Externals []string `yaml:"externals,omitempty" mapstructure:"externals,omitempty"`
 }
 
 type PythonDefinition struct {
-	Entrypoint string `yaml:"entrypoint" mapstructure:"entrypoint"`
+	Entrypoint    string `yaml:"entrypoint" mapstructure:"entrypoint"`
+	PythonVersion string `yaml:"pythonVersion,omitempty" mapstructure:"pythonVersion,omitempty"`
+}
+
+type RubyDefinition struct {
+	// Entrypoint is a Ruby file that defines a `main(params)` method.
+	Entrypoint  string `yaml:"entrypoint" mapstructure:"entrypoint"`
+	RubyVersion string `yaml:"rubyVersion,omitempty" mapstructure:"rubyVersion,omitempty"`
+}
+
+type JavaDefinition struct {
+	// MainClass is the class whose `main` method runs the task, f.e.
+	// `tasks.Main`. It receives the JSON params as its first argument.
+	MainClass   string `yaml:"mainClass" mapstructure:"mainClass"`
+	JavaVersion string `yaml:"javaVersion,omitempty" mapstructure:"javaVersion,omitempty"`
 }
 
 type ShellDefinition struct {
 	Entrypoint string `yaml:"entrypoint" mapstructure:"entrypoint"`
+
+	// BaseImage is the image that the script runs in. If not set, defaults
+	// to Ubuntu with a few common packages, f.e. curl and jq.
+	BaseImage string `yaml:"baseImage,omitempty" mapstructure:"baseImage,omitempty"`
+	// AptPackages and APKPackages are installed into the base image with
+	// apt-get or apk respectively.
+	AptPackages []string `yaml:"aptPackages,omitempty" mapstructure:"aptPackages,omitempty"`
+	APKPackages []string `yaml:"apkPackages,omitempty" mapstructure:"apkPackages,omitempty"`
+	// Setup is a shell command that runs when the image is built, after
+	// packages are installed, f.e. to install a CLI.
+	Setup string `yaml:"setup,omitempty" mapstructure:"setup,omitempty"`
 }
 
 type SQLDefinition struct {
@@ -88,3 +186,125 @@ type RESTDefinition struct {
 func (d Definition_0_2) upgrade() (Definition, error) {
 	return Definition(d), nil
 }
+
+// PermissionRole is a set of recipients that are granted the same actions.
+type PermissionRole struct {
+	Actions    []api.Action
+	Recipients PermissionRecipients
+}
+
+// Roles returns each role of p along with the actions it grants. Every role
+// grants the actions of the roles before it.
+func (p PermissionsDefinition) Roles() []PermissionRole {
+	viewer := []api.Action{api.ActionTasksGet, api.ActionRunsGet}
+	requester := append(viewer[:len(viewer):len(viewer)], api.ActionTriggerRequestsCreate)
+	executer := append(requester[:len(requester):len(requester)], api.ActionTasksExecute, api.ActionRunsCancel)
+	admin := append(executer[:len(executer):len(executer)], api.ActionTasksUpdate)
+
+	return []PermissionRole{
+		{Actions: viewer, Recipients: p.Viewers},
+		{Actions: requester, Recipients: p.Requesters},
+		{Actions: executer, Recipients: p.Executers},
+		{Actions: admin, Recipients: p.Admins},
+	}
+}
+
+// NewPermissionsDefinition returns the definition of a task's permissions,
+// or nil if the task is not restricted to explicit permissions and grants
+// none.
+//
+// Every user and group is listed in the highest role whose actions are all
+// granted to it. Users and groups are referenced by ID, callers are
+// responsible for resolving them to emails and names.
+func NewPermissionsDefinition(requireExplicitPermissions bool, permissions api.Permissions) *PermissionsDefinition {
+	if !requireExplicitPermissions && len(permissions) == 0 {
+		return nil
+	}
+
+	userActions := map[string]map[api.Action]bool{}
+	groupActions := map[string]map[api.Action]bool{}
+	for _, p := range permissions {
+		var actions map[string]map[api.Action]bool
+		var id string
+		if p.SubUserID != nil {
+			actions, id = userActions, *p.SubUserID
+		} else if p.SubGroupID != nil {
+			actions, id = groupActions, *p.SubGroupID
+		} else {
+			continue
+		}
+		if actions[id] == nil {
+			actions[id] = map[api.Action]bool{}
+		}
+		actions[id][p.Action] = true
+	}
+
+	roles := PermissionsDefinition{}.Roles()
+	highestRole := func(granted map[api.Action]bool) int {
+		for i := len(roles) - 1; i >= 0; i-- {
+			ok := true
+			for _, action := range roles[i].Actions {
+				ok = ok && granted[action]
+			}
+			if ok {
+				return i
+			}
+		}
+		return -1
+	}
+
+	userRoles := map[string]int{}
+	for id, granted := range userActions {
+		userRoles[id] = highestRole(granted)
+	}
+	groupRoles := map[string]int{}
+	for id, granted := range groupActions {
+		groupRoles[id] = highestRole(granted)
+	}
+
+	def := newPermissionsDefinition(userRoles, groupRoles)
+	def.RequireExplicitPermissions = requireExplicitPermissions
+	return &def
+}
+
+// Normalize returns p with every user and group only listed in the highest
+// role it's listed in, sorted. Two definitions that grant the same actions
+// normalize to the same definition.
+func (p PermissionsDefinition) Normalize() PermissionsDefinition {
+	userRoles := map[string]int{}
+	groupRoles := map[string]int{}
+	for i, role := range p.Roles() {
+		for _, email := range role.Recipients.Users {
+			userRoles[email] = i
+		}
+		for _, name := range role.Recipients.Groups {
+			groupRoles[name] = i
+		}
+	}
+
+	def := newPermissionsDefinition(userRoles, groupRoles)
+	def.RequireExplicitPermissions = p.RequireExplicitPermissions
+	return def
+}
+
+// newPermissionsDefinition returns a definition that lists users and groups
+// in the role with the given index of Roles. Negative indexes are skipped.
+func newPermissionsDefinition(userRoles, groupRoles map[string]int) PermissionsDefinition {
+	var def PermissionsDefinition
+	recipients := []*PermissionRecipients{&def.Viewers, &def.Requesters, &def.Executers, &def.Admins}
+	for user, i := range userRoles {
+		if i >= 0 {
+			recipients[i].Users = append(recipients[i].Users, user)
+		}
+	}
+	for group, i := range groupRoles {
+		if i >= 0 {
+			recipients[i].Groups = append(recipients[i].Groups, group)
+		}
+	}
+	for _, r := range recipients {
+		sort.Strings(r.Users)
+		sort.Strings(r.Groups)
+	}
+	return def
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/airplanedev/cli/pkg/api"
+	"github.com/airplanedev/cli/pkg/utils"
 	"github.com/mitchellh/mapstructure"
 	"github.com/pkg/errors"
 	"gopkg.in/yaml.v3"
@@ -30,6 +31,7 @@ func NewDefinitionFromTask(task api.Task) (Definition, error) {
 		ResourceRequests: task.ResourceRequests,
 		Repo:             task.Repo,
 		Timeout:          task.Timeout,
+		Permissions:      NewPermissionsDefinition(task.RequireExplicitPermissions, task.Permissions),
 	}
 
 	var taskDef interface{}
@@ -45,6 +47,10 @@ func NewDefinitionFromTask(task api.Task) (Definition, error) {
 		def.Go = &GoDefinition{}
 		taskDef = &def.Go
 
+	} else if task.Kind == api.TaskKindJava {
+		def.Java = &JavaDefinition{}
+		taskDef = &def.Java
+
 	} else if task.Kind == api.TaskKindNode {
 		def.Node = &NodeDefinition{}
 		taskDef = &def.Node
@@ -53,6 +59,10 @@ func NewDefinitionFromTask(task api.Task) (Definition, error) {
 		def.Python = &PythonDefinition{}
 		taskDef = &def.Python
 
+	} else if task.Kind == api.TaskKindRuby {
+		def.Ruby = &RubyDefinition{}
+		taskDef = &def.Ruby
+
 	} else if task.Kind == api.TaskKindImage {
 		def.Image = &ImageDefinition{
 			Command: task.Command,
@@ -105,6 +115,11 @@ func (def Definition) GetKindAndOptions() (api.TaskKind, api.KindOptions, error)
 			return "", api.KindOptions{}, errors.Wrap(err, "decoding Go definition")
 		}
 		return api.TaskKindGo, options, nil
+	} else if def.Java != nil {
+		if err := mapstructure.Decode(def.Java, &options); err != nil {
+			return "", api.KindOptions{}, errors.Wrap(err, "decoding Java definition")
+		}
+		return api.TaskKindJava, options, nil
 	} else if def.Node != nil {
 		if err := mapstructure.Decode(def.Node, &options); err != nil {
 			return "", api.KindOptions{}, errors.Wrap(err, "decoding Node definition")
@@ -115,6 +130,11 @@ func (def Definition) GetKindAndOptions() (api.TaskKind, api.KindOptions, error)
 			return "", api.KindOptions{}, errors.Wrap(err, "decoding Python definition")
 		}
 		return api.TaskKindPython, options, nil
+	} else if def.Ruby != nil {
+		if err := mapstructure.Decode(def.Ruby, &options); err != nil {
+			return "", api.KindOptions{}, errors.Wrap(err, "decoding Ruby definition")
+		}
+		return api.TaskKindRuby, options, nil
 	} else if def.Shell != nil {
 		if err := mapstructure.Decode(def.Shell, &options); err != nil {
 			return "", api.KindOptions{}, errors.Wrap(err, "decoding Shell definition")
@@ -185,12 +205,18 @@ func (def Definition) Validate() (Definition, error) {
 	if def.Go != nil {
 		defs = append(defs, "go")
 	}
+	if def.Java != nil {
+		defs = append(defs, "java")
+	}
 	if def.Node != nil {
 		defs = append(defs, "node")
 	}
 	if def.Python != nil {
 		defs = append(defs, "python")
 	}
+	if def.Ruby != nil {
+		defs = append(defs, "ruby")
+	}
 	if def.SQL != nil {
 		defs = append(defs, "sql")
 	}
@@ -241,6 +267,84 @@ func UnmarshalDefinition(buf []byte, defPath string) (Definition, error) {
 	return def, nil
 }
 
+// UnmarshalDefinitions is like UnmarshalDefinition, but also supports files that
+// define several tasks in a `tasks:` list:
+//
+//	root: ..
+//	env:
+//	  LOG_LEVEL:
+//	    value: info
+//	tasks:
+//	  - slug: first_task
+//	    ...
+//	  - slug: second_task
+//	    ...
+//
+// All other top-level fields are shared, each task inherits them and can
+// override them. Since tasks in the same file share a root, it cannot be
+// overridden.
+func UnmarshalDefinitions(buf []byte, defPath string) ([]Definition, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(buf, &root); err != nil || len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		// Let UnmarshalDefinition produce a friendly error.
+		def, err := UnmarshalDefinition(buf, defPath)
+		if err != nil {
+			return nil, err
+		}
+		return []Definition{def}, nil
+	}
+
+	shared := *root.Content[0]
+	shared.Content = nil
+	var tasks *yaml.Node
+	for i := 0; i+1 < len(root.Content[0].Content); i += 2 {
+		k, v := root.Content[0].Content[i], root.Content[0].Content[i+1]
+		if k.Value == "tasks" {
+			tasks = v
+		} else {
+			shared.Content = append(shared.Content, k, v)
+		}
+	}
+
+	if tasks == nil {
+		def, err := UnmarshalDefinition(buf, defPath)
+		if err != nil {
+			return nil, err
+		}
+		return []Definition{def}, nil
+	}
+	if tasks.Kind != yaml.SequenceNode || len(tasks.Content) == 0 {
+		return nil, newErrReadDefinition(fmt.Sprintf("Error reading %s", defPath), "tasks: Expected a non-empty list of tasks")
+	}
+
+	var defs []Definition
+	slugs := map[string]bool{}
+	for i, task := range tasks.Content {
+		taskPath := fmt.Sprintf("%s (tasks[%d])", defPath, i)
+		if task.Kind == yaml.MappingNode {
+			if node, err := utils.GetYAMLNode(task, "root"); err == nil && node != nil {
+				return nil, newErrReadDefinition(fmt.Sprintf("Error reading %s", taskPath), "root: Tasks in the same file share a root, set it at the top-level instead")
+			}
+		}
+
+		buf, err := yaml.Marshal(utils.MergeYAMLNodes(&shared, task))
+		if err != nil {
+			return nil, errors.Wrap(err, "marshalling task definition")
+		}
+		def, err := UnmarshalDefinition(buf, taskPath)
+		if err != nil {
+			return nil, err
+		}
+		if slugs[def.Slug] {
+			return nil, newErrReadDefinition(fmt.Sprintf("Error reading %s", defPath), fmt.Sprintf("tasks: Duplicate task slug %q", def.Slug))
+		}
+		slugs[def.Slug] = true
+		defs = append(defs, def)
+	}
+
+	return defs, nil
+}
+
 func tryOlderDefinitions(buf []byte) (Definition, error) {
 	var err error
 	if err = validateYAML(buf, Definition_0_1{}); err == nil {
@@ -0,0 +1,232 @@
+package definitions
+
+import (
+	"testing"
+
+	"github.com/airplanedev/cli/pkg/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnmarshalDefinitions(t *testing.T) {
+	require := require.New(t)
+
+	// A single task:
+	defs, err := UnmarshalDefinitions([]byte(`
+slug: my_task
+name: My task
+python:
+  entrypoint: main.py
+`), "airplane.yml")
+	require.NoError(err)
+	require.Len(defs, 1)
+	require.Equal("my_task", defs[0].Slug)
+
+	// Multiple tasks inherit top-level fields:
+	defs, err = UnmarshalDefinitions([]byte(`
+root: ..
+timeout: 60
+node:
+  language: typescript
+  nodeVersion: "16"
+tasks:
+  - slug: first
+    name: First
+    node:
+      entrypoint: first.ts
+  - slug: second
+    name: Second
+    timeout: 120
+    node:
+      entrypoint: second.ts
+`), "airplane.yml")
+	require.NoError(err)
+	require.Len(defs, 2)
+	require.Equal("first", defs[0].Slug)
+	require.Equal("..", defs[0].Root)
+	require.Equal(60, defs[0].Timeout)
+	require.Equal(NodeDefinition{Entrypoint: "first.ts", Language: "typescript", NodeVersion: "16"}, *defs[0].Node)
+	require.Equal("second", defs[1].Slug)
+	require.Equal("..", defs[1].Root)
+	require.Equal(120, defs[1].Timeout)
+	require.Equal(NodeDefinition{Entrypoint: "second.ts", Language: "typescript", NodeVersion: "16"}, *defs[1].Node)
+
+	// Tasks cannot override the shared root:
+	_, err = UnmarshalDefinitions([]byte(`
+tasks:
+  - slug: first
+    name: First
+    root: ./first
+    python:
+      entrypoint: main.py
+`), "airplane.yml")
+	require.Error(err)
+
+	// Slugs must be unique:
+	_, err = UnmarshalDefinitions([]byte(`
+python:
+  entrypoint: main.py
+tasks:
+  - slug: first
+    name: First
+  - slug: first
+    name: Second
+`), "airplane.yml")
+	require.Error(err)
+}
+
+func TestRubyDefinition(t *testing.T) {
+	require := require.New(t)
+
+	defs, err := UnmarshalDefinitions([]byte(`
+slug: my_task
+name: My task
+ruby:
+  entrypoint: main.rb
+  rubyVersion: "2.7"
+`), "airplane.yml")
+	require.NoError(err)
+	require.Len(defs, 1)
+	require.Equal(RubyDefinition{Entrypoint: "main.rb", RubyVersion: "2.7"}, *defs[0].Ruby)
+
+	kind, options, err := defs[0].GetKindAndOptions()
+	require.NoError(err)
+	require.Equal(api.TaskKindRuby, kind)
+	require.Equal(api.KindOptions{"entrypoint": "main.rb", "rubyVersion": "2.7"}, options)
+
+	def, err := NewDefinitionFromTask(api.Task{Slug: "my_task", Kind: kind, KindOptions: options})
+	require.NoError(err)
+	require.Equal(defs[0].Ruby, def.Ruby)
+
+	// Only one kind can be defined:
+	_, err = Definition{
+		Slug:   "my_task",
+		Ruby:   &RubyDefinition{Entrypoint: "main.rb"},
+		Python: &PythonDefinition{Entrypoint: "main.py"},
+	}.Validate()
+	require.Error(err)
+}
+
+func TestDockerfileDefinition(t *testing.T) {
+	require := require.New(t)
+
+	defs, err := UnmarshalDefinitions([]byte(`
+slug: my_task
+name: My task
+dockerfile:
+  dockerfile: ../Dockerfile
+  buildArgs:
+    VARIANT: task
+  target: task
+  context: service
+`), "airplane.yml")
+	require.NoError(err)
+	require.Len(defs, 1)
+	require.Equal(DockerfileDefinition{
+		Dockerfile: "../Dockerfile",
+		BuildArgs:  map[string]string{"VARIANT": "task"},
+		Target:     "task",
+		Context:    "service",
+	}, *defs[0].Dockerfile)
+
+	kind, options, err := defs[0].GetKindAndOptions()
+	require.NoError(err)
+	require.Equal(api.TaskKindDockerfile, kind)
+	require.Equal(api.KindOptions{
+		"dockerfile": "../Dockerfile",
+		"buildArgs":  map[string]string{"VARIANT": "task"},
+		"target":     "task",
+		"context":    "service",
+	}, options)
+
+	// Options read from the API are decoded from JSON:
+	def, err := NewDefinitionFromTask(api.Task{
+		Slug: "my_task",
+		Kind: api.TaskKindDockerfile,
+		KindOptions: api.KindOptions{
+			"dockerfile": "../Dockerfile",
+			"buildArgs":  map[string]interface{}{"VARIANT": "task"},
+			"target":     "task",
+			"context":    "service",
+		},
+	})
+	require.NoError(err)
+	require.Equal(defs[0].Dockerfile, def.Dockerfile)
+
+	// Optional fields are omitted:
+	_, options, err = Definition{
+		Slug:       "my_task",
+		Dockerfile: &DockerfileDefinition{Dockerfile: "Dockerfile"},
+	}.GetKindAndOptions()
+	require.NoError(err)
+	require.Equal(api.KindOptions{"dockerfile": "Dockerfile"}, options)
+}
+
+func TestPermissionRoles(t *testing.T) {
+	viewer := []api.Action{api.ActionTasksGet, api.ActionRunsGet}
+	requester := append(viewer[:len(viewer):len(viewer)], api.ActionTriggerRequestsCreate)
+	executer := append(requester[:len(requester):len(requester)], api.ActionTasksExecute, api.ActionRunsCancel)
+	admin := append(executer[:len(executer):len(executer)], api.ActionTasksUpdate)
+
+	for _, test := range []struct {
+		name  string
+		def   PermissionsDefinition
+		roles []PermissionRole
+	}{
+		{
+			name: "empty",
+			roles: []PermissionRole{
+				{Actions: viewer},
+				{Actions: requester},
+				{Actions: executer},
+				{Actions: admin},
+			},
+		},
+		{
+			name: "recipients",
+			def: PermissionsDefinition{
+				RequireExplicitPermissions: true,
+				Viewers:                    PermissionRecipients{Groups: []string{"eng"}},
+				Executers:                  PermissionRecipients{Users: []string{"ada@airplane.dev"}},
+				Admins:                     PermissionRecipients{Users: []string{"ada@airplane.dev"}, Groups: []string{"ops"}},
+			},
+			roles: []PermissionRole{
+				{Actions: viewer, Recipients: PermissionRecipients{Groups: []string{"eng"}}},
+				{Actions: requester},
+				{Actions: executer, Recipients: PermissionRecipients{Users: []string{"ada@airplane.dev"}}},
+				{Actions: admin, Recipients: PermissionRecipients{Users: []string{"ada@airplane.dev"}, Groups: []string{"ops"}}},
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+
+			require.Equal(test.roles, test.def.Roles())
+		})
+	}
+}
+
+func TestJavaDefinition(t *testing.T) {
+	require := require.New(t)
+
+	defs, err := UnmarshalDefinitions([]byte(`
+slug: my_task
+name: My task
+java:
+  mainClass: tasks.Main
+  javaVersion: "11"
+`), "airplane.yml")
+	require.NoError(err)
+	require.Len(defs, 1)
+	require.Equal(JavaDefinition{MainClass: "tasks.Main", JavaVersion: "11"}, *defs[0].Java)
+	_, err = defs[0].Validate()
+	require.NoError(err)
+
+	kind, options, err := defs[0].GetKindAndOptions()
+	require.NoError(err)
+	require.Equal(api.TaskKindJava, kind)
+	require.Equal(api.KindOptions{"mainClass": "tasks.Main", "javaVersion": "11"}, options)
+
+	def, err := NewDefinitionFromTask(api.Task{Slug: "my_task", Kind: kind, KindOptions: options})
+	require.NoError(err)
+	require.Equal(defs[0].Java, def.Java)
+}
@@ -0,0 +1,157 @@
+package definitions
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+
+	"github.com/airplanedev/cli/pkg/api"
+	"github.com/pkg/errors"
+)
+
+// Drift describes a single field whose value differs between a local
+// task definition and the task that is deployed to Airplane.
+type Drift struct {
+	Field  string      `json:"field" yaml:"field"`
+	Local  interface{} `json:"local" yaml:"local"`
+	Remote interface{} `json:"remote" yaml:"remote"`
+}
+
+// Drift compares def against remote field by field and returns every
+// field that differs, sorted by field name.
+//
+// Kind options are normalized through GetKindAndOptions on both sides so
+// that options which are not part of the definition format (f.e. "shim")
+// do not produce drift. Resources are expected to be keyed by name on both
+// definitions, callers are responsible for resolving resource IDs. The same
+// goes for the users and groups of permissions, which are only compared if
+// def declares permissions, since deploys leave them untouched otherwise.
+func (def Definition) Drift(remote Definition) ([]Drift, error) {
+	localKind, localOptions, err := def.GetKindAndOptions()
+	if err != nil {
+		return nil, errors.Wrap(err, "local definition")
+	}
+	remoteKind, remoteOptions, err := remote.GetKindAndOptions()
+	if err != nil {
+		return nil, errors.Wrap(err, "remote definition")
+	}
+
+	fields := map[string][2]interface{}{
+		"name":             {def.Name, remote.Name},
+		"description":      {def.Description, remote.Description},
+		"arguments":        {def.Arguments, remote.Arguments},
+		"parameters":       {[]api.Parameter(def.Parameters), []api.Parameter(remote.Parameters)},
+		"constraints":      {def.GetRunConstraints(), remote.Constraints},
+		"env":              {def.Env, remote.Env},
+		"resourceRequests": {def.ResourceRequests, remote.ResourceRequests},
+		"resources":        {def.Resources, remote.Resources},
+		"repo":             {def.Repo, remote.Repo},
+		"timeout":          {def.Timeout, remote.Timeout},
+		"kind":             {localKind, remoteKind},
+	}
+	if localKind == remoteKind {
+		for k := range localOptions {
+			fields["kindOptions."+k] = [2]interface{}{localOptions[k], remoteOptions[k]}
+		}
+		for k := range remoteOptions {
+			fields["kindOptions."+k] = [2]interface{}{localOptions[k], remoteOptions[k]}
+		}
+	}
+	if def.Image != nil && remote.Image != nil {
+		fields["image"] = [2]interface{}{def.Image.Image, remote.Image.Image}
+		fields["command"] = [2]interface{}{def.Image.Command, remote.Image.Command}
+	}
+
+	if def.Permissions != nil {
+		local := def.Permissions.Normalize()
+		var remotePermissions PermissionsDefinition
+		if remote.Permissions != nil {
+			remotePermissions = remote.Permissions.Normalize()
+		}
+		fields["permissions.requireExplicitPermissions"] = [2]interface{}{local.RequireExplicitPermissions, remotePermissions.RequireExplicitPermissions}
+		fields["permissions.viewers"] = [2]interface{}{local.Viewers, remotePermissions.Viewers}
+		fields["permissions.requesters"] = [2]interface{}{local.Requesters, remotePermissions.Requesters}
+		fields["permissions.executers"] = [2]interface{}{local.Executers, remotePermissions.Executers}
+		fields["permissions.admins"] = [2]interface{}{local.Admins, remotePermissions.Admins}
+	}
+
+	var drift []Drift
+	for field, values := range fields {
+		local, err := normalize(values[0])
+		if err != nil {
+			return nil, errors.Wrapf(err, "normalizing local %s", field)
+		}
+		remote, err := normalize(values[1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "normalizing remote %s", field)
+		}
+		if !reflect.DeepEqual(local, remote) {
+			drift = append(drift, Drift{
+				Field:  field,
+				Local:  local,
+				Remote: remote,
+			})
+		}
+	}
+	sort.Slice(drift, func(i, j int) bool {
+		return drift[i].Field < drift[j].Field
+	})
+
+	return drift, nil
+}
+
+// normalize converts v into its JSON representation and drops empty
+// values, so that f.e. a missing list and an empty list compare as equal.
+func normalize(v interface{}) (interface{}, error) {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	if err := json.Unmarshal(buf, &out); err != nil {
+		return nil, err
+	}
+	return prune(out), nil
+}
+
+func prune(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			if e = prune(e); e == nil {
+				delete(t, k)
+			} else {
+				t[k] = e
+			}
+		}
+		if len(t) == 0 {
+			return nil
+		}
+		return t
+	case []interface{}:
+		if len(t) == 0 {
+			return nil
+		}
+		for i, e := range t {
+			t[i] = prune(e)
+		}
+		return t
+	case string:
+		if t == "" {
+			return nil
+		}
+		return t
+	case bool:
+		if !t {
+			return nil
+		}
+		return t
+	case float64:
+		if t == 0 {
+			return nil
+		}
+		return t
+	default:
+		return v
+	}
+}
@@ -0,0 +1,106 @@
+package definitions
+
+import (
+	"testing"
+
+	"github.com/airplanedev/cli/pkg/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDrift(t *testing.T) {
+	require := require.New(t)
+
+	local := Definition{
+		Slug: "my_task",
+		Name: "My task",
+		Node: &NodeDefinition{
+			Entrypoint:  "main.ts",
+			Language:    "typescript",
+			NodeVersion: "16",
+		},
+		Timeout: 3600,
+	}
+
+	// Kind options the CLI doesn't manage, and empty vs. missing values, are not drift:
+	remote, err := NewDefinitionFromTask(api.Task{
+		Slug: "my_task",
+		Name: "My task",
+		Kind: api.TaskKindNode,
+		KindOptions: api.KindOptions{
+			"entrypoint":  "main.ts",
+			"language":    "typescript",
+			"nodeVersion": "16",
+			"shim":        "true",
+		},
+		Arguments: []string{},
+		Env:       api.TaskEnv{},
+		Timeout:   3600,
+	})
+	require.NoError(err)
+	drift, err := local.Drift(remote)
+	require.NoError(err)
+	require.Empty(drift)
+
+	// Changed fields are reported:
+	remote.Name = "Renamed"
+	remote.Node.NodeVersion = "14"
+	drift, err = local.Drift(remote)
+	require.NoError(err)
+	require.Equal([]Drift{
+		{Field: "kindOptions.nodeVersion", Local: "16", Remote: "14"},
+		{Field: "name", Local: "My task", Remote: "Renamed"},
+	}, drift)
+
+	// A different kind is reported without comparing kind options:
+	remote = Definition{
+		Slug:    "my_task",
+		Name:    "My task",
+		Python:  &PythonDefinition{Entrypoint: "main.py"},
+		Timeout: 3600,
+	}
+	drift, err = local.Drift(remote)
+	require.NoError(err)
+	require.Equal([]Drift{
+		{Field: "kind", Local: "node", Remote: "python"},
+	}, drift)
+
+	// Permissions are compared by the highest role of every user and group:
+	local.Permissions = &PermissionsDefinition{
+		Viewers: PermissionRecipients{Users: []string{"usr1"}},
+		Admins:  PermissionRecipients{Users: []string{"usr1"}, Groups: []string{"grp1"}},
+	}
+	usr1, grp1 := "usr1", "grp1"
+	var permissions api.Permissions
+	for _, action := range []api.Action{api.ActionTasksGet, api.ActionRunsGet, api.ActionTriggerRequestsCreate, api.ActionTasksExecute, api.ActionRunsCancel, api.ActionTasksUpdate} {
+		permissions = append(permissions,
+			api.Permission{Action: action, SubUserID: &usr1},
+			api.Permission{Action: action, SubGroupID: &grp1},
+		)
+	}
+	remote = local
+	remote.Permissions = NewPermissionsDefinition(false, permissions)
+	drift, err = local.Drift(remote)
+	require.NoError(err)
+	require.Empty(drift)
+
+	remote.Permissions = NewPermissionsDefinition(false, permissions[:6])
+	drift, err = local.Drift(remote)
+	require.NoError(err)
+	require.Equal([]Drift{
+		{Field: "permissions.admins", Local: map[string]interface{}{"users": []interface{}{"usr1"}, "groups": []interface{}{"grp1"}}, Remote: nil},
+		{Field: "permissions.requesters", Local: nil, Remote: map[string]interface{}{"users": []interface{}{"usr1"}, "groups": []interface{}{"grp1"}}},
+	}, drift)
+
+	remote.Permissions = NewPermissionsDefinition(true, permissions)
+	drift, err = local.Drift(remote)
+	require.NoError(err)
+	require.Equal([]Drift{
+		{Field: "permissions.requireExplicitPermissions", Local: nil, Remote: true},
+	}, drift)
+
+	// Permissions are left untouched by deploys if they aren't declared:
+	local.Permissions = nil
+	drift, err = local.Drift(remote)
+	require.NoError(err)
+	require.Empty(drift)
+}
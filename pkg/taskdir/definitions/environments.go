@@ -0,0 +1,69 @@
+package definitions
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/airplanedev/cli/pkg/api"
+	"github.com/airplanedev/cli/pkg/configs"
+	"github.com/pkg/errors"
+)
+
+// ForEnvironment returns the definition of the task that is deployed to env.
+//
+// The returned definition:
+//   - applies the env and resources overrides from `environments.<env>`.
+//   - references configs with the env tag, unless a tag is already set,
+//     f.e. `db/url` becomes `db/url:staging`.
+//   - uses an environment-suffixed slug and name, f.e. `my_task_staging`.
+func (def Definition) ForEnvironment(env string) (Definition, error) {
+	override, ok := def.Environments[env]
+	if !ok {
+		var envs []string
+		for name := range def.Environments {
+			envs = append(envs, name)
+		}
+		sort.Strings(envs)
+		if len(envs) == 0 {
+			return Definition{}, errors.Errorf("task %s does not define any environments", def.Slug)
+		}
+		return Definition{}, errors.Errorf("task %s does not define environment %q: expected one of (%s)", def.Slug, env, strings.Join(envs, ", "))
+	}
+
+	out := def
+	out.Environments = nil
+	out.Slug = def.Slug + "_" + env
+	out.Name = def.Name + " (" + env + ")"
+
+	out.Env = api.TaskEnv{}
+	for k, v := range def.Env {
+		out.Env[k] = v
+	}
+	for k, v := range override.Env {
+		out.Env[k] = v
+	}
+	for k, v := range out.Env {
+		if v.Config == nil {
+			continue
+		}
+		nt, err := configs.ParseName(*v.Config)
+		if err != nil {
+			return Definition{}, errors.Wrapf(err, "env %s", k)
+		}
+		if nt.Tag == "" {
+			nt.Tag = env
+		}
+		name := configs.JoinName(nt)
+		out.Env[k] = api.EnvVarValue{Config: &name}
+	}
+
+	out.Resources = api.Resources{}
+	for k, v := range def.Resources {
+		out.Resources[k] = v
+	}
+	for k, v := range override.Resources {
+		out.Resources[k] = v
+	}
+
+	return out, nil
+}
@@ -0,0 +1,61 @@
+package definitions
+
+import (
+	"testing"
+
+	"github.com/airplanedev/cli/pkg/api"
+	"github.com/airplanedev/cli/pkg/utils/pointers"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForEnvironment(t *testing.T) {
+	require := require.New(t)
+
+	defs, err := UnmarshalDefinitions([]byte(`
+slug: my_task
+name: My task
+node:
+  entrypoint: main.ts
+  language: typescript
+  nodeVersion: "16"
+env:
+  DATABASE_URL:
+    config: db/url
+  API_KEY:
+    config: api/key:shared
+  LOG_LEVEL:
+    value: info
+resources:
+  db: Production DB
+environments:
+  staging:
+    env:
+      LOG_LEVEL:
+        value: debug
+    resources:
+      db: Staging DB
+`), "airplane.yml")
+	require.NoError(err)
+	require.Len(defs, 1)
+
+	def, err := defs[0].ForEnvironment("staging")
+	require.NoError(err)
+	require.Equal("my_task_staging", def.Slug)
+	require.Equal("My task (staging)", def.Name)
+	require.Nil(def.Environments)
+
+	dbURL, apiKey := "db/url:staging", "api/key:shared"
+	require.Equal(api.TaskEnv{
+		"DATABASE_URL": {Config: &dbURL},
+		"API_KEY":      {Config: &apiKey},
+		"LOG_LEVEL":    {Value: pointers.String("debug")},
+	}, def.Env)
+	require.Equal(api.Resources{"db": "Staging DB"}, def.Resources)
+
+	// The base definition is left untouched:
+	require.Equal("info", *defs[0].Env["LOG_LEVEL"].Value)
+	require.Equal("db/url", *defs[0].Env["DATABASE_URL"].Config)
+
+	_, err = defs[0].ForEnvironment("prod")
+	require.EqualError(err, `task my_task does not define environment "prod": expected one of (staging)`)
+}
@@ -0,0 +1,36 @@
+package definitions
+
+import (
+	"strings"
+
+	"github.com/airplanedev/cli/pkg/api"
+)
+
+// ArchLabel is the agent label that agents report their architecture with.
+const ArchLabel = "arch"
+
+// GetRunConstraints returns the run constraints of the task, including the
+// constraint that is implied by its platforms.
+//
+// A task that is built for a single platform is constrained to agents of
+// that architecture, unless the definition constrains the architecture
+// itself. Multi-platform images run on all of their architectures and don't
+// add a constraint.
+func (def Definition) GetRunConstraints() api.RunConstraints {
+	if len(def.Platforms) != 1 {
+		return def.Constraints
+	}
+	for _, label := range def.Constraints.Labels {
+		if label.Key == ArchLabel {
+			return def.Constraints
+		}
+	}
+
+	parts := strings.SplitN(def.Platforms[0], "/", 2)
+	if len(parts) != 2 {
+		return def.Constraints
+	}
+	labels := append([]api.AgentLabel{}, def.Constraints.Labels...)
+	labels = append(labels, api.AgentLabel{Key: ArchLabel, Value: parts[1]})
+	return api.RunConstraints{Labels: labels}
+}
@@ -0,0 +1,30 @@
+package definitions
+
+import (
+	"testing"
+
+	"github.com/airplanedev/cli/pkg/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetRunConstraints(t *testing.T) {
+	require := require.New(t)
+
+	team := api.AgentLabel{Key: "team", Value: "data"}
+	def := Definition{
+		Constraints: api.RunConstraints{Labels: []api.AgentLabel{team}},
+	}
+	require.Equal(def.Constraints, def.GetRunConstraints())
+
+	// A single platform constrains the architecture:
+	def.Platforms = []string{"linux/arm64"}
+	require.Equal(api.RunConstraints{Labels: []api.AgentLabel{
+		team,
+		{Key: "arch", Value: "arm64"},
+	}}, def.GetRunConstraints())
+	require.Len(def.Constraints.Labels, 1)
+
+	// Multi-platform images run on any of their architectures:
+	def.Platforms = []string{"linux/amd64", "linux/arm64"}
+	require.Equal(def.Constraints, def.GetRunConstraints())
+}
@@ -16,6 +16,9 @@ type TaskDirectory struct {
 	defPath string
 	// closer is used to clean up TaskDirectory.
 	closer io.Closer
+	// vars are template variables that take precedence over the
+	// definition's own `vars:` block.
+	vars map[string]string
 }
 
 // New creates a TaskDirectory struct with the (desired) definition file as input
@@ -35,11 +38,18 @@ func New(file string) (TaskDirectory, error) {
 // Supports file in the form of github.com/path/to/repo/example and will download from GitHub
 // Supports file in the form of local_file.yml and will read it to determine the full details
 func Open(file string) (TaskDirectory, error) {
+	return OpenWithVars(file, nil)
+}
+
+// OpenWithVars is like Open, but interpolates vars into the task definition.
+//
+// See ReadDefinition for details on task definition templates.
+func OpenWithVars(file string, vars map[string]string) (TaskDirectory, error) {
 	if strings.HasPrefix(file, "http://") {
 		return TaskDirectory{}, errors.New("http:// paths are not supported, use https:// instead")
 	}
 
-	var td TaskDirectory
+	var td = TaskDirectory{vars: vars}
 	var err error
 	if strings.HasPrefix(file, "github.com/") || strings.HasPrefix(file, "https://github.com/") {
 		td.defPath, td.closer, err = openGitHubDirectory(file)
@@ -53,11 +63,12 @@ func Open(file string) (TaskDirectory, error) {
 		}
 	}
 
-	def, err := td.ReadDefinition()
+	// All tasks in a definition file share the same root.
+	defs, err := td.ReadDefinitions()
 	if err != nil {
 		return TaskDirectory{}, err
 	}
-	td.rootPath = path.Join(filepath.Dir(td.defPath), def.Root)
+	td.rootPath = path.Join(filepath.Dir(td.defPath), defs[0].Root)
 
 	if !strings.HasPrefix(td.defPath, td.rootPath+string(filepath.Separator)) {
 		return TaskDirectory{}, errors.Errorf("%s must be inside of the task's root directory: %s", path.Base(td.defPath), td.rootPath)
@@ -4,6 +4,8 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/airplanedev/cli/pkg/logger"
 	"github.com/airplanedev/cli/pkg/taskdir/definitions"
@@ -12,14 +14,40 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+// ReadDefinition reads and parses the task definition.
+//
+// Task definitions can be templates: `extends:` inherits fields from another
+// definition and `${name}` references are interpolated from the `vars:` block
+// and any vars that td was opened with.
+//
+// An error is returned if the file defines more than one task, use
+// ReadDefinitions to support those.
 func (td TaskDirectory) ReadDefinition() (definitions.Definition, error) {
-	buf, err := ioutil.ReadFile(td.defPath)
+	defs, err := td.ReadDefinitions()
 	if err != nil {
-		return definitions.Definition{}, errors.Wrap(err, "reading task definition")
+		return definitions.Definition{}, err
+	}
+	if len(defs) != 1 {
+		return definitions.Definition{}, errors.Errorf("%s defines %d tasks, expected a single task", td.prettyPath(), len(defs))
 	}
+	return defs[0], nil
+}
+
+// ReadDefinitions reads and parses all task definitions in the file. Multiple
+// tasks can be defined in a single file with a `tasks:` list.
+func (td TaskDirectory) ReadDefinitions() ([]definitions.Definition, error) {
+	buf, err := renderTemplate(td.defPath, td.vars)
+	if err != nil {
+		return nil, err
+	}
+
+	return definitions.UnmarshalDefinitions(buf, td.prettyPath())
+}
 
+// prettyPath returns the definition path relative to the working
+// directory, best effort.
+func (td TaskDirectory) prettyPath() string {
 	defPath := td.defPath
-	// Attempt to set a prettier defPath, best effort
 	if wd, err := os.Getwd(); err != nil {
 		logger.Debug("%s", err)
 	} else if path, err := filepath.Rel(wd, defPath); err != nil {
@@ -27,8 +55,7 @@ func (td TaskDirectory) ReadDefinition() (definitions.Definition, error) {
 	} else {
 		defPath = path
 	}
-
-	return definitions.UnmarshalDefinition(buf, defPath)
+	return defPath
 }
 
 // WriteSlug updates the slug of a task definition and persists td to disk.
@@ -42,6 +69,26 @@ func (td TaskDirectory) WriteSlug(slug string) error {
 	return nil
 }
 
+// WriteFields updates fields of a task definition, keyed by their path, f.e.
+// "node.entrypoint", and persists td to disk. A nil value removes the field.
+//
+// Like WriteSlug, it attempts to retain the existing file's formatting.
+func (td TaskDirectory) WriteFields(fields map[string]interface{}) error {
+	paths := make([]string, 0, len(fields))
+	for path := range fields {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		if err := utils.SetYAMLFieldValue(td.defPath, strings.Split(path, "."), fields[path]); err != nil {
+			return errors.Wrapf(err, "setting %s", path)
+		}
+	}
+
+	return nil
+}
+
 func (td TaskDirectory) WriteDefinition(def definitions.Definition) error {
 	data, err := yaml.Marshal(def)
 	if err != nil {
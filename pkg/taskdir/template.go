@@ -0,0 +1,233 @@
+package taskdir
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/airplanedev/cli/pkg/utils"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// varRegex matches `${name}` references to template variables. A reference
+	// can be escaped as `$${name}` to produce a literal `${name}`.
+	varRegex = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+	// templateKeys are the top-level keys of template directives.
+	templateKeys = []string{"extends", "vars"}
+)
+
+// ParseVars parses a list of `key=value` pairs, f.e. from `--var` flags.
+func ParseVars(pairs []string) (map[string]string, error) {
+	vars := map[string]string{}
+	for _, pair := range pairs {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, errors.Errorf("invalid variable %q: expected key=value", pair)
+		}
+		vars[parts[0]] = parts[1]
+	}
+	return vars, nil
+}
+
+// renderTemplate reads the task definition at path and resolves its template
+// directives:
+//
+//   - `extends: ../base.yml` inherits all fields from another definition. Maps
+//     are merged recursively, any other value in this definition overrides the
+//     inherited one. Relative paths, such as `root`, are interpreted relative to
+//     the extending definition.
+//   - `vars:` declares variables that are interpolated into `${name}` references.
+//     A var without a value, f.e. `env:`, must be set with the vars argument.
+//     The vars argument, f.e. from `--var` flags, declares variables too and
+//     takes precedence over the vars block.
+//
+// Only declared variables are interpolated, other `${name}` references, f.e.
+// to environment variables in a command, are left untouched. Definitions that
+// don't use any template directives are returned unchanged.
+func renderTemplate(path string, vars map[string]string) ([]byte, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading task definition")
+	}
+	if !isTemplate(buf) && len(vars) == 0 {
+		return buf, nil
+	}
+
+	doc, err := loadTemplate(path, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+
+	// Values maps every declared variable to its value, or nil if it must
+	// be set with vars.
+	values := map[string]*string{}
+	if node := removeKey(doc, "vars"); node != nil {
+		if node.Kind != yaml.MappingNode {
+			return nil, errors.Errorf("%s: vars must be a map", path)
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			k, v := node.Content[i], node.Content[i+1]
+			if v.Kind != yaml.ScalarNode {
+				return nil, errors.Errorf("%s: var %s must be a scalar value", path, k.Value)
+			}
+			if v.Tag == "!!null" {
+				values[k.Value] = nil
+			} else {
+				value := v.Value
+				values[k.Value] = &value
+			}
+		}
+	}
+	for k, v := range vars {
+		v := v
+		values[k] = &v
+	}
+
+	if err := interpolate(doc, values); err != nil {
+		return nil, errors.Wrap(err, path)
+	}
+
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshalling rendered task definition")
+	}
+	return out, nil
+}
+
+// IsTemplate reports whether the task definition uses any template
+// directives, such as `extends:` or `vars:`, or was opened with vars.
+func (td TaskDirectory) IsTemplate() (bool, error) {
+	if len(td.vars) > 0 {
+		return true, nil
+	}
+	buf, err := ioutil.ReadFile(td.defPath)
+	if err != nil {
+		return false, errors.Wrap(err, "reading task definition")
+	}
+	return isTemplate(buf), nil
+}
+
+// isTemplate reports whether buf has any top-level template directives. Files
+// that aren't a YAML map are left for the definition parser to report.
+func isTemplate(buf []byte) bool {
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(buf, &doc); err != nil {
+		return false
+	}
+	for _, key := range templateKeys {
+		if _, ok := doc[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// loadTemplate reads the definition at path and merges it on top of the
+// definition it extends, if any. Seen tracks visited files to detect cycles.
+func loadTemplate(path string, seen map[string]bool) (*yaml.Node, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "converting path to absolute path")
+	}
+	if seen[path] {
+		return nil, errors.Errorf("%s: extends cycle detected", path)
+	}
+	seen[path] = true
+
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading task definition")
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(buf, &root); err != nil {
+		return nil, errors.Wrapf(err, "%s: invalid YAML", path)
+	}
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.Errorf("%s: expected a map", path)
+	}
+	doc := root.Content[0]
+
+	extends := removeKey(doc, "extends")
+	if extends == nil {
+		return doc, nil
+	}
+	if extends.Kind != yaml.ScalarNode || extends.Value == "" {
+		return nil, errors.Errorf("%s: extends must be a file path", path)
+	}
+
+	// Since vars is a map, it is merged like any other field: the base
+	// definition's vars are inherited and can be overridden.
+	base, err := loadTemplate(filepath.Join(filepath.Dir(path), extends.Value), seen)
+	if err != nil {
+		return nil, err
+	}
+	return utils.MergeYAMLNodes(base, doc), nil
+}
+
+// removeKey removes key from the mapping node and returns its value, if any.
+func removeKey(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			v := node.Content[i+1]
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+			return v
+		}
+	}
+	return nil
+}
+
+// interpolate replaces `${name}` references to the declared vars in all scalar
+// values of node. References to other names are left untouched, as are their
+// `$${name}` escapes.
+//
+// If an unquoted scalar consists of a single reference, its type is inferred
+// from the variable's value so that f.e. `timeout: ${timeout}` remains a number.
+func interpolate(node *yaml.Node, vars map[string]*string) error {
+	if node.Kind != yaml.ScalarNode {
+		for _, n := range node.Content {
+			if err := interpolate(n, vars); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var rerr error
+	value := varRegex.ReplaceAllStringFunc(node.Value, func(ref string) string {
+		name := varRegex.FindStringSubmatch(ref)[1]
+		v, ok := vars[name]
+		if !ok {
+			return ref
+		}
+		if strings.HasPrefix(ref, "$$") {
+			return ref[1:]
+		}
+		if v == nil {
+			if rerr == nil {
+				rerr = errors.Errorf("undefined variable: %s", name)
+			}
+			return ref
+		}
+		return *v
+	})
+	if rerr != nil {
+		return rerr
+	}
+	if value == node.Value {
+		return nil
+	}
+
+	whole := varRegex.FindString(node.Value) == node.Value && !strings.HasPrefix(node.Value, "$$")
+	if whole && node.Style == 0 {
+		// Let the decoder resolve the type of the interpolated value.
+		node.Tag = ""
+	} else {
+		node.Tag = "!!str"
+	}
+	node.Value = value
+	return nil
+}
@@ -0,0 +1,104 @@
+package taskdir
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/airplanedev/cli/pkg/api"
+	"github.com/airplanedev/cli/pkg/taskdir/definitions"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	require := require.New(t)
+
+	// Variables are required to be defined:
+	_, err := renderTemplate("testdata/templates/service/airplane.yml", nil)
+	require.EqualError(err, "testdata/templates/service/airplane.yml: undefined variable: env")
+
+	buf, err := renderTemplate("testdata/templates/service/airplane.yml", map[string]string{
+		"env": "staging",
+	})
+	require.NoError(err)
+	def, err := definitions.UnmarshalDefinition(buf, "airplane.yml")
+	require.NoError(err)
+
+	require.Equal("billing_report", def.Slug)
+	require.Equal("billing report", def.Name)
+	require.Equal(600, def.Timeout)
+	require.Equal(api.Resources{"db": "billing-staging"}, def.Resources)
+	require.Equal("billing", *def.Env["SERVICE"].Value)
+	require.Equal("debug", *def.Env["LOG_LEVEL"].Value)
+	require.Equal("main.ts", def.Node.Entrypoint)
+
+	// Vars take precedence over the vars block:
+	buf, err = renderTemplate("testdata/templates/service/airplane.yml", map[string]string{
+		"env":     "prod",
+		"service": "payments",
+	})
+	require.NoError(err)
+	def, err = definitions.UnmarshalDefinition(buf, "airplane.yml")
+	require.NoError(err)
+	require.Equal("payments_report", def.Slug)
+	require.Equal(api.Resources{"db": "payments-prod"}, def.Resources)
+
+	_, err = renderTemplate("testdata/templates/cycle.yml", nil)
+	require.Error(err)
+	require.Contains(err.Error(), "extends cycle detected")
+}
+
+func TestRenderUndeclaredVars(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "airplane-template-*")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+	write := func(content string) string {
+		path := filepath.Join(dir, "airplane.yml")
+		require.NoError(ioutil.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+
+	// Definitions without template directives are not interpolated, even if
+	// they mention vars: or extends: in a value.
+	content := `slug: my_task
+name: My task
+description: "Set vars: with --var, see extends: too"
+image:
+  image: alpine
+  command: ["sh", "-c", "echo ${HOME} $${HOME}"]
+`
+	path := write(content)
+	buf, err := renderTemplate(path, nil)
+	require.NoError(err)
+	require.Equal(content, string(buf))
+
+	// Templates only interpolate declared vars:
+	path = write("vars:\n  greeting: hello\n" + content + "env:\n  GREETING:\n    value: ${greeting} $${greeting}\n")
+	buf, err = renderTemplate(path, nil)
+	require.NoError(err)
+	def, err := definitions.UnmarshalDefinition(buf, "airplane.yml")
+	require.NoError(err)
+	require.Equal([]string{"sh", "-c", "echo ${HOME} $${HOME}"}, def.Image.Command)
+	require.Equal("hello ${greeting}", *def.Env["GREETING"].Value)
+
+	// Vars are declared by the vars argument too:
+	buf, err = renderTemplate(path, map[string]string{"HOME": "/root"})
+	require.NoError(err)
+	def, err = definitions.UnmarshalDefinition(buf, "airplane.yml")
+	require.NoError(err)
+	require.Equal([]string{"sh", "-c", "echo /root ${HOME}"}, def.Image.Command)
+}
+
+func TestParseVars(t *testing.T) {
+	require := require.New(t)
+
+	vars, err := ParseVars([]string{"a=b", "query=x=1"})
+	require.NoError(err)
+	require.Equal(map[string]string{"a": "b", "query": "x=1"}, vars)
+
+	_, err = ParseVars([]string{"a"})
+	require.Error(err)
+}
@@ -4,3 +4,10 @@ type ErrorExplained interface {
 	Error() string
 	ExplainError() string
 }
+
+// ErrorExitCode is implemented by errors that should terminate
+// the CLI with a specific, non-default, exit code.
+type ErrorExitCode interface {
+	Error() string
+	ExitCode() int
+}
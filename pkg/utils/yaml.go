@@ -2,6 +2,7 @@ package utils
 
 import (
 	"os"
+	"strings"
 
 	"github.com/pkg/errors"
 	"gopkg.in/yaml.v3"
@@ -28,6 +29,103 @@ func GetYAMLNode(node *yaml.Node, field string) (*yaml.Node, error) {
 }
 
 func SetYAMLField(path, field, value string) error {
+	return updateYAML(path, func(mapnode *yaml.Node) error {
+		node, err := GetYAMLNode(mapnode, field)
+		if err != nil {
+			return err
+		}
+
+		if node != nil {
+			// This field already exists, so just update it's value.
+			node.Value = value
+		} else {
+			// This field does not exist yet, so prepend this field into the map.
+			mapnode.Content = append([]*yaml.Node{
+				{
+					Kind:  yaml.ScalarNode,
+					Tag:   "!!str",
+					Value: field,
+				},
+				{
+					Kind:  yaml.ScalarNode,
+					Tag:   "!!str",
+					Value: value,
+				},
+			}, mapnode.Content...)
+		}
+		return nil
+	})
+}
+
+// SetYAMLFieldValue sets the field at fieldPath, f.e. ["node", "entrypoint"],
+// to value. Missing maps along fieldPath are created and a nil value removes
+// the field.
+//
+// Like SetYAMLField, it retains the rest of the file's formatting (comments,
+// etc.) and the comments of the field itself.
+func SetYAMLFieldValue(path string, fieldPath []string, value interface{}) error {
+	if len(fieldPath) == 0 {
+		return errors.New("expected a field path")
+	}
+
+	var node *yaml.Node
+	if value != nil {
+		node = &yaml.Node{}
+		if err := node.Encode(value); err != nil {
+			return errors.Wrapf(err, "encoding %s", strings.Join(fieldPath, "."))
+		}
+	}
+
+	return updateYAML(path, func(mapnode *yaml.Node) error {
+		for i, field := range fieldPath {
+			last := i == len(fieldPath)-1
+			idx := -1
+			for j := 0; j+1 < len(mapnode.Content); j += 2 {
+				if mapnode.Content[j].Value == field {
+					idx = j
+					break
+				}
+			}
+
+			switch {
+			case idx < 0 && node == nil:
+				// Nothing to remove.
+				return nil
+			case idx < 0:
+				var child *yaml.Node
+				if last {
+					child = node
+				} else {
+					child = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+				}
+				mapnode.Content = append(mapnode.Content, &yaml.Node{
+					Kind:  yaml.ScalarNode,
+					Tag:   "!!str",
+					Value: field,
+				}, child)
+				mapnode = child
+			case last && node == nil:
+				mapnode.Content = append(mapnode.Content[:idx], mapnode.Content[idx+2:]...)
+			case last:
+				old := mapnode.Content[idx+1]
+				node.HeadComment = old.HeadComment
+				node.LineComment = old.LineComment
+				node.FootComment = old.FootComment
+				mapnode.Content[idx+1] = node
+			default:
+				mapnode = mapnode.Content[idx+1]
+				if mapnode.Kind != yaml.MappingNode {
+					return errors.Errorf("cannot set %s: %s is not a map", strings.Join(fieldPath, "."), strings.Join(fieldPath[:i+1], "."))
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// updateYAML decodes the YAML document at path, calls fn with its root map
+// and writes the updated document back to path.
+func updateYAML(path string, fn func(mapnode *yaml.Node) error) error {
 	f, err := os.OpenFile(path, os.O_RDWR, 0)
 	if err != nil {
 		return errors.Wrap(err, "opening task definition")
@@ -40,7 +138,7 @@ func SetYAMLField(path, field, value string) error {
 	}
 
 	if len(root.Content) == 0 {
-		return errors.Errorf("cannot insert %s: yaml document empty", field)
+		return errors.New("cannot update fields: yaml document empty")
 	}
 	// Find the root map, which may not be the first element due to comments.
 	var mapnode *yaml.Node
@@ -51,33 +149,13 @@ func SetYAMLField(path, field, value string) error {
 		}
 	}
 	if mapnode == nil {
-		return errors.Errorf("cannot insert %s: yaml document has map field", field)
+		return errors.New("cannot update fields: yaml document has no map")
 	}
 
-	node, err := GetYAMLNode(mapnode, field)
-	if err != nil {
+	if err := fn(mapnode); err != nil {
 		return err
 	}
 
-	if node != nil {
-		// This field already exists, so just update it's value.
-		node.Value = value
-	} else {
-		// This field does not exist yet, so prepend this field into the map.
-		mapnode.Content = append([]*yaml.Node{
-			{
-				Kind:  yaml.ScalarNode,
-				Tag:   "!!str",
-				Value: field,
-			},
-			{
-				Kind:  yaml.ScalarNode,
-				Tag:   "!!str",
-				Value: value,
-			},
-		}, mapnode.Content...)
-	}
-
 	if _, err := f.Seek(0, 0); err != nil {
 		return errors.Wrap(err, "seeking to start of task definition")
 	}
@@ -92,3 +170,30 @@ func SetYAMLField(path, field, value string) error {
 
 	return nil
 }
+
+// MergeYAMLNodes merges override on top of base and returns the result. Mapping
+// nodes are merged recursively, any other node in override replaces the node in
+// base. Neither base nor override are modified.
+func MergeYAMLNodes(base, override *yaml.Node) *yaml.Node {
+	if base.Kind != yaml.MappingNode || override.Kind != yaml.MappingNode {
+		return override
+	}
+
+	out := *base
+	out.Content = append([]*yaml.Node{}, base.Content...)
+	for i := 0; i+1 < len(override.Content); i += 2 {
+		k, v := override.Content[i], override.Content[i+1]
+		found := false
+		for j := 0; j+1 < len(out.Content); j += 2 {
+			if out.Content[j].Value == k.Value {
+				out.Content[j+1] = MergeYAMLNodes(out.Content[j+1], v)
+				found = true
+				break
+			}
+		}
+		if !found {
+			out.Content = append(out.Content, k, v)
+		}
+	}
+	return &out
+}
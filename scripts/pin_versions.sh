@@ -0,0 +1,49 @@
+#!/bin/bash
+
+# Pins the base images in pkg/build/versions.json that don't have a digest
+# yet, f.e. after adding a version.
+#
+# The tag of every image is resolved to the digest of its linux/amd64 image,
+# and of its images for PLATFORMS in `digests`. Digests that are already set
+# are left untouched. Requires docker buildx and jq.
+set -euo pipefail
+
+VERSIONS="$(dirname "$0")/../pkg/build/versions.json"
+PLATFORMS=(linux/amd64 linux/arm64)
+
+# Prints the digest of the image of ref for the platform os/arch.
+function platform_digest() {
+    local ref="$1" os="${2%/*}" arch="${2#*/}"
+    docker buildx imagetools inspect --raw "${ref}" | jq -er --arg os "${os}" --arg arch "${arch}" '
+        .manifests[]? | select(.platform.os == $os and .platform.architecture == $arch) | .digest'
+}
+
+# Sets the value at the jq path to digest, if it's not set yet.
+function pin() {
+    local path="$1" digest="$2"
+    local tmp
+    tmp=$(mktemp)
+    jq --indent 2 --arg digest "${digest}" "${path} //= \$digest" "${VERSIONS}" > "${tmp}"
+    mv "${tmp}" "${VERSIONS}"
+}
+
+jq -r 'to_entries[] | .key as $builder | .value | to_entries[] | [$builder, .key, .value.image + ":" + .value.tag] | @tsv' "${VERSIONS}" |
+while IFS=$'\t' read -r builder version ref; do
+    for platform in "${PLATFORMS[@]}"; do
+        if [[ "${platform}" == "linux/amd64" ]]; then
+            path=".\"${builder}\".\"${version}\".digest"
+        else
+            path=".\"${builder}\".\"${version}\".digests.\"${platform}\""
+        fi
+        if [[ "$(jq -r "${path} // empty" "${VERSIONS}")" != "" ]]; then
+            continue
+        fi
+
+        if digest=$(platform_digest "${ref}" "${platform}"); then
+            echo "${builder} ${version}: ${ref} (${platform}) is ${digest}"
+            pin "${path}" "${digest}"
+        else
+            echo "${builder} ${version}: ${ref} is not available for ${platform}" >&2
+        fi
+    done
+done